@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/trustmaster/goflow"
+	"github.com/trustmaster/goflow/components/core"
+	"github.com/trustmaster/goflow/components/fileio"
+	"github.com/trustmaster/goflow/components/timing"
+	"github.com/trustmaster/goflow/components/web"
+)
+
+// libraries register the component libraries compiled into the tool.
+// Custom builds of the tool can add their own libraries here.
+var libraries = []func(f *goflow.Factory) error{
+	core.Register,
+	fileio.Register,
+	timing.Register,
+	web.Register,
+}
@@ -0,0 +1,323 @@
+// Command goflow runs and inspects graphs defined in JSON or FBP files.
+//
+// Usage:
+//
+//	goflow run [-lib dir] [-param name=value] [-in Port=file] [-out Port=file] [-watch interval] graph.json
+//	goflow validate [-lib dir] [-param name=value] graph.json
+//	goflow dot [-lib dir] [-param name=value] graph.json
+//	goflow gen [-lib dir] [-param name=value] [-package name] [-pkgpath path] [-name Type] [-o file] graph.json
+//	goflow list-components [-lib dir] [-json] [prefix]
+//	goflow new component Name [-in Port:type] [-out Port:type] [-package name] [-library name] [-dir dir]
+//
+// Components come from the libraries compiled into the tool and from graph
+// files found in -lib directories, which can be used as subgraphs. Graph
+// parameters are set with -param flags or GOFLOW_PARAM_<NAME> environment variables.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/trustmaster/goflow"
+)
+
+const usage = `Usage: goflow <command> [flags] [args]
+
+Commands:
+  run              run a graph file
+  validate         check a graph file for errors
+  dot              render a graph file in Graphviz DOT format
+  gen              generate Go code of a graph file
+  list-components  print the available components
+  new component    generate a component skeleton with a test
+
+Run "goflow <command> -h" for command flags.
+`
+
+// command is a subcommand of the tool.
+type command func(args []string, stdio stdio) error
+
+// stdio holds the standard streams, so commands can be tested.
+type stdio struct {
+	in       io.Reader
+	out, err io.Writer
+}
+
+var commands = map[string]command{
+	"run":             runCommand,
+	"validate":        validateCommand,
+	"dot":             dotCommand,
+	"gen":             genCommand,
+	"list-components": listCommand,
+	"new":             newCommand,
+}
+
+func main() {
+	os.Exit(execute(os.Args[1:], stdio{os.Stdin, os.Stdout, os.Stderr}))
+}
+
+// execute runs a command and returns the exit code.
+func execute(args []string, stdio stdio) int {
+	if len(args) == 0 {
+		fmt.Fprint(stdio.err, usage)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stdio.err, "goflow: unknown command '%s'\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(args[1:], stdio); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(stdio.err, "goflow %s: %s\n", args[0], err)
+		}
+
+		return 1
+	}
+
+	return 0
+}
+
+// listFlag is a flag which can be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// newFlagSet creates flags common for all commands.
+func newFlagSet(name string, stdio stdio, libs *listFlag) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdio.err)
+	fs.Var(libs, "lib", "directory of graph files to register as components (repeatable)")
+
+	return fs
+}
+
+// newFactory registers the compiled in libraries and graph directories.
+func newFactory(libs []string) (*goflow.Factory, error) {
+	f := goflow.NewFactory()
+
+	for _, register := range libraries {
+		if err := register(f); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dir := range libs {
+		if err := f.RegisterGraphFS(os.DirFS(dir), "."); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// loadGraph parses the flags and loads the graph file given as the only argument.
+// It adds the flag setting graph parameters.
+func loadGraph(fs *flag.FlagSet, args []string, libs *listFlag) (*goflow.Graph, *goflow.Factory, error) {
+	fs.Var(new(listFlag), "param", "set a graph parameter, name=value, overriding "+paramEnvPrefix+"<NAME> environment variables (repeatable)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if fs.NArg() != 1 {
+		return nil, nil, fmt.Errorf("expected a graph file")
+	}
+
+	f, err := newFactory(*libs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n, err := goflow.LoadGraph(fs.Arg(0), f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := setParams(fs, n); err != nil {
+		return nil, nil, err
+	}
+
+	return n, f, nil
+}
+
+// paramEnvPrefix is the prefix of environment variables setting graph parameters.
+const paramEnvPrefix = "GOFLOW_PARAM_"
+
+// setParams sets graph parameters from the environment and then from -param flags.
+func setParams(fs *flag.FlagSet, n *goflow.Graph) error {
+	if err := n.SetParamsFromEnv(paramEnvPrefix); err != nil {
+		return err
+	}
+
+	for _, param := range *fs.Lookup("param").Value.(*listFlag) {
+		eq := strings.Index(param, "=")
+		if eq < 0 {
+			return fmt.Errorf("invalid parameter '%s', expected name=value", param)
+		}
+
+		if err := n.SetParam(param[:eq], param[eq+1:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateCommand(args []string, stdio stdio) error {
+	var libs listFlag
+
+	fs := newFlagSet("validate", stdio, &libs)
+
+	n, _, err := loadGraph(fs, args, &libs)
+	if err != nil {
+		return err
+	}
+
+	if err := n.Validate(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdio.out, "OK")
+
+	return nil
+}
+
+func dotCommand(args []string, stdio stdio) error {
+	var libs listFlag
+
+	fs := newFlagSet("dot", stdio, &libs)
+
+	n, _, err := loadGraph(fs, args, &libs)
+	if err != nil {
+		return err
+	}
+
+	return n.WriteDot(stdio.out)
+}
+
+func genCommand(args []string, stdio stdio) error {
+	var libs listFlag
+
+	fs := newFlagSet("gen", stdio, &libs)
+	pkg := fs.String("package", "main", "package name of the generated code")
+	pkgPath := fs.String("pkgpath", "", "import path of the package, its own types are not qualified")
+	name := fs.String("name", "", "name of the generated network type, derived from the file name by default")
+	output := fs.String("o", "", "output file, stdout by default")
+
+	n, _, err := loadGraph(fs, args, &libs)
+	if err != nil {
+		return err
+	}
+
+	conf := goflow.GenConfig{
+		Package: *pkg,
+		PkgPath: *pkgPath,
+		Name:    *name,
+		Source:  filepath.Base(fs.Arg(0)),
+	}
+
+	if conf.Name == "" {
+		conf.Name = typeName(strings.TrimSuffix(conf.Source, filepath.Ext(conf.Source)))
+	}
+
+	if *output == "" {
+		return n.GenerateGo(stdio.out, conf)
+	}
+
+	var buf bytes.Buffer
+	if err := n.GenerateGo(&buf, conf); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(*output, buf.Bytes(), 0o644)
+}
+
+// typeName converts a file name like "word-count" into a type name like "WordCount".
+func typeName(s string) string {
+	var b strings.Builder
+
+	upper := true
+
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) && b.Len() > 0:
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+
+			b.WriteRune(r)
+
+			upper = false
+		default:
+			upper = true
+		}
+	}
+
+	if b.Len() == 0 {
+		return "Graph"
+	}
+
+	return b.String()
+}
+
+func listCommand(args []string, stdio stdio) error {
+	var libs listFlag
+
+	fs := newFlagSet("list-components", stdio, &libs)
+	asJSON := fs.Bool("json", false, "print component info as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := newFactory(libs)
+	if err != nil {
+		return err
+	}
+
+	list := f.List(fs.Arg(0))
+
+	if *asJSON {
+		enc := json.NewEncoder(stdio.out)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(list)
+	}
+
+	for _, info := range list {
+		name := info.Name
+		if info.Version != "" {
+			name += "@" + info.Version
+		}
+
+		fmt.Fprintf(stdio.out, "%s\t%s\n", name, info.Description)
+
+		for _, p := range info.InPorts {
+			fmt.Fprintf(stdio.out, "\tin  %s %s\t%s\n", p.ID, p.Type, p.Description)
+		}
+
+		for _, p := range info.OutPorts {
+			fmt.Fprintf(stdio.out, "\tout %s %s\t%s\n", p.ID, p.Type, p.Description)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/trustmaster/goflow"
+)
+
+type doubler struct {
+	In  <-chan int
+	Out chan<- int
+}
+
+func (c *doubler) Process() {
+	for i := range c.In {
+		c.Out <- 2 * i
+	}
+}
+
+func init() {
+	libraries = append(libraries, func(f *goflow.Factory) error {
+		if err := f.Register("test/Doubler", func() (interface{}, error) {
+			return new(doubler), nil
+		}); err != nil {
+			return err
+		}
+
+		return f.Annotate("test/Doubler", goflow.Annotation{
+			Description: "Doubles its input",
+		})
+	})
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+func executeString(args []string, input string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+
+	code = execute(args, stdio{strings.NewReader(input), &out, &errOut})
+
+	return code, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	graph := writeFile(t, dir, "graph.fbp", "INPORT=a.IN:IN\nOUTPORT=b.OUT:OUT\na(test/Doubler) OUT -> IN b(test/Doubler)\n")
+
+	code, stdout, stderr := executeString([]string{"run", graph}, "1\n2\nthree\n3\n")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if stdout != "4\n8\n12\n" {
+		t.Errorf("Unexpected output %q", stdout)
+	}
+
+	if !strings.Contains(stderr, "In: line 3") {
+		t.Errorf("Invalid input is not reported: %q", stderr)
+	}
+
+	// Files
+	input := writeFile(t, dir, "input.txt", "5\n")
+	output := filepath.Join(dir, "output.txt")
+
+	code, _, stderr = executeString([]string{"run", "-in", "in=" + input, "-out", "Out=" + output, graph}, "")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if data, err := ioutil.ReadFile(output); err != nil || string(data) != "20\n" {
+		t.Errorf("Unexpected output %q: %v", data, err)
+	}
+
+	if code, _, _ = executeString([]string{"run", "-in", "Nope=-", graph}, ""); code != 1 {
+		t.Errorf("%d != 1", code)
+	}
+}
+
+func TestRunParams(t *testing.T) {
+	dir := t.TempDir()
+	graph := writeFile(t, dir, "graph.json", `{
+		"params": {"n": {"type": "int"}},
+		"processes": {"a": {"component": "test/Doubler"}},
+		"connections": [{"data": "${n}", "tgt": {"process": "a", "port": "In"}}],
+		"outports": {"Out": {"process": "a", "port": "Out"}}
+	}`)
+
+	os.Setenv("GOFLOW_PARAM_N", "3")
+	defer os.Unsetenv("GOFLOW_PARAM_N")
+
+	if code, stdout, stderr := executeString([]string{"run", graph}, ""); code != 0 || stdout != "6\n" {
+		t.Errorf("Exit code %d, output %q: %s", code, stdout, stderr)
+	}
+
+	// Flags override the environment
+	if code, stdout, stderr := executeString([]string{"run", "-param", "n=4", graph}, ""); code != 0 || stdout != "8\n" {
+		t.Errorf("Exit code %d, output %q: %s", code, stdout, stderr)
+	}
+
+	if code, _, stderr := executeString([]string{"validate", "-param", "m=4", graph}, ""); code != 1 || !strings.Contains(stderr, "'m'") {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeFile(t, dir, "valid.fbp", "'2' -> IN a(test/Doubler)\nOUTPORT=a.OUT:OUT\n")
+	invalid := writeFile(t, dir, "invalid.fbp", "'2' -> IN a(test/Doubler)\n")
+	unknown := writeFile(t, dir, "unknown.fbp", "'2' -> IN a(test/Nope)\n")
+
+	if code, stdout, stderr := executeString([]string{"validate", valid}, ""); code != 0 || stdout != "OK\n" {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if code, _, stderr := executeString([]string{"validate", invalid}, ""); code != 1 || !strings.Contains(stderr, "a.Out") {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if code, _, _ := executeString([]string{"validate", unknown}, ""); code != 1 {
+		t.Errorf("%d != 1", code)
+	}
+}
+
+func TestDotAndSubgraphs(t *testing.T) {
+	lib := t.TempDir()
+	writeFile(t, lib, "quad.fbp", "INPORT=a.IN:IN\nOUTPORT=b.OUT:OUT\na(test/Doubler) OUT -> IN b(test/Doubler)\n")
+	graph := writeFile(t, t.TempDir(), "graph.fbp", "INPORT=q.IN:IN\nOUTPORT=q.OUT:OUT\nq(quad)\n")
+
+	code, stdout, stderr := executeString([]string{"dot", "-lib", lib, graph}, "")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "digraph {") || !strings.Contains(stdout, `"q" [label="q\n(quad)"]`) {
+		t.Errorf("Unexpected output %s", stdout)
+	}
+
+	code, stdout, stderr = executeString([]string{"run", "-lib", lib, graph}, "1\n")
+	if code != 0 || stdout != "4\n" {
+		t.Errorf("Exit code %d, output %q: %s", code, stdout, stderr)
+	}
+}
+
+func TestListComponents(t *testing.T) {
+	code, stdout, stderr := executeString([]string{"list-components", "test/"}, "")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if !strings.Contains(stdout, "test/Doubler\tDoubles its input\n\tin  In int\t\n\tout Out int\t\n") {
+		t.Errorf("Unexpected output %q", stdout)
+	}
+
+	code, stdout, _ = executeString([]string{"list-components", "-json"}, "")
+	if code != 0 || !strings.Contains(stdout, `"name": "test/Doubler"`) {
+		t.Errorf("Unexpected output %q", stdout)
+	}
+
+	if code, _, _ = executeString([]string{"unknown"}, ""); code != 2 {
+		t.Errorf("%d != 2", code)
+	}
+}
+
+func TestGen(t *testing.T) {
+	dir := t.TempDir()
+	graph := writeFile(t, dir, "double-twice.fbp", "INPORT=a.IN:IN\nOUTPORT=b.OUT:OUT\na(test/Doubler) OUT -> IN b(test/Doubler)\n")
+
+	code, stdout, stderr := executeString([]string{"gen", "-pkgpath", "github.com/trustmaster/goflow/cmd/goflow", graph}, "")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	for _, s := range []string{
+		"// Code generated by goflow gen from double-twice.fbp. DO NOT EDIT.",
+		"package main",
+		"type DoubleTwice struct",
+		"func NewDoubleTwice(f *goflow.Factory) (*DoubleTwice, error)",
+		"pa *doubler",
+		"g.pa.Out = c0",
+	} {
+		if !strings.Contains(stdout, s) {
+			t.Errorf("Generated code does not contain %q:\n%s", s, stdout)
+		}
+	}
+
+	output := filepath.Join(dir, "graph_gen.go")
+
+	code, _, stderr = executeString([]string{"gen", "-package", "pipeline", "-name", "Pipeline", "-o", output, graph}, "")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The component type is qualified with an alias different from goflow
+	if !strings.Contains(string(data), `goflow1 "github.com/trustmaster/goflow/cmd/goflow"`) || !strings.Contains(string(data), "*goflow1.doubler") {
+		t.Errorf("Unexpected imports:\n%s", data)
+	}
+}
+
+func TestNewComponent(t *testing.T) {
+	dir := t.TempDir()
+
+	code, stdout, stderr := executeString([]string{"new", "component", "WordCount", "-in", "text:string", "--in", "Reset:bool", "--out", "Count:int", "-library", "text", "-dir", dir}, "")
+	if code != 0 {
+		t.Errorf("Exit code %d: %s", code, stderr)
+		return
+	}
+
+	filename := filepath.Join(dir, "word_count.go")
+	if stdout != filename+"\n"+filepath.Join(dir, "word_count_test.go")+"\n" {
+		t.Errorf("Unexpected output %q", stdout)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, s := range []string{
+		"package " + packageName(dir),
+		"Text  <-chan string `description:\"Text inport\"`",
+		"Count chan<- int    `description:\"Count outport\"`",
+		"for c.Text != nil || c.Reset != nil {",
+		"func RegisterWordCount(f *goflow.Factory) error {",
+		`f.Register("text/WordCount"`,
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("Generated code does not contain %q:\n%s", s, data)
+		}
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "word_count_test.go"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !strings.Contains(string(data), "goflowtest.RunCases(t, func() interface{} { return new(WordCount) }") || !strings.Contains(string(data), `"Reset": []bool{},`) {
+		t.Errorf("Unexpected test:\n%s", data)
+	}
+
+	// Existing files are not overwritten
+	if code, _, stderr = executeString([]string{"new", "component", "WordCount", "-dir", dir}, ""); code != 1 || !strings.Contains(stderr, "already exists") {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+
+	if code, _, stderr = executeString([]string{"new", "component", "Bad", "-in", "In", "-dir", dir}, ""); code != 1 || !strings.Contains(stderr, "expected Port:type") {
+		t.Errorf("Exit code %d: %s", code, stderr)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Adder":     "adder",
+		"WordCount": "word_count",
+		"HTTPGet":   "http_get",
+		"ReadCSV":   "read_csv",
+	} {
+		if actual := fileName(name); actual != expected {
+			t.Errorf("%s: %s != %s", name, actual, expected)
+		}
+	}
+}
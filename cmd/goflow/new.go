@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// portSpec is a port of a generated component.
+type portSpec struct {
+	Name string // Field name, e.g. "Sum"
+	Var  string // Local variable name, e.g. "sum"
+	Type string // Element type, e.g. "int"
+}
+
+// componentSpec describes a generated component.
+type componentSpec struct {
+	Package     string
+	Name        string
+	Component   string // Name in the factory registry
+	Description string
+	InPorts     []portSpec
+	OutPorts    []portSpec
+}
+
+func newCommand(args []string, stdio stdio) error {
+	if len(args) == 0 || args[0] != "component" {
+		fmt.Fprint(stdio.err, "Usage: goflow new component Name [-in Port:type] [-out Port:type] [flags]\n")
+		return fmt.Errorf("expected 'component'")
+	}
+
+	var ins, outs listFlag
+
+	fs := flag.NewFlagSet("new component", flag.ContinueOnError)
+	fs.SetOutput(stdio.err)
+	fs.Var(&ins, "in", "inport as Port:type (repeatable)")
+	fs.Var(&outs, "out", "outport as Port:type (repeatable)")
+	pkg := fs.String("package", "", "package name, derived from the directory by default")
+	library := fs.String("library", "", "library namespace of the registered component")
+	description := fs.String("description", "", "description of the component")
+	dir := fs.String("dir", ".", "directory to write the files to")
+
+	names, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if len(names) != 1 {
+		return fmt.Errorf("expected a component name")
+	}
+
+	spec := componentSpec{
+		Package:     *pkg,
+		Name:        typeName(names[0]),
+		Description: *description,
+	}
+
+	spec.Component = spec.Name
+	if *library != "" {
+		spec.Component = *library + "/" + spec.Name
+	}
+
+	if spec.Description == "" {
+		spec.Description = "TODO: describe " + spec.Name
+	}
+
+	if spec.Package == "" {
+		spec.Package = packageName(*dir)
+	}
+
+	if spec.InPorts, err = parsePortSpecs(ins); err != nil {
+		return err
+	}
+
+	if spec.OutPorts, err = parsePortSpecs(outs); err != nil {
+		return err
+	}
+
+	if err := checkPortNames(spec); err != nil {
+		return err
+	}
+
+	base := filepath.Join(*dir, fileName(spec.Name))
+	files := map[string]*template.Template{
+		base + ".go":      componentTemplate,
+		base + "_test.go": componentTestTemplate,
+	}
+
+	for filename := range files {
+		if _, err := os.Stat(filename); err == nil {
+			return fmt.Errorf("file '%s' already exists", filename)
+		}
+	}
+
+	for _, filename := range []string{base + ".go", base + "_test.go"} {
+		src, err := executeTemplate(files[filename], spec)
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(filename, src, 0o644); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stdio.out, filename)
+	}
+
+	return nil
+}
+
+// parseInterspersed parses flags which may follow positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parsePortSpecs parses Port:type flags. Port names are capitalized.
+func parsePortSpecs(flags []string) ([]portSpec, error) {
+	res := make([]portSpec, 0, len(flags))
+
+	for _, s := range flags {
+		colon := strings.Index(s, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid port '%s', expected Port:type", s)
+		}
+
+		name, typ := typeName(s[:colon]), strings.TrimSpace(s[colon+1:])
+
+		if _, err := parser.ParseExpr(typ); err != nil || typ == "" {
+			return nil, fmt.Errorf("invalid type of port '%s': '%s'", name, typ)
+		}
+
+		v := []rune(name)
+		v[0] = unicode.ToLower(v[0])
+
+		p := portSpec{Name: name, Var: string(v), Type: typ}
+		if token.Lookup(p.Var).IsKeyword() || reservedVars[p.Var] {
+			p.Var += "Port"
+		}
+
+		res = append(res, p)
+	}
+
+	return res, nil
+}
+
+// reservedVars are local names used by the generated code.
+var reservedVars = map[string]bool{"c": true, "f": true, "ok": true, "goflow": true}
+
+// checkPortNames makes sure that port names are unique.
+func checkPortNames(spec componentSpec) error {
+	seen := make(map[string]bool)
+
+	for _, p := range append(append([]portSpec{}, spec.InPorts...), spec.OutPorts...) {
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate port '%s'", p.Name)
+		}
+
+		seen[p.Name] = true
+	}
+
+	return nil
+}
+
+// packageName derives a package name from a directory name.
+func packageName(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return -1
+	}, filepath.Base(dir))
+
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return "components"
+	}
+
+	return name
+}
+
+// fileName converts a type name like "WordCount" into a file name like "word_count".
+func fileName(name string) string {
+	var b strings.Builder
+
+	runes := []rune(name)
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func executeTemplate(tpl *template.Template, spec componentSpec) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, spec); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code: %w", err)
+	}
+
+	return src, nil
+}
+
+var componentTemplate = template.Must(template.New("component").Parse(`package {{.Package}}
+
+import "github.com/trustmaster/goflow"
+
+// {{.Name}} is a component. {{.Description}}
+type {{.Name}} struct {
+{{- range .InPorts}}
+	{{.Name}} <-chan {{.Type}} ` + "`" + `description:"{{.Name}} inport"` + "`" + `
+{{- end}}
+{{- range .OutPorts}}
+	{{.Name}} chan<- {{.Type}} ` + "`" + `description:"{{.Name}} outport"` + "`" + `
+{{- end}}
+}
+
+// Process reads the inports until all of them are closed.
+func (c *{{.Name}}) Process() {
+{{- if eq (len .InPorts) 1}}
+{{- with index .InPorts 0}}
+	for {{.Var}} := range c.{{.Name}} {
+		// TODO: process {{.Var}}
+		_ = {{.Var}}
+	}
+{{- end}}
+{{- else if .InPorts}}
+	for {{range $i, $p := .InPorts}}{{if $i}} || {{end}}c.{{$p.Name}} != nil{{end}} {
+		select {
+{{- range .InPorts}}
+		case {{.Var}}, ok := <-c.{{.Name}}:
+			if !ok {
+				c.{{.Name}} = nil
+				break
+			}
+
+			// TODO: process {{.Var}}
+			_ = {{.Var}}
+{{- end}}
+		}
+	}
+{{- else}}
+	// TODO: send packets
+{{- end}}
+}
+
+// Register{{.Name}} registers the {{.Name}} component in a factory.
+func Register{{.Name}}(f *goflow.Factory) error {
+	if err := f.Register("{{.Component}}", func() (interface{}, error) {
+		return new({{.Name}}), nil
+	}); err != nil {
+		return err
+	}
+
+	return f.Annotate("{{.Component}}", goflow.Annotation{
+		Description: {{printf "%q" .Description}},
+	})
+}
+`))
+
+var componentTestTemplate = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import (
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func Test{{.Name}}(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new({{.Name}}) }, []goflowtest.Case{
+		{
+			Name: "empty",
+			In: map[string]interface{}{
+{{- range .InPorts}}
+				"{{.Name}}": []{{.Type}}{},
+{{- end}}
+			},
+			Out: map[string]interface{}{
+{{- range .OutPorts}}
+				"{{.Name}}": []{{.Type}}{},
+{{- end}}
+			},
+		},
+		// TODO: add test cases
+	})
+}
+`))
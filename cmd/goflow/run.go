@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/trustmaster/goflow"
+)
+
+// network is a graph or a graph watcher.
+type network interface {
+	goflow.Component
+	SetInPort(name string, channel interface{}) error
+	SetOutPort(name string, channel interface{}) error
+}
+
+func runCommand(args []string, stdio stdio) error {
+	var libs, ins, outs listFlag
+
+	fs := newFlagSet("run", stdio, &libs)
+	fs.Var(&ins, "in", "read a graph inport from a file, Port=path where '-' is stdin (repeatable)")
+	fs.Var(&outs, "out", "write a graph outport to a file, Port=path where '-' is stdout (repeatable)")
+	watch := fs.Duration("watch", 0, "reload the graph when the file changes, checking it with a given interval")
+
+	n, f, err := loadGraph(fs, args, &libs)
+	if err != nil {
+		return err
+	}
+
+	inFiles, err := parsePortFiles(ins, n.InPortNames())
+	if err != nil {
+		return err
+	}
+
+	outFiles, err := parsePortFiles(outs, n.OutPortNames())
+	if err != nil {
+		return err
+	}
+
+	var net network = n
+
+	if *watch > 0 {
+		w, err := goflow.NewGraphWatcher(fs.Arg(0), f, *watch)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			for e := range w.Events() {
+				if e.Err != nil {
+					fmt.Fprintf(stdio.err, "goflow run: %s\n", e.Err)
+				} else {
+					fmt.Fprintf(stdio.err, "goflow run: reloaded %s, %d changes, restarted: %t\n", e.File, len(e.Patch), e.Restarted)
+				}
+			}
+		}()
+
+		// Reloaded graphs get the parameters too
+		if err := w.SetBuildFunc(func(g *goflow.Graph) error { return setParams(fs, g) }); err != nil {
+			return err
+		}
+
+		net, n = w, w.Graph()
+	}
+
+	written := new(sync.WaitGroup)
+
+	for _, name := range n.OutPortNames() {
+		if err := wireOutPort(net, n, name, outFiles[name], stdio, written); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range n.InPortNames() {
+		if err := wireInPort(net, n, name, inFiles[name], stdio); err != nil {
+			return err
+		}
+	}
+
+	<-goflow.Run(net)
+	written.Wait()
+
+	if w, ok := net.(*goflow.GraphWatcher); ok {
+		n = w.Graph()
+	}
+
+	return n.ProcessError()
+}
+
+// parsePortFiles parses Port=path flags. A graph with a single port uses
+// the standard stream by default.
+func parsePortFiles(flags []string, ports []string) (map[string]string, error) {
+	files := make(map[string]string)
+
+	for _, s := range flags {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid port file '%s', expected Port=path", s)
+		}
+
+		files[s[:eq]] = s[eq+1:]
+	}
+
+	if len(flags) == 0 && len(ports) == 1 {
+		files[ports[0]] = "-"
+	}
+
+	res := make(map[string]string, len(files))
+
+	for name, path := range files {
+		found := false
+
+		for _, p := range ports {
+			if strings.EqualFold(p, name) {
+				res[p] = path
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("graph has no port '%s'", name)
+		}
+	}
+
+	return res, nil
+}
+
+// wireInPort feeds a graph inport with lines of a file. Inports without a file are closed.
+func wireInPort(net network, n *goflow.Graph, name, path string, stdio stdio) error {
+	t, err := n.InPortType(name)
+	if err != nil {
+		return err
+	}
+
+	ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t), 0)
+	if err := net.SetInPort(name, ch.Interface()); err != nil {
+		return err
+	}
+
+	if path == "" {
+		ch.Close()
+		return nil
+	}
+
+	r := ioutil.NopCloser(stdio.in)
+
+	if path != "-" {
+		if r, err = os.Open(path); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		defer r.Close()
+		defer ch.Close()
+
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for line := 1; s.Scan(); line++ {
+			v, err := decodePacket(s.Text(), t)
+			if err != nil {
+				fmt.Fprintf(stdio.err, "goflow run: %s: line %d: %s\n", name, line, err)
+				continue
+			}
+
+			ch.Send(v)
+		}
+
+		if err := s.Err(); err != nil {
+			fmt.Fprintf(stdio.err, "goflow run: %s: %s\n", name, err)
+		}
+	}()
+
+	return nil
+}
+
+// wireOutPort writes packets of a graph outport to a file line by line.
+// Outports without a file are drained.
+func wireOutPort(net network, n *goflow.Graph, name, path string, stdio stdio, written *sync.WaitGroup) error {
+	t, err := n.OutPortType(name)
+	if err != nil {
+		return err
+	}
+
+	ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t), 0)
+	if err := net.SetOutPort(name, ch.Interface()); err != nil {
+		return err
+	}
+
+	var w io.WriteCloser
+
+	switch path {
+	case "":
+		w = nopWriteCloser{ioutil.Discard}
+	case "-":
+		w = nopWriteCloser{stdio.out}
+	default:
+		if w, err = os.Create(path); err != nil {
+			return err
+		}
+	}
+
+	written.Add(1)
+
+	go func() {
+		defer written.Done()
+		defer w.Close()
+
+		for {
+			v, ok := ch.Recv()
+			if !ok {
+				return
+			}
+
+			if _, err := fmt.Fprintln(w, encodePacket(v)); err != nil {
+				fmt.Fprintf(stdio.err, "goflow run: %s: %s\n", name, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// decodePacket converts a line of text into a packet. Strings are taken as is,
+// other types are decoded from JSON.
+func decodePacket(line string, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(line).Convert(t), nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return reflect.ValueOf([]byte(line)).Convert(t), nil
+	}
+
+	v := reflect.New(t)
+	if err := json.Unmarshal([]byte(line), v.Interface()); err != nil {
+		return v, err
+	}
+
+	return v.Elem(), nil
+}
+
+// encodePacket converts a packet into a line of text.
+func encodePacket(v reflect.Value) string {
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return string(v.Bytes())
+	}
+
+	js, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+
+	return string(js)
+}
@@ -0,0 +1,329 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Bracket packets delimit substreams. Aggregating components treat a CloseBracket
+// like the end of the stream: they send the results collected so far and start
+// over. Brackets are not forwarded by them.
+type Bracket int
+
+// Brackets around a substream.
+const (
+	OpenBracket Bracket = iota + 1
+	CloseBracket
+)
+
+// ReduceFunc combines an accumulated value with a packet.
+type ReduceFunc func(acc, packet interface{}) interface{}
+
+// LessFunc tells if a packet must be sorted before another.
+type LessFunc func(a, b interface{}) bool
+
+// Group is a list of packets with the same key collected by GroupBy.
+type Group struct {
+	Key     string
+	Packets []interface{}
+}
+
+// keyOf receives a key function from an optional port.
+func keyOf(ch <-chan KeyFunc) KeyFunc {
+	if ch == nil {
+		return nil
+	}
+
+	return <-ch
+}
+
+// reduceOf receives a reduce function from an optional port.
+func reduceOf(ch <-chan ReduceFunc) ReduceFunc {
+	if ch == nil {
+		return nil
+	}
+
+	return <-ch
+}
+
+// lessOf receives a comparison function from an optional port.
+func lessOf(ch <-chan LessFunc) LessFunc {
+	if ch == nil {
+		return nil
+	}
+
+	return <-ch
+}
+
+// limitOf receives a limit from an optional port, 0 means no limit.
+func limitOf(ch <-chan int) int {
+	if ch == nil {
+		return 0
+	}
+
+	return <-ch
+}
+
+// keyFor returns the key of a packet. Without a key function all packets
+// have the same key.
+func keyFor(key KeyFunc, p interface{}) string {
+	if key == nil {
+		return ""
+	}
+
+	return key(p)
+}
+
+// GroupBy collects packets into groups by the key returned by the Key function
+// and sends the groups to Out in order of their first packet when In is closed
+// or a CloseBracket arrives. A group is sent early when it has Limit packets,
+// which is an optional IIP. Without Key all packets are in one group.
+type GroupBy struct {
+	Key   <-chan KeyFunc `required:"false"`
+	Limit <-chan int     `required:"false"`
+	In    <-chan interface{}
+	Out   chan<- Group
+}
+
+// Process groups packets until In is closed.
+func (c *GroupBy) Process() {
+	key := keyOf(c.Key)
+	limit := limitOf(c.Limit)
+	groups := make(map[string]*Group)
+
+	var order []*Group
+
+	flush := func() {
+		for _, g := range order {
+			if len(g.Packets) > 0 {
+				c.Out <- *g
+			}
+		}
+
+		groups = make(map[string]*Group)
+		order = nil
+	}
+
+	for p := range c.In {
+		switch p {
+		case OpenBracket:
+			continue
+		case CloseBracket:
+			flush()
+			continue
+		}
+
+		k := keyFor(key, p)
+
+		g, ok := groups[k]
+		if !ok {
+			g = &Group{Key: k}
+			groups[k] = g
+			order = append(order, g)
+		}
+
+		g.Packets = append(g.Packets, p)
+
+		if limit > 0 && len(g.Packets) >= limit {
+			c.Out <- *g
+			g.Packets = nil
+		}
+	}
+
+	flush()
+}
+
+// Reduce combines all packets with the Fn function and sends the result when
+// In is closed or a CloseBracket arrives. The accumulated value starts with the
+// value received on the optional Init port, without it the first packet is used.
+// Nothing is sent for an empty stream without Init. Without Fn the last packet is sent.
+type Reduce struct {
+	Fn   <-chan ReduceFunc  `required:"false"`
+	Init <-chan interface{} `required:"false"`
+	In   <-chan interface{}
+	Out  chan<- interface{}
+}
+
+// Process reduces packets until In is closed.
+func (c *Reduce) Process() {
+	fn := reduceOf(c.Fn)
+
+	var init interface{}
+
+	hasInit := false
+	if c.Init != nil {
+		init, hasInit = <-c.Init
+	}
+
+	acc, ok := init, hasInit
+
+	flush := func() {
+		if ok {
+			c.Out <- acc
+		}
+
+		acc, ok = init, hasInit
+	}
+
+	for p := range c.In {
+		switch {
+		case p == OpenBracket:
+		case p == CloseBracket:
+			flush()
+		case !ok:
+			acc, ok = p, true
+		case fn != nil:
+			acc = fn(acc, p)
+		default:
+			acc = p
+		}
+	}
+
+	flush()
+}
+
+// Count sends the number of packets when In is closed or a CloseBracket arrives.
+type Count struct {
+	In  <-chan interface{}
+	Out chan<- int
+}
+
+// Process counts packets until In is closed.
+func (c *Count) Process() {
+	count := 0
+
+	for p := range c.In {
+		switch p {
+		case OpenBracket:
+		case CloseBracket:
+			c.Out <- count
+			count = 0
+		default:
+			count++
+		}
+	}
+
+	c.Out <- count
+}
+
+// Distinct passes only the first packet with every key. Keys are returned by the
+// optional Key function, by default packets are compared by their Go syntax
+// representation. With the optional Limit only that many recent keys are
+// remembered. Brackets are forwarded and a CloseBracket forgets all keys.
+type Distinct struct {
+	Key   <-chan KeyFunc `required:"false"`
+	Limit <-chan int     `required:"false"`
+	In    <-chan interface{}
+	Out   chan<- interface{}
+}
+
+// Process filters packets until In is closed.
+func (c *Distinct) Process() {
+	key := keyOf(c.Key)
+	if key == nil {
+		key = func(p interface{}) string { return fmt.Sprintf("%#v", p) }
+	}
+
+	limit := limitOf(c.Limit)
+	seen := make(map[string]bool)
+
+	var recent []string
+
+	for p := range c.In {
+		switch p {
+		case OpenBracket:
+			c.Out <- p
+			continue
+		case CloseBracket:
+			seen = make(map[string]bool)
+			recent = nil
+
+			c.Out <- p
+
+			continue
+		}
+
+		k := key(p)
+		if seen[k] {
+			continue
+		}
+
+		seen[k] = true
+
+		if limit > 0 {
+			recent = append(recent, k)
+			if len(recent) > limit {
+				delete(seen, recent[0])
+				recent = recent[1:]
+			}
+		}
+
+		c.Out <- p
+	}
+}
+
+// Sort collects packets until In is closed or a CloseBracket arrives and sends
+// them sorted by the Less function. The sort is stable. With the optional Limit
+// only that many first packets of the sorted order are kept and sent. Without
+// Less the packets keep their order.
+type Sort struct {
+	Less  <-chan LessFunc `required:"false"`
+	Limit <-chan int      `required:"false"`
+	In    <-chan interface{}
+	Out   chan<- interface{}
+}
+
+// Process sorts packets until In is closed.
+func (c *Sort) Process() {
+	less := lessOf(c.Less)
+	limit := limitOf(c.Limit)
+
+	var buf []interface{}
+
+	flush := func() {
+		if limit <= 0 && less != nil {
+			sort.SliceStable(buf, func(i, j int) bool { return less(buf[i], buf[j]) })
+		}
+
+		for _, p := range buf {
+			c.Out <- p
+		}
+
+		buf = nil
+	}
+
+	for p := range c.In {
+		switch p {
+		case OpenBracket:
+			continue
+		case CloseBracket:
+			flush()
+			continue
+		}
+
+		if limit <= 0 {
+			buf = append(buf, p)
+			continue
+		}
+
+		// Keep the buffer sorted inserting after equal packets
+		i := len(buf)
+		if less != nil {
+			i = sort.Search(len(buf), func(i int) bool { return less(p, buf[i]) })
+		}
+
+		if i >= limit {
+			continue
+		}
+
+		buf = append(buf, nil)
+		copy(buf[i+1:], buf[i:])
+		buf[i] = p
+
+		if len(buf) > limit {
+			buf = buf[:limit]
+		}
+	}
+
+	flush()
+}
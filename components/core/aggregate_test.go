@@ -0,0 +1,151 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestGroupBy(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(GroupBy) }, []goflowtest.Case{
+		{
+			Name: "brackets",
+			In: map[string]interface{}{
+				"Key": []KeyFunc{prefix},
+				"In":  []interface{}{OpenBracket, "a:1", "b:1", "a:2", CloseBracket, "b:2", "c:1"},
+			},
+			Out: map[string]interface{}{"Out": []Group{
+				{Key: "a", Packets: []interface{}{"a:1", "a:2"}},
+				{Key: "b", Packets: []interface{}{"b:1"}},
+				{Key: "b", Packets: []interface{}{"b:2"}},
+				{Key: "c", Packets: []interface{}{"c:1"}},
+			}},
+		},
+		{
+			Name: "limit",
+			In: map[string]interface{}{
+				"Key":   []KeyFunc{prefix},
+				"Limit": []int{2},
+				"In":    []interface{}{"a:1", "a:2", "a:3"},
+			},
+			Out: map[string]interface{}{"Out": []Group{
+				{Key: "a", Packets: []interface{}{"a:1", "a:2"}},
+				{Key: "a", Packets: []interface{}{"a:3"}},
+			}},
+		},
+		{
+			Name: "unconnected key",
+			In:   map[string]interface{}{"In": []interface{}{"a:1", "b:1"}},
+			Out: map[string]interface{}{"Out": []Group{
+				{Key: "", Packets: []interface{}{"a:1", "b:1"}},
+			}},
+		},
+	})
+}
+
+func TestReduce(t *testing.T) {
+	sum := ReduceFunc(func(acc, p interface{}) interface{} { return acc.(int) + p.(int) })
+
+	goflowtest.RunCases(t, func() interface{} { return new(Reduce) }, []goflowtest.Case{
+		{
+			Name: "sum",
+			In: map[string]interface{}{
+				"Fn": []ReduceFunc{sum},
+				"In": []interface{}{1, 2, 3, CloseBracket, 4},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{6, 4}},
+		},
+		{
+			Name: "fold",
+			In: map[string]interface{}{
+				"Fn":   []ReduceFunc{sum},
+				"Init": []interface{}{10},
+				"In":   []interface{}{1, CloseBracket},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{11, 10}},
+		},
+		{
+			Name: "empty",
+			In: map[string]interface{}{
+				"Fn": []ReduceFunc{sum},
+				"In": []interface{}{},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{}},
+		},
+		{
+			Name: "unconnected function",
+			In:   map[string]interface{}{"In": []interface{}{1, 2, CloseBracket}},
+			Out:  map[string]interface{}{"Out": []interface{}{2}},
+		},
+	})
+}
+
+func TestCount(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Count) }, []goflowtest.Case{
+		{
+			Name: "brackets",
+			In:   map[string]interface{}{"In": []interface{}{OpenBracket, "a", "b", CloseBracket, "c"}},
+			Out:  map[string]interface{}{"Out": []int{2, 1}},
+		},
+		{
+			Name: "empty",
+			In:   map[string]interface{}{"In": []interface{}{}},
+			Out:  map[string]interface{}{"Out": []int{0}},
+		},
+	})
+}
+
+func TestDistinct(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Distinct) }, []goflowtest.Case{
+		{
+			Name: "values",
+			In:   map[string]interface{}{"In": []interface{}{1, "1", 1, 2, CloseBracket, 1}},
+			Out:  map[string]interface{}{"Out": []interface{}{1, "1", 2, CloseBracket, 1}},
+		},
+		{
+			Name: "keys",
+			In: map[string]interface{}{
+				"Key": []KeyFunc{prefix},
+				"In":  []interface{}{"a:1", "a:2", "b:1"},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"a:1", "b:1"}},
+		},
+		{
+			Name: "limit",
+			In: map[string]interface{}{
+				"Limit": []int{1},
+				"In":    []interface{}{1, 1, 2, 1},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{1, 2, 1}},
+		},
+	})
+}
+
+func TestSort(t *testing.T) {
+	byPrefix := LessFunc(func(a, b interface{}) bool { return prefix(a) < prefix(b) })
+
+	goflowtest.RunCases(t, func() interface{} { return new(Sort) }, []goflowtest.Case{
+		{
+			Name: "stable",
+			In: map[string]interface{}{
+				"Less": []LessFunc{byPrefix},
+				"In":   []interface{}{"2:a", "1:a", "2:b", CloseBracket, "0:a"},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"1:a", "2:a", "2:b", "0:a"}},
+		},
+		{
+			Name: "limit",
+			In: map[string]interface{}{
+				"Less":  []LessFunc{byPrefix},
+				"Limit": []int{2},
+				"In":    []interface{}{"3:a", "2:a", "1:a", "2:b"},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"1:a", "2:a"}},
+		},
+		{
+			Name: "unconnected less",
+			In:   map[string]interface{}{"In": []interface{}{"2:a", "1:a"}},
+			Out:  map[string]interface{}{"Out": []interface{}{"2:a", "1:a"}},
+		},
+	})
+}
@@ -0,0 +1,71 @@
+// Package core provides general purpose components which route and transform
+// streams of packets of any type. Packets are passed as interface{}, so the
+// components connect to ports of interface{} type.
+//
+// Functions used by Filter, Map, Router and the aggregating components are
+// sent to them as IIPs, e.g.
+//
+//	n.AddIIP("double", "Fn", core.MapFunc(func(p interface{}) interface{} {
+//		return p.(int) * 2
+//	}))
+package core
+
+import "github.com/trustmaster/goflow"
+
+// Library is the namespace of the components in a factory.
+const Library = "core"
+
+// components lists the components of the package with their annotations.
+var components = []struct {
+	name        string
+	constructor goflow.Constructor
+	annotation  goflow.Annotation
+}{
+	{"Split", func() (interface{}, error) { return new(Split), nil },
+		goflow.Annotation{Description: "Sends a copy of every packet to all elements of an array outport", Icon: "code-fork"}},
+	{"Merge", func() (interface{}, error) { return new(Merge), nil },
+		goflow.Annotation{Description: "Merges packets from all elements of an array inport", Icon: "compress"}},
+	{"RoundRobin", func() (interface{}, error) { return new(RoundRobin), nil },
+		goflow.Annotation{Description: "Distributes packets between elements of an array outport in turn", Icon: "refresh"}},
+	{"Router", func() (interface{}, error) { return new(Router), nil },
+		goflow.Annotation{Description: "Routes packets to map outports by a key function", Icon: "random"}},
+	{"Filter", func() (interface{}, error) { return new(Filter), nil },
+		goflow.Annotation{Description: "Passes only packets matching a predicate", Icon: "filter"}},
+	{"Map", func() (interface{}, error) { return new(Map), nil },
+		goflow.Annotation{Description: "Transforms packets with a function", Icon: "exchange"}},
+	{"Drop", func() (interface{}, error) { return new(Drop), nil },
+		goflow.Annotation{Description: "Discards all packets", Icon: "trash"}},
+	{"Repeat", func() (interface{}, error) { return new(Repeat), nil },
+		goflow.Annotation{Description: "Sends every packet a given number of times", Icon: "repeat"}},
+	{"Kick", func() (interface{}, error) { return new(Kick), nil },
+		goflow.Annotation{Description: "Sends the Data packet whenever a packet arrives", Icon: "share"}},
+	{"Join", func() (interface{}, error) { return new(Join), nil },
+		goflow.Annotation{Description: "Pairs packets from two inports by their keys", Icon: "chain"}},
+	{"GroupBy", func() (interface{}, error) { return new(GroupBy), nil },
+		goflow.Annotation{Description: "Collects packets into groups by their keys", Icon: "object-group"}},
+	{"Reduce", func() (interface{}, error) { return new(Reduce), nil },
+		goflow.Annotation{Description: "Combines all packets into a single value", Icon: "calculator"}},
+	{"Count", func() (interface{}, error) { return new(Count), nil },
+		goflow.Annotation{Description: "Counts packets", Icon: "list-ol"}},
+	{"Distinct", func() (interface{}, error) { return new(Distinct), nil },
+		goflow.Annotation{Description: "Drops packets which have been seen before", Icon: "snowflake-o"}},
+	{"Sort", func() (interface{}, error) { return new(Sort), nil },
+		goflow.Annotation{Description: "Sends all packets sorted once the stream ends", Icon: "sort"}},
+}
+
+// Register registers the components in a factory as "core/Split", "core/Merge", etc.
+func Register(f *goflow.Factory) error {
+	for _, c := range components {
+		name := Library + "/" + c.name
+
+		if err := f.Register(name, c.constructor); err != nil {
+			return err
+		}
+
+		if err := f.Annotate(name, c.annotation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/trustmaster/goflow"
+)
+
+func TestRegister(t *testing.T) {
+	f := goflow.NewFactory()
+
+	if err := Register(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	list := f.List("core/")
+	if len(list) != len(components) {
+		t.Errorf("%d != %d", len(list), len(components))
+	}
+
+	for _, info := range list {
+		if info.Description == "" {
+			t.Errorf("Component %s is not annotated", info.Name)
+		}
+	}
+
+	// Components work in graphs loaded from files
+	n, err := goflow.ParseFBP([]byte(`
+INPORT=split.IN:IN
+OUTPORT=merge.OUT:OUT
+split(core/Split) OUT[0] -> IN[0] merge(core/Merge)
+split OUT[1] -> IN r(core/Repeat) OUT -> IN[1] merge
+'2' -> TIMES r
+`), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan interface{})
+	out := make(chan interface{})
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := goflow.Run(n)
+
+	go func() {
+		in <- "x"
+		close(in)
+	}()
+
+	count := 0
+	for range out {
+		count++
+	}
+
+	<-wait
+
+	if count != 3 {
+		t.Errorf("%d != 3", count)
+	}
+}
+
+func TestValidateOptionalPorts(t *testing.T) {
+	f := goflow.NewFactory()
+
+	if err := Register(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Function and limit ports which are not connected fall back to defaults
+	n, err := goflow.ParseFBP([]byte(`
+INPORT=f.IN:IN
+OUTPORT=s.OUT:OUT
+f(core/Filter) OUT -> IN m(core/Map) OUT -> IN d(core/Distinct) OUT -> IN s(core/Sort)
+`), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Validate(); err != nil {
+		t.Error(err)
+	}
+}
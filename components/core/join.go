@@ -0,0 +1,184 @@
+package core
+
+import "sort"
+
+// Pair is a packet from Left joined with a packet from Right by Join.
+type Pair struct {
+	Key   string
+	Left  interface{}
+	Right interface{}
+}
+
+// Join correlates packets from Left and Right which have the same key. Keys are
+// extracted by the LeftKey and RightKey functions, so the inports may carry
+// different kinds of packets. Every packet is joined with the earliest waiting
+// packet of the other side which has the same key, the pair is sent to Out.
+// Without a key function all packets of a side have the same key, so packets
+// are paired in order of arrival.
+//
+// Packets which cannot be joined are sent to Unmatched if it is connected:
+// when the other side is closed and nothing of the same key is waiting, when
+// Limit packets of a side are waiting and a new one arrives (the oldest one is
+// evicted), and the packets still waiting when both inports are closed.
+// Key functions and Limit are optional IIPs.
+type Join struct {
+	LeftKey   <-chan KeyFunc `required:"false"`
+	RightKey  <-chan KeyFunc `required:"false"`
+	Limit     <-chan int     `required:"false"`
+	Left      <-chan interface{}
+	Right     <-chan interface{}
+	Out       chan<- Pair
+	Unmatched chan<- interface{} `required:"false"`
+}
+
+// Process joins packets until both Left and Right are closed.
+func (c *Join) Process() {
+	leftKey, rightKey := keyOf(c.LeftKey), keyOf(c.RightKey)
+	limit := limitOf(c.Limit)
+	left, right := newJoinSide(limit), newJoinSide(limit)
+	leftIn, rightIn := c.Left, c.Right
+
+	for leftIn != nil || rightIn != nil {
+		select {
+		case p, ok := <-leftIn:
+			if !ok {
+				leftIn = nil
+				c.unmatched(right.flushAll()...)
+
+				break
+			}
+
+			k := keyFor(leftKey, p)
+			if r, ok := right.take(k); ok {
+				c.Out <- Pair{Key: k, Left: p, Right: r}
+			} else if rightIn == nil {
+				c.unmatched(p)
+			} else {
+				c.unmatched(left.add(k, p)...)
+			}
+		case p, ok := <-rightIn:
+			if !ok {
+				rightIn = nil
+				c.unmatched(left.flushAll()...)
+
+				break
+			}
+
+			k := keyFor(rightKey, p)
+			if l, ok := left.take(k); ok {
+				c.Out <- Pair{Key: k, Left: l, Right: p}
+			} else if leftIn == nil {
+				c.unmatched(p)
+			} else {
+				c.unmatched(right.add(k, p)...)
+			}
+		}
+	}
+}
+
+func (c *Join) unmatched(packets ...interface{}) {
+	if c.Unmatched == nil {
+		return
+	}
+
+	for _, p := range packets {
+		c.Unmatched <- p
+	}
+}
+
+// joinSide holds the packets of a Join side waiting for a pair.
+type joinSide struct {
+	limit   int
+	count   int
+	pending map[string][]interface{}
+	order   []string       // Keys in order of arrival, kept only with a limit
+	stale   map[string]int // Number of keys in order which have been taken already
+}
+
+func newJoinSide(limit int) *joinSide {
+	return &joinSide{
+		limit:   limit,
+		pending: make(map[string][]interface{}),
+		stale:   make(map[string]int),
+	}
+}
+
+// add adds a waiting packet and returns the evicted one if the limit is exceeded.
+func (s *joinSide) add(key string, p interface{}) []interface{} {
+	s.pending[key] = append(s.pending[key], p)
+	s.count++
+
+	if s.limit <= 0 {
+		return nil
+	}
+
+	s.order = append(s.order, key)
+
+	if s.count <= s.limit {
+		return nil
+	}
+
+	for {
+		k := s.order[0]
+		s.order = s.order[1:]
+
+		if s.stale[k] > 0 {
+			s.stale[k]--
+			continue
+		}
+
+		p, _ := s.pop(k)
+
+		return []interface{}{p}
+	}
+}
+
+// take removes the earliest packet with a key.
+func (s *joinSide) take(key string) (interface{}, bool) {
+	p, ok := s.pop(key)
+	if ok && s.limit > 0 {
+		s.stale[key]++
+	}
+
+	return p, ok
+}
+
+func (s *joinSide) pop(key string) (interface{}, bool) {
+	queue := s.pending[key]
+	if len(queue) == 0 {
+		return nil, false
+	}
+
+	p := queue[0]
+	if len(queue) == 1 {
+		delete(s.pending, key)
+	} else {
+		s.pending[key] = queue[1:]
+	}
+
+	s.count--
+
+	return p, true
+}
+
+// flushAll removes all waiting packets and returns them ordered by key.
+func (s *joinSide) flushAll() []interface{} {
+	keys := make([]string, 0, len(s.pending))
+	for k := range s.pending {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var res []interface{}
+	for _, k := range keys {
+		res = append(res, s.pending[k]...)
+	}
+
+	s.pending = make(map[string][]interface{})
+	s.order = nil
+	s.stale = make(map[string]int)
+	s.count = 0
+
+	return res
+}
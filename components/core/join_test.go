@@ -0,0 +1,93 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+// prefix returns the part of a string packet before a colon.
+func prefix(p interface{}) string {
+	return strings.SplitN(p.(string), ":", 2)[0]
+}
+
+func TestJoin(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Join) }, []goflowtest.Case{
+		{
+			Name: "keyed",
+			In: map[string]interface{}{
+				"LeftKey":  []KeyFunc{prefix},
+				"RightKey": []KeyFunc{func(p interface{}) string { return p.(Pair).Key }},
+				"Left":     []interface{}{"a:1", "b:1", "a:2"},
+				"Right":    []interface{}{Pair{Key: "a", Right: 1}, Pair{Key: "a", Right: 2}, Pair{Key: "c"}},
+			},
+			Out: map[string]interface{}{
+				"Out": []Pair{
+					{Key: "a", Left: "a:1", Right: Pair{Key: "a", Right: 1}},
+					{Key: "a", Left: "a:2", Right: Pair{Key: "a", Right: 2}},
+				},
+				"Unmatched": []interface{}{"b:1", Pair{Key: "c"}},
+			},
+			Unordered: true,
+		},
+		{
+			Name: "in order",
+			In: map[string]interface{}{
+				"LeftKey":  []KeyFunc{},
+				"RightKey": []KeyFunc{},
+				"Left":     []interface{}{1, 2},
+				"Right":    []interface{}{"x", "y", "z"},
+			},
+			Out: map[string]interface{}{
+				"Out":       []Pair{{Left: 1, Right: "x"}, {Left: 2, Right: "y"}},
+				"Unmatched": []interface{}{"z"},
+			},
+		},
+	})
+}
+
+func TestJoinLimit(t *testing.T) {
+	limit := make(chan int, 1)
+	key := make(chan KeyFunc, 2)
+	left, right := make(chan interface{}), make(chan interface{})
+	out := make(chan Pair)
+	unmatched := make(chan interface{})
+
+	limit <- 1
+	key <- prefix
+	key <- prefix
+
+	c := &Join{LeftKey: key, RightKey: key, Limit: limit, Left: left, Right: right, Out: out, Unmatched: unmatched}
+	done := make(chan struct{})
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	// The oldest waiting packet is evicted
+	left <- "a:1"
+	left <- "b:1"
+
+	if p := <-unmatched; p != "a:1" {
+		t.Errorf("%v != a:1", p)
+	}
+
+	right <- "b:2"
+
+	if p := <-out; p != (Pair{Key: "b", Left: "b:1", Right: "b:2"}) {
+		t.Errorf("Unexpected pair %v", p)
+	}
+
+	// Nothing can be joined after the other side is closed
+	close(left)
+	right <- "c:1"
+
+	if p := <-unmatched; p != "c:1" {
+		t.Errorf("%v != c:1", p)
+	}
+
+	close(right)
+	<-done
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultKey is the outport key Router uses for packets which have no outport of their own.
+const DefaultKey = "default"
+
+// Split sends a copy of every packet to all elements of the Out array port.
+type Split struct {
+	In  <-chan interface{}
+	Out []chan<- interface{}
+}
+
+// Process broadcasts packets until In is closed.
+func (c *Split) Process() {
+	for p := range c.In {
+		for _, out := range c.Out {
+			if out != nil {
+				out <- p
+			}
+		}
+	}
+
+	closeAll(c.Out)
+}
+
+// Merge sends packets from all elements of the In array port to Out in the order they arrive.
+type Merge struct {
+	In  []<-chan interface{}
+	Out chan<- interface{}
+}
+
+// Process merges packets until all inports are closed.
+func (c *Merge) Process() {
+	wg := new(sync.WaitGroup)
+
+	for _, in := range c.In {
+		if in == nil {
+			continue
+		}
+
+		in := in
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for p := range in {
+				c.Out <- p
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// RoundRobin distributes packets between the elements of the Out array port in turn.
+type RoundRobin struct {
+	In  <-chan interface{}
+	Out []chan<- interface{}
+}
+
+// Process distributes packets until In is closed.
+func (c *RoundRobin) Process() {
+	i := 0
+
+	for p := range c.In {
+		for j := 0; j < len(c.Out); j++ {
+			out := c.Out[(i+j)%len(c.Out)]
+			if out != nil {
+				out <- p
+				i += j
+
+				break
+			}
+		}
+
+		i++
+	}
+
+	closeAll(c.Out)
+}
+
+// KeyFunc returns a routing key of a packet.
+type KeyFunc func(packet interface{}) string
+
+// Predicate converts a predicate into a KeyFunc which routes packets to
+// the "true" and "false" outports.
+func Predicate(fn PredicateFunc) KeyFunc {
+	return func(p interface{}) string {
+		return fmt.Sprint(fn(p))
+	}
+}
+
+// Router sends packets to the Out map port by the key returned by the Key function.
+// Packets with keys which have no outport are sent to Out[default] if it exists
+// or dropped otherwise. Key is usually set with an IIP, without it all packets go
+// to the default outport.
+type Router struct {
+	Key <-chan KeyFunc `required:"false"`
+	In  <-chan interface{}
+	Out map[string]chan<- interface{}
+}
+
+// Process routes packets until In is closed.
+func (c *Router) Process() {
+	key := keyOf(c.Key)
+
+	for p := range c.In {
+		k := DefaultKey
+		if key != nil {
+			k = key(p)
+		}
+
+		out, ok := c.Out[k]
+		if !ok {
+			out = c.Out[DefaultKey]
+		}
+
+		if out != nil {
+			out <- p
+		}
+	}
+
+	for _, out := range c.Out {
+		if out != nil {
+			close(out)
+		}
+	}
+}
+
+func closeAll(outs []chan<- interface{}) {
+	for _, out := range outs {
+		if out != nil {
+			close(out)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestSplit(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Split) }, []goflowtest.Case{
+		{
+			Name: "broadcast",
+			In:   map[string]interface{}{"In": []interface{}{1, "two", 3.0}},
+			Out: map[string]interface{}{
+				"Out[0]": []interface{}{1, "two", 3.0},
+				"Out[1]": []interface{}{1, "two", 3.0},
+			},
+		},
+		{
+			Name: "empty",
+			In:   map[string]interface{}{"In": []interface{}{}},
+			Out:  map[string]interface{}{"Out[0]": []interface{}{}},
+		},
+	})
+}
+
+func TestMerge(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Merge) }, []goflowtest.Case{
+		{
+			Name: "merge",
+			In: map[string]interface{}{
+				"In[0]": []interface{}{1, 2},
+				"In[1]": []interface{}{3},
+				"In[2]": []interface{}{4, 5},
+			},
+			Out:       map[string]interface{}{"Out": []interface{}{1, 2, 3, 4, 5}},
+			Unordered: true,
+		},
+	})
+}
+
+func TestRoundRobin(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(RoundRobin) }, []goflowtest.Case{
+		{
+			Name: "three",
+			In:   map[string]interface{}{"In": []interface{}{1, 2, 3, 4, 5, 6, 7}},
+			Out: map[string]interface{}{
+				"Out[0]": []interface{}{1, 4, 7},
+				"Out[1]": []interface{}{2, 5},
+				"Out[2]": []interface{}{3, 6},
+			},
+		},
+	})
+}
+
+func TestRouter(t *testing.T) {
+	parity := func(p interface{}) string {
+		if p.(int)%2 == 0 {
+			return "even"
+		}
+
+		return "odd"
+	}
+
+	goflowtest.RunCases(t, func() interface{} { return new(Router) }, []goflowtest.Case{
+		{
+			Name: "key",
+			In: map[string]interface{}{
+				"Key": []interface{}{KeyFunc(parity)},
+				"In":  []interface{}{1, 2, 3, 4},
+			},
+			Out: map[string]interface{}{
+				"Out[even]": []interface{}{2, 4},
+				"Out[odd]":  []interface{}{1, 3},
+			},
+		},
+		{
+			Name: "default",
+			In: map[string]interface{}{
+				"Key": []interface{}{KeyFunc(parity)},
+				"In":  []interface{}{1, 2, 3},
+			},
+			Out: map[string]interface{}{
+				"Out[even]":    []interface{}{2},
+				"Out[default]": []interface{}{1, 3},
+			},
+		},
+		{
+			Name: "predicate",
+			In: map[string]interface{}{
+				"Key": []interface{}{Predicate(func(p interface{}) bool { return p.(int) > 2 })},
+				"In":  []interface{}{1, 2, 3, 4},
+			},
+			Out: map[string]interface{}{
+				"Out[true]":  []interface{}{3, 4},
+				"Out[false]": []interface{}{1, 2},
+			},
+		},
+		{
+			Name: "without key",
+			In: map[string]interface{}{
+				"Key": []KeyFunc{},
+				"In":  []interface{}{1, 2},
+			},
+			Out: map[string]interface{}{"Out[default]": []interface{}{1, 2}},
+		},
+		{
+			Name: "unconnected key",
+			In:   map[string]interface{}{"In": []interface{}{1, 2}},
+			Out:  map[string]interface{}{"Out[default]": []interface{}{1, 2}},
+		},
+	})
+}
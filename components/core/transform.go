@@ -0,0 +1,128 @@
+package core
+
+// PredicateFunc tells if a packet matches a condition.
+type PredicateFunc func(packet interface{}) bool
+
+// MapFunc transforms a packet.
+type MapFunc func(packet interface{}) interface{}
+
+// predicateOf receives a predicate from an optional port.
+func predicateOf(ch <-chan PredicateFunc) PredicateFunc {
+	if ch == nil {
+		return nil
+	}
+
+	return <-ch
+}
+
+// mapOf receives a function from an optional port.
+func mapOf(ch <-chan MapFunc) MapFunc {
+	if ch == nil {
+		return nil
+	}
+
+	return <-ch
+}
+
+// valueOf receives a packet from an optional port, nil if it is not connected.
+func valueOf(ch <-chan interface{}) interface{} {
+	if ch == nil {
+		return nil
+	}
+
+	return <-ch
+}
+
+// Filter passes packets which match the Test predicate to Out and drops the others.
+// Without a predicate all packets pass.
+type Filter struct {
+	Test <-chan PredicateFunc `required:"false"`
+	In   <-chan interface{}
+	Out  chan<- interface{}
+}
+
+// Process filters packets until In is closed.
+func (c *Filter) Process() {
+	test := predicateOf(c.Test)
+
+	for p := range c.In {
+		if test == nil || test(p) {
+			c.Out <- p
+		}
+	}
+}
+
+// Map sends the result of the Fn function applied to every packet.
+// Without a function packets are passed as is.
+type Map struct {
+	Fn  <-chan MapFunc `required:"false"`
+	In  <-chan interface{}
+	Out chan<- interface{}
+}
+
+// Process transforms packets until In is closed.
+func (c *Map) Process() {
+	fn := mapOf(c.Fn)
+
+	for p := range c.In {
+		if fn != nil {
+			p = fn(p)
+		}
+
+		c.Out <- p
+	}
+}
+
+// Drop discards all packets. It terminates streams which are not needed.
+type Drop struct {
+	In <-chan interface{}
+}
+
+// Process reads packets until In is closed.
+func (c *Drop) Process() {
+	for range c.In {
+	}
+}
+
+// Repeat sends every packet to Out the number of times received on Times,
+// which is usually an IIP. Packets are sent once if Times is not connected or
+// closed without a value.
+type Repeat struct {
+	Times <-chan int `required:"false"`
+	In    <-chan interface{}
+	Out   chan<- interface{}
+}
+
+// Process repeats packets until In is closed.
+func (c *Repeat) Process() {
+	times := 1
+	if c.Times != nil {
+		if t, ok := <-c.Times; ok {
+			times = t
+		}
+	}
+
+	for p := range c.In {
+		for i := 0; i < times; i++ {
+			c.Out <- p
+		}
+	}
+}
+
+// Kick sends the packet received on Data to Out every time a packet arrives on In.
+// It turns any event into a given packet, Data is usually an IIP. Without Data
+// nil packets are sent.
+type Kick struct {
+	Data <-chan interface{} `required:"false"`
+	In   <-chan interface{}
+	Out  chan<- interface{}
+}
+
+// Process kicks until In is closed.
+func (c *Kick) Process() {
+	data := valueOf(c.Data)
+
+	for range c.In {
+		c.Out <- data
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestFilter(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Filter) }, []goflowtest.Case{
+		{
+			Name: "positive",
+			In: map[string]interface{}{
+				"Test": []PredicateFunc{func(p interface{}) bool { return p.(int) > 0 }},
+				"In":   []interface{}{-1, 2, 0, 3},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{2, 3}},
+		},
+		{
+			Name: "without predicate",
+			In: map[string]interface{}{
+				"Test": []PredicateFunc{},
+				"In":   []interface{}{-1, 2},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{-1, 2}},
+		},
+		{
+			Name: "unconnected predicate",
+			In:   map[string]interface{}{"In": []interface{}{-1, 2}},
+			Out:  map[string]interface{}{"Out": []interface{}{-1, 2}},
+		},
+	})
+}
+
+func TestMap(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Map) }, []goflowtest.Case{
+		{
+			Name: "upper",
+			In: map[string]interface{}{
+				"Fn": []MapFunc{func(p interface{}) interface{} { return strings.ToUpper(p.(string)) }},
+				"In": []interface{}{"a", "b"},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"A", "B"}},
+		},
+		{
+			Name: "unconnected function",
+			In:   map[string]interface{}{"In": []interface{}{"a", "b"}},
+			Out:  map[string]interface{}{"Out": []interface{}{"a", "b"}},
+		},
+	})
+}
+
+func TestDrop(t *testing.T) {
+	res, err := goflowtest.New(new(Drop)).In("In", []interface{}{1, 2, 3}).Run()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(res) != 0 {
+		t.Errorf("Unexpected output %v", res)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Repeat) }, []goflowtest.Case{
+		{
+			Name: "twice",
+			In: map[string]interface{}{
+				"Times": []int{2},
+				"In":    []interface{}{"a", "b"},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"a", "a", "b", "b"}},
+		},
+		{
+			Name: "default",
+			In: map[string]interface{}{
+				"Times": []int{},
+				"In":    []interface{}{"a"},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"a"}},
+		},
+		{
+			Name: "unconnected times",
+			In:   map[string]interface{}{"In": []interface{}{"a", "b"}},
+			Out:  map[string]interface{}{"Out": []interface{}{"a", "b"}},
+		},
+	})
+}
+
+func TestKick(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Kick) }, []goflowtest.Case{
+		{
+			Name: "kick",
+			In: map[string]interface{}{
+				"Data": []interface{}{"go"},
+				"In":   []interface{}{1, true, nil},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{"go", "go", "go"}},
+		},
+		{
+			Name: "unconnected data",
+			In:   map[string]interface{}{"In": []interface{}{1, 2}},
+			Out:  map[string]interface{}{"Out": []interface{}{nil, nil}},
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package fileio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ReadCSV reads CSV files received on Path and sends every row after the header
+// to Out as a record. Records are map[string]interface{} with string values by
+// header names, or structs of the type of the optional Record IIP, e.g. Person{}.
+// Struct fields are matched to columns by the "csv" tag or the field name and
+// columns without a field are skipped.
+type ReadCSV struct {
+	Path   <-chan string
+	Record <-chan interface{}
+	Out    chan<- interface{}
+	Err    chan<- error
+}
+
+// Process reads files until Path is closed.
+func (c *ReadCSV) Process() {
+	t := recordType(c.Record)
+
+	for path := range c.Path {
+		if err := c.read(path, t); err != nil {
+			sendErr(c.Err, err)
+		}
+	}
+}
+
+func (c *ReadCSV) read(path string, t reflect.Type) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("fileio: %s: %w", path, err)
+	}
+
+	var fields []int
+
+	if t != nil {
+		_, target := newRecord(t)
+		if target.Kind() != reflect.Struct {
+			return fmt.Errorf("fileio: record type %s is not a struct", t)
+		}
+
+		for _, col := range header {
+			fields = append(fields, findField(target.Type(), col))
+		}
+	}
+
+	for n := 1; ; n++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("fileio: %s: %w", path, err)
+		}
+
+		if t == nil {
+			m := make(map[string]interface{}, len(header))
+			for i, col := range header {
+				m[col] = row[i]
+			}
+
+			c.Out <- m
+
+			continue
+		}
+
+		record, target := newRecord(t)
+
+		for i, idx := range fields {
+			if idx < 0 {
+				continue
+			}
+
+			if err := setField(target.Field(idx), row[i]); err != nil {
+				return fmt.Errorf("fileio: %s: record %d: column '%s': %w", path, n, header[i], err)
+			}
+		}
+
+		c.Out <- record.Interface()
+	}
+}
+
+// WriteCSV writes records from In to the CSV file received on Path. Records are
+// structs or maps with string keys. The header is taken from the optional Columns
+// IIP or from the first record: struct fields or sorted map keys.
+type WriteCSV struct {
+	Path    <-chan string
+	Columns <-chan []string
+	In      <-chan interface{}
+	Out     chan<- string
+	Err     chan<- error
+}
+
+// Process writes records until In is closed.
+func (c *WriteCSV) Process() {
+	var columns []string
+	if c.Columns != nil {
+		columns = <-c.Columns
+	}
+
+	o := openOutput(c.Path, false)
+
+	var w *csv.Writer
+
+	for record := range c.In {
+		o.write(func(out io.Writer) error {
+			if w == nil {
+				w = csv.NewWriter(out)
+
+				if columns == nil {
+					var err error
+					if columns, err = recordColumns(record); err != nil {
+						return err
+					}
+				}
+
+				if err := w.Write(columns); err != nil {
+					return err
+				}
+			}
+
+			values, err := recordValues(record, columns)
+			if err != nil {
+				return err
+			}
+
+			return w.Write(values)
+		})
+	}
+
+	if w != nil {
+		o.write(func(io.Writer) error {
+			w.Flush()
+			return w.Error()
+		})
+	}
+
+	o.close(c.Out, c.Err)
+}
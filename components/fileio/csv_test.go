@@ -0,0 +1,129 @@
+package fileio
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+type person struct {
+	Name  string
+	Age   int     `csv:"age"`
+	Score float64 `csv:"score"`
+	Notes string  `csv:"-"`
+}
+
+func TestReadCSV(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "people.csv", "name,age,score,extra\nAda,36,9.5,x\nBob,41,7,y\n")
+
+	goflowtest.RunCases(t, func() interface{} { return new(ReadCSV) }, []goflowtest.Case{
+		{
+			Name: "maps",
+			In:   map[string]interface{}{"Path": []string{path}},
+			Out: map[string]interface{}{"Out": []interface{}{
+				map[string]interface{}{"name": "Ada", "age": "36", "score": "9.5", "extra": "x"},
+				map[string]interface{}{"name": "Bob", "age": "41", "score": "7", "extra": "y"},
+			}},
+		},
+		{
+			Name: "structs",
+			In: map[string]interface{}{
+				"Path":   []string{path},
+				"Record": []interface{}{person{}},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{
+				person{Name: "Ada", Age: 36, Score: 9.5},
+				person{Name: "Bob", Age: 41, Score: 7},
+			}},
+		},
+		{
+			Name: "pointers",
+			In: map[string]interface{}{
+				"Path":   []string{path},
+				"Record": []interface{}{&person{}},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{
+				&person{Name: "Ada", Age: 36, Score: 9.5},
+				&person{Name: "Bob", Age: 41, Score: 7},
+			}},
+		},
+	})
+}
+
+func TestReadCSVInvalidField(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "people.csv", "name,age\nAda,old\n")
+
+	res, err := goflowtest.New(new(ReadCSV)).
+		In("Path", []string{path}).
+		In("Record", []interface{}{person{}}).
+		Out("Out", "Err").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	errs := res["Err"].([]error)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "record 1: column 'age'") {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		columns []interface{}
+		records []interface{}
+		want    string
+	}{
+		{
+			name:    "structs",
+			records: []interface{}{person{Name: "Ada", Age: 36, Score: 9.5}, &person{Name: "Bob, Jr", Age: 41}},
+			want:    "Name,age,score\nAda,36,9.5\n\"Bob, Jr\",41,0\n",
+		},
+		{
+			name:    "maps",
+			records: []interface{}{map[string]interface{}{"b": 2, "a": "x"}, map[string]interface{}{"a": "y", "c": 3}},
+			want:    "a,b\nx,2\ny,\n",
+		},
+		{
+			name:    "columns",
+			columns: []interface{}{[]string{"c", "a"}},
+			records: []interface{}{map[string]interface{}{"a": 1, "c": 3}},
+			want:    "c,a\n3,1\n",
+		},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name+".csv")
+		h := goflowtest.New(new(WriteCSV)).In("Path", []string{path}).In("In", test.records).Out("Err")
+
+		if test.columns != nil {
+			h.In("Columns", test.columns)
+		}
+
+		res, err := h.Run()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if err := goflowtest.Equal(res["Err"], nil); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		data, err := readAll(path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if string(data) != test.want {
+			t.Errorf("%s: %q != %q", test.name, data, test.want)
+		}
+	}
+}
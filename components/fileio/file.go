@@ -0,0 +1,187 @@
+package fileio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// errNoPath is reported by writers which have not received a path.
+var errNoPath = errors.New("fileio: no path received")
+
+// ReadFile sends the contents of every file received on Path to Out.
+type ReadFile struct {
+	Path <-chan string
+	Out  chan<- []byte
+	Err  chan<- error
+}
+
+// Process reads files until Path is closed.
+func (c *ReadFile) Process() {
+	for path := range c.Path {
+		data, err := readAll(path)
+		if err != nil {
+			sendErr(c.Err, err)
+			continue
+		}
+
+		c.Out <- data
+	}
+}
+
+func readAll(path string) ([]byte, error) {
+	r, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// ReadLines sends lines of every file received on Path to Out without line endings.
+type ReadLines struct {
+	Path <-chan string
+	Out  chan<- string
+	Err  chan<- error
+}
+
+// Process reads files until Path is closed.
+func (c *ReadLines) Process() {
+	for path := range c.Path {
+		if err := c.read(path); err != nil {
+			sendErr(c.Err, err)
+		}
+	}
+}
+
+func (c *ReadLines) read(path string) error {
+	r, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<30)
+
+	for s.Scan() {
+		c.Out <- s.Text()
+	}
+
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("fileio: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// WriteFile writes all packets from In to the file received on Path, replacing
+// its contents. When In is closed and the file is written, its path is sent
+// to Out if it is connected.
+type WriteFile struct {
+	Path <-chan string
+	In   <-chan []byte
+	Out  chan<- string
+	Err  chan<- error
+}
+
+// Process writes packets until In is closed.
+func (c *WriteFile) Process() {
+	o := openOutput(c.Path, false)
+
+	for p := range c.In {
+		o.write(func(w io.Writer) error {
+			_, err := w.Write(p)
+			return err
+		})
+	}
+
+	o.close(c.Out, c.Err)
+}
+
+// AppendLines appends packets from In as lines to the file received on Path.
+// When In is closed, the path is sent to Out if it is connected.
+type AppendLines struct {
+	Path <-chan string
+	In   <-chan string
+	Out  chan<- string
+	Err  chan<- error
+}
+
+// Process appends lines until In is closed.
+func (c *AppendLines) Process() {
+	o := openOutput(c.Path, true)
+
+	for line := range c.In {
+		o.write(func(w io.Writer) error {
+			_, err := io.WriteString(w, line+"\n")
+			return err
+		})
+	}
+
+	o.close(c.Out, c.Err)
+}
+
+// output is a file written by a writer component. After the first error
+// packets are discarded, so that the senders are not blocked.
+type output struct {
+	path string
+	w    *bufio.Writer
+	file io.WriteCloser
+	err  error
+}
+
+// openOutput opens a file by the path received from a port.
+func openOutput(pathCh <-chan string, appendTo bool) *output {
+	path, ok := <-pathCh
+	if !ok {
+		return &output{err: errNoPath}
+	}
+
+	o := &output{path: path}
+
+	o.file, o.err = create(path, appendTo)
+	if o.err == nil {
+		o.w = bufio.NewWriter(o.file)
+	}
+
+	return o
+}
+
+// write writes to the file unless an error has occurred.
+func (o *output) write(fn func(w io.Writer) error) {
+	if o.err != nil {
+		return
+	}
+
+	if err := fn(o.w); err != nil {
+		o.err = fmt.Errorf("fileio: %s: %w", o.path, err)
+	}
+}
+
+// close flushes and closes the file and reports the result.
+func (o *output) close(out chan<- string, errs chan<- error) {
+	if o.file != nil {
+		if o.err == nil {
+			if err := o.w.Flush(); err != nil {
+				o.err = fmt.Errorf("fileio: %s: %w", o.path, err)
+			}
+		}
+
+		if err := o.file.Close(); err != nil && o.err == nil {
+			o.err = fmt.Errorf("fileio: %s: %w", o.path, err)
+		}
+	}
+
+	if o.err != nil {
+		sendErr(errs, o.err)
+		return
+	}
+
+	if out != nil {
+		out <- o.path
+	}
+}
@@ -0,0 +1,145 @@
+package fileio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+// writeTestFile creates a file with given contents in a temporary directory.
+func writeTestFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	w, err := create(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	plain := writeTestFile(t, dir, "plain.txt", "hello")
+	packed := writeTestFile(t, dir, "packed.txt.gz", "world")
+
+	res, err := goflowtest.New(new(ReadFile)).
+		In("Path", []string{plain, filepath.Join(dir, "missing"), packed}).
+		Out("Out", "Err").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := goflowtest.Equal(res["Out"], [][]byte{[]byte("hello"), []byte("world")}); err != nil {
+		t.Error(err)
+	}
+
+	if errs := res["Err"].([]error); len(errs) != 1 || !os.IsNotExist(errs[0]) {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "lines.txt", "one\ntwo\r\n\nthree")
+
+	res, err := goflowtest.New(new(ReadLines)).
+		In("Path", []string{path}).
+		Out("Out").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := goflowtest.Equal(res["Out"], []string{"one", "two", "", "three"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	writeTestFile(t, filepath.Dir(path), "out.txt", "old contents")
+
+	res, err := goflowtest.New(new(WriteFile)).
+		In("Path", []string{path}).
+		In("In", [][]byte{[]byte("new "), []byte("contents")}).
+		Out("Out", "Err").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := goflowtest.Equal(res["Out"], []string{path}); err != nil {
+		t.Error(err)
+	}
+
+	if err := goflowtest.Equal(res["Err"], nil); err != nil {
+		t.Error(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(data) != "new contents" {
+		t.Errorf("'%s' != 'new contents'", data)
+	}
+}
+
+func TestWriteFileWithoutPath(t *testing.T) {
+	res, err := goflowtest.New(new(WriteFile)).
+		In("Path", []string{}).
+		In("In", [][]byte{[]byte("lost")}).
+		Out("Out", "Err").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if errs := res["Err"].([]error); len(errs) != 1 || errs[0] != errNoPath {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
+
+func TestAppendLines(t *testing.T) {
+	for _, name := range []string{"log.txt", "log.txt.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+
+		for _, lines := range [][]string{{"a", "b"}, {"c"}} {
+			if _, err := goflowtest.New(new(AppendLines)).In("Path", []string{path}).In("In", lines).Run(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+
+		res, err := goflowtest.New(new(ReadLines)).In("Path", []string{path}).Out("Out").Run()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if err := goflowtest.Equal(res["Out"], []string{"a", "b", "c"}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
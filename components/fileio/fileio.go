@@ -0,0 +1,154 @@
+// Package fileio provides components which read and write files: whole files,
+// lines, CSV and JSON lines records, as well as gzip compression and a
+// directory watcher.
+//
+// Readers receive file paths on their Path inport, which may be an IIP, and
+// process the files one by one. Writers receive the path as an IIP and write
+// all packets from In to the file. Files with the ".gz" extension are
+// compressed and decompressed transparently.
+//
+// Errors are sent to the Err outport. In a graph an unconnected Err outport is
+// routed to the dead letters of the graph, outside of a graph errors are
+// dropped if Err is nil. A reader which fails on a file continues with the next path.
+package fileio
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/trustmaster/goflow"
+)
+
+// Library is the namespace of the components in a factory.
+const Library = "fileio"
+
+// components lists the components of the package with their annotations.
+var components = []struct {
+	name        string
+	constructor goflow.Constructor
+	annotation  goflow.Annotation
+}{
+	{"ReadFile", func() (interface{}, error) { return new(ReadFile), nil },
+		goflow.Annotation{Description: "Reads the contents of files", Icon: "file"}},
+	{"ReadLines", func() (interface{}, error) { return new(ReadLines), nil },
+		goflow.Annotation{Description: "Reads files line by line", Icon: "file-text"}},
+	{"WriteFile", func() (interface{}, error) { return new(WriteFile), nil },
+		goflow.Annotation{Description: "Writes packets to a file", Icon: "floppy-o"}},
+	{"AppendLines", func() (interface{}, error) { return new(AppendLines), nil },
+		goflow.Annotation{Description: "Appends lines to a file", Icon: "pencil"}},
+	{"ReadCSV", func() (interface{}, error) { return new(ReadCSV), nil },
+		goflow.Annotation{Description: "Reads records from CSV files", Icon: "table"}},
+	{"WriteCSV", func() (interface{}, error) { return new(WriteCSV), nil },
+		goflow.Annotation{Description: "Writes records to a CSV file", Icon: "table"}},
+	{"ReadJSONL", func() (interface{}, error) { return new(ReadJSONL), nil },
+		goflow.Annotation{Description: "Reads records from JSON lines files", Icon: "code"}},
+	{"WriteJSONL", func() (interface{}, error) { return new(WriteJSONL), nil },
+		goflow.Annotation{Description: "Writes records to a JSON lines file", Icon: "code"}},
+	{"Gzip", func() (interface{}, error) { return new(Gzip), nil },
+		goflow.Annotation{Description: "Compresses packets with gzip", Icon: "compress"}},
+	{"Gunzip", func() (interface{}, error) { return new(Gunzip), nil },
+		goflow.Annotation{Description: "Decompresses gzip packets", Icon: "expand"}},
+	{"DirectoryWatcher", func() (interface{}, error) { return new(DirectoryWatcher), nil },
+		goflow.Annotation{Description: "Sends events about files created, changed or removed in directories", Icon: "eye"}},
+}
+
+// Register registers the components in a factory as "fileio/ReadFile", "fileio/WriteCSV", etc.
+func Register(f *goflow.Factory) error {
+	for _, c := range components {
+		name := Library + "/" + c.name
+
+		if err := f.Register(name, c.constructor); err != nil {
+			return err
+		}
+
+		if err := f.Annotate(name, c.annotation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendErr sends an error to an optional Err outport.
+func sendErr(ch chan<- error, err error) {
+	if ch != nil {
+		ch <- err
+	}
+}
+
+// isGzip tells if a file is compressed by its name.
+func isGzip(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// gzipReader closes both the decompressor and the file.
+type gzipReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r gzipReader) Close() error {
+	err := r.Reader.Close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// gzipWriter flushes the compressor and closes the file.
+type gzipWriter struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (w gzipWriter) Close() error {
+	err := w.Writer.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// open opens a file for reading, decompressing it if needed.
+func open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isGzip(path) {
+		return f, nil
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "gunzip", Path: path, Err: err}
+	}
+
+	return gzipReader{zr, f}, nil
+}
+
+// create opens a file for writing, truncating it or appending to it. Compressed
+// files are appended as new gzip members, which are read as a single stream.
+func create(path string, appendTo bool) (io.WriteCloser, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isGzip(path) {
+		return f, nil
+	}
+
+	return gzipWriter{gzip.NewWriter(f), f}, nil
+}
@@ -0,0 +1,66 @@
+package fileio
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/trustmaster/goflow"
+)
+
+func TestRegister(t *testing.T) {
+	f := goflow.NewFactory()
+
+	if err := Register(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	list := f.List("fileio/")
+	if len(list) != len(components) {
+		t.Errorf("%d != %d", len(list), len(components))
+	}
+
+	for _, info := range list {
+		if info.Description == "" {
+			t.Errorf("Component %s is not annotated", info.Name)
+		}
+	}
+
+	// A graph file copies lines from a file to another
+	dir := t.TempDir()
+	src := writeTestFile(t, dir, "src.txt", "one\ntwo\n")
+	dst := filepath.Join(dir, "dst.txt")
+
+	n, err := goflow.ParseFBP([]byte(`
+OUTPORT=write.OUT:DONE
+'`+src+`' -> PATH read(fileio/ReadLines) OUT -> IN write(fileio/AppendLines)
+'`+dst+`' -> PATH write
+`), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	done := make(chan string, 1)
+
+	if err := n.SetOutPort("Done", done); err != nil {
+		t.Error(err)
+		return
+	}
+
+	<-goflow.Run(n)
+
+	if path := <-done; path != dst {
+		t.Errorf("%s != %s", path, dst)
+	}
+
+	data, err := readAll(dst)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(data) != "one\ntwo\n" {
+		t.Errorf("%q != %q", data, "one\ntwo\n")
+	}
+}
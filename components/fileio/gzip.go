@@ -0,0 +1,57 @@
+package fileio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+// Gzip compresses every packet from In and sends it to Out.
+type Gzip struct {
+	In  <-chan []byte
+	Out chan<- []byte
+}
+
+// Process compresses packets until In is closed.
+func (c *Gzip) Process() {
+	for p := range c.In {
+		var buf bytes.Buffer
+
+		w := gzip.NewWriter(&buf)
+		w.Write(p) // Writes to a buffer do not fail
+		w.Close()
+
+		c.Out <- buf.Bytes()
+	}
+}
+
+// Gunzip decompresses every packet from In and sends it to Out.
+// Packets which are not valid gzip data are reported to Err.
+type Gunzip struct {
+	In  <-chan []byte
+	Out chan<- []byte
+	Err chan<- error
+}
+
+// Process decompresses packets until In is closed.
+func (c *Gunzip) Process() {
+	for p := range c.In {
+		data, err := gunzip(p)
+		if err != nil {
+			sendErr(c.Err, fmt.Errorf("fileio: gunzip: %w", err))
+			continue
+		}
+
+		c.Out <- data
+	}
+}
+
+func gunzip(p []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,33 @@
+package fileio
+
+import (
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestGzip(t *testing.T) {
+	packets := [][]byte{[]byte("first"), {}, []byte("second")}
+
+	res, err := goflowtest.New(new(Gzip)).In("In", packets).Out("Out").Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	compressed := append(res["Out"].([][]byte), []byte("not gzip"))
+
+	res, err = goflowtest.New(new(Gunzip)).In("In", compressed).Out("Out", "Err").Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := goflowtest.Equal(res["Out"], packets); err != nil {
+		t.Error(err)
+	}
+
+	if errs := res["Err"].([]error); len(errs) != 1 {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
@@ -0,0 +1,90 @@
+package fileio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ReadJSONL reads files received on Path which contain a JSON value per line
+// and sends the values to Out as records. Records are map[string]interface{}
+// or values of the type of the optional Record IIP, e.g. Person{}.
+type ReadJSONL struct {
+	Path   <-chan string
+	Record <-chan interface{}
+	Out    chan<- interface{}
+	Err    chan<- error
+}
+
+// Process reads files until Path is closed.
+func (c *ReadJSONL) Process() {
+	t := recordType(c.Record)
+
+	for path := range c.Path {
+		if err := c.read(path, t); err != nil {
+			sendErr(c.Err, err)
+		}
+	}
+}
+
+func (c *ReadJSONL) read(path string, t reflect.Type) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+
+	for n := 1; ; n++ {
+		var record, target reflect.Value
+
+		if t == nil {
+			record = reflect.New(reflect.TypeOf(map[string]interface{}{})).Elem()
+			target = record
+		} else {
+			record, target = newRecord(t)
+		}
+
+		err := dec.Decode(target.Addr().Interface())
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("fileio: %s: record %d: %w", path, n, err)
+		}
+
+		c.Out <- record.Interface()
+	}
+}
+
+// WriteJSONL writes packets from In to the file received on Path as JSON values,
+// one per line.
+type WriteJSONL struct {
+	Path <-chan string
+	In   <-chan interface{}
+	Out  chan<- string
+	Err  chan<- error
+}
+
+// Process writes records until In is closed.
+func (c *WriteJSONL) Process() {
+	o := openOutput(c.Path, false)
+
+	var enc *json.Encoder
+
+	for record := range c.In {
+		o.write(func(w io.Writer) error {
+			if enc == nil {
+				enc = json.NewEncoder(w)
+			}
+
+			return enc.Encode(record)
+		})
+	}
+
+	o.close(c.Out, c.Err)
+}
@@ -0,0 +1,66 @@
+package fileio
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+type event struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+}
+
+func TestJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl.gz")
+	events := []interface{}{event{1, "start"}, map[string]interface{}{"id": 2, "kind": "stop"}}
+
+	res, err := goflowtest.New(new(WriteJSONL)).In("Path", []string{path}).In("In", events).Out("Out").Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := goflowtest.Equal(res["Out"], []string{path}); err != nil {
+		t.Error(err)
+	}
+
+	goflowtest.RunCases(t, func() interface{} { return new(ReadJSONL) }, []goflowtest.Case{
+		{
+			Name: "maps",
+			In:   map[string]interface{}{"Path": []string{path}},
+			Out: map[string]interface{}{"Out": []interface{}{
+				map[string]interface{}{"id": 1.0, "kind": "start"},
+				map[string]interface{}{"id": 2.0, "kind": "stop"},
+			}},
+		},
+		{
+			Name: "structs",
+			In: map[string]interface{}{
+				"Path":   []string{path},
+				"Record": []interface{}{event{}},
+			},
+			Out: map[string]interface{}{"Out": []interface{}{event{1, "start"}, event{2, "stop"}}},
+		},
+	})
+}
+
+func TestReadJSONLInvalid(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "bad.jsonl", "{\"id\": 1}\n{\"id\": \n")
+
+	res, err := goflowtest.New(new(ReadJSONL)).In("Path", []string{path}).Out("Out", "Err").Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := goflowtest.Equal(res["Out"], []interface{}{map[string]interface{}{"id": 1.0}}); err != nil {
+		t.Error(err)
+	}
+
+	if errs := res["Err"].([]error); len(errs) != 1 || !strings.Contains(errs[0].Error(), "record 2") {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
@@ -0,0 +1,170 @@
+package fileio
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// recordType receives a prototype of record packets from an optional Record
+// port, e.g. Person{} or &Person{}. Without it records are map[string]interface{}.
+func recordType(ch <-chan interface{}) reflect.Type {
+	if ch == nil {
+		return nil
+	}
+
+	proto, ok := <-ch
+	if !ok || proto == nil {
+		return nil
+	}
+
+	return reflect.TypeOf(proto)
+}
+
+// newRecord creates a record of a prototype type, the returned value is
+// the struct to fill.
+func newRecord(t reflect.Type) (record, target reflect.Value) {
+	if t.Kind() == reflect.Ptr {
+		record = reflect.New(t.Elem())
+		return record, record.Elem()
+	}
+
+	record = reflect.New(t).Elem()
+
+	return record, record
+}
+
+// fieldColumns returns the names of exported struct fields as columns, which
+// are taken from the "csv" tag if it is set. Fields tagged "-" are skipped.
+func fieldColumns(t reflect.Type) (names []string, fields []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("csv"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		names = append(names, name)
+		fields = append(fields, i)
+	}
+
+	return names, fields
+}
+
+// findField returns the index of a field for a column, matching the name case-insensitively.
+func findField(t reflect.Type, column string) int {
+	names, fields := fieldColumns(t)
+
+	for i, name := range names {
+		if name == column {
+			return fields[i]
+		}
+	}
+
+	for i, name := range names {
+		if strings.EqualFold(name, column) {
+			return fields[i]
+		}
+	}
+
+	return -1
+}
+
+// setField parses a string into a field of a basic type.
+func setField(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
+
+// recordColumns returns the columns of a record: struct fields or sorted map keys.
+func recordColumns(record interface{}) ([]string, error) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		names, _ := fieldColumns(v.Type())
+		return names, nil
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		names := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			names = append(names, k.String())
+		}
+
+		sort.Strings(names)
+
+		return names, nil
+	default:
+		return nil, fmt.Errorf("record must be a struct or a map with string keys, got %T", record)
+	}
+}
+
+// recordValues formats the values of columns of a record. Missing values are empty.
+func recordValues(record interface{}, columns []string) ([]string, error) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	res := make([]string, len(columns))
+
+	for i, col := range columns {
+		var f reflect.Value
+
+		switch {
+		case v.Kind() == reflect.Struct:
+			if idx := findField(v.Type(), col); idx >= 0 {
+				f = v.Field(idx)
+			}
+		case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			f = v.MapIndex(reflect.ValueOf(col).Convert(v.Type().Key()))
+		default:
+			return nil, fmt.Errorf("record must be a struct or a map with string keys, got %T", record)
+		}
+
+		if f.IsValid() && !(f.Kind() == reflect.Interface && f.IsNil()) {
+			res[i] = fmt.Sprint(f.Interface())
+		}
+	}
+
+	return res, nil
+}
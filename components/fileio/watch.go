@@ -0,0 +1,148 @@
+package fileio
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultPollInterval is the interval a DirectoryWatcher checks directories at.
+const DefaultPollInterval = time.Second
+
+// FileOp is a kind of change of a file.
+type FileOp string
+
+// Changes reported by DirectoryWatcher.
+const (
+	FileCreated FileOp = "create"
+	FileWritten FileOp = "write"
+	FileRemoved FileOp = "remove"
+)
+
+// FileEvent is a change of a file in a watched directory.
+type FileEvent struct {
+	Op   FileOp
+	Path string
+}
+
+// DirectoryWatcher watches directories received on Dir and sends events about
+// files created, changed or removed in them to Out. Files which exist when
+// a directory is added are not reported. Directories are polled every Interval,
+// which is an optional IIP. Watching stops when Stop receives a packet or is
+// closed, without Stop it never stops.
+type DirectoryWatcher struct {
+	Dir      <-chan string
+	Interval <-chan time.Duration
+	Stop     <-chan interface{}
+	Out      chan<- FileEvent
+	Err      chan<- error
+}
+
+// fileState is what a watcher remembers about a file to detect changes.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Process watches directories until Stop is closed.
+func (c *DirectoryWatcher) Process() {
+	interval := DefaultPollInterval
+	if c.Interval != nil {
+		if d, ok := <-c.Interval; ok && d > 0 {
+			interval = d
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	dirs := make(map[string]map[string]fileState)
+	dirCh := c.Dir
+
+	for {
+		select {
+		case dir, ok := <-dirCh:
+			if !ok {
+				dirCh = nil
+				break
+			}
+
+			if _, watched := dirs[dir]; watched {
+				break
+			}
+
+			files, err := scan(dir)
+			if err != nil {
+				sendErr(c.Err, err)
+				break
+			}
+
+			dirs[dir] = files
+		case <-ticker.C:
+			for dir, files := range dirs {
+				dirs[dir] = c.poll(dir, files)
+			}
+		case <-c.Stop:
+			return
+		}
+	}
+}
+
+// poll sends events about the changes since the previous state of a directory
+// and returns the new state.
+func (c *DirectoryWatcher) poll(dir string, prev map[string]fileState) map[string]fileState {
+	files, err := scan(dir)
+	if err != nil {
+		sendErr(c.Err, err)
+		return prev
+	}
+
+	names := make([]string, 0, len(files)+len(prev))
+
+	for name := range files {
+		names = append(names, name)
+	}
+
+	for name := range prev {
+		if _, ok := files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		was, existed := prev[name]
+		now, exists := files[name]
+
+		switch {
+		case !existed:
+			c.Out <- FileEvent{FileCreated, filepath.Join(dir, name)}
+		case !exists:
+			c.Out <- FileEvent{FileRemoved, filepath.Join(dir, name)}
+		case was.size != now.size || !was.modTime.Equal(now.modTime):
+			c.Out <- FileEvent{FileWritten, filepath.Join(dir, name)}
+		}
+	}
+
+	return files
+}
+
+// scan reads the state of the files in a directory.
+func scan(dir string) (map[string]fileState, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]fileState, len(infos))
+
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			files[info.Name()] = fileState{info.Size(), info.ModTime()}
+		}
+	}
+
+	return files, nil
+}
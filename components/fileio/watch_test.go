@@ -0,0 +1,60 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirectoryWatcher(t *testing.T) {
+	dir := t.TempDir()
+	existing := writeTestFile(t, dir, "existing.txt", "x")
+
+	dirs := make(chan string)
+	interval := make(chan time.Duration, 1)
+	stop := make(chan interface{})
+	out := make(chan FileEvent)
+	done := make(chan struct{})
+
+	interval <- 5 * time.Millisecond
+
+	c := &DirectoryWatcher{Dir: dirs, Interval: interval, Stop: stop, Out: out}
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	// Sending the directory again waits until the first scan is done
+	dirs <- dir
+	dirs <- dir
+
+	expect := func(want FileEvent) {
+		t.Helper()
+
+		select {
+		case got := <-out:
+			if got != want {
+				t.Fatalf("%v != %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %v", want)
+		}
+	}
+
+	created := writeTestFile(t, dir, "new.txt", "a")
+	expect(FileEvent{FileCreated, created})
+
+	writeTestFile(t, dir, "new.txt", "longer")
+	expect(FileEvent{FileWritten, created})
+
+	if err := os.Remove(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(FileEvent{FileRemoved, filepath.Join(dir, "existing.txt")})
+
+	close(stop)
+	<-done
+}
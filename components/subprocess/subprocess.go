@@ -0,0 +1,408 @@
+// Package subprocess provides a component which runs an external executable
+// as a flow-based process.
+package subprocess
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trustmaster/goflow"
+)
+
+// Framing defines how packets are delimited on the standard streams of a child process.
+type Framing int
+
+const (
+	// Lines frames each packet as a single line terminated with '\n'.
+	Lines Framing = iota
+	// LengthPrefixed frames each packet as a 4-byte big-endian length followed by the payload.
+	LengthPrefixed
+)
+
+// RestartPolicy tells what to do when a child process exits while there is still input for it.
+type RestartPolicy int
+
+const (
+	// RestartNever lets the component finish when the child exits.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restarts the child only if it exited with a non-zero code.
+	RestartOnFailure
+	// RestartAlways restarts the child whenever it exits before its input is closed.
+	RestartAlways
+)
+
+// Config describes the external command and how it is supervised.
+type Config struct {
+	Path         string        // Executable name or path
+	Args         []string      // Command line arguments
+	Dir          string        // Working directory, current one if empty
+	Env          []string      // Environment in "key=value" form, inherited if nil
+	Framing      Framing       // Record framing on stdin and stdout
+	Restart      RestartPolicy // Restart policy
+	MaxRestarts  int           // Maximum number of restarts, unlimited if 0
+	RestartDelay time.Duration // Pause before restarting the child
+	StopTimeout  time.Duration // How long to wait for the child to exit after its stdin is closed before killing it, no limit if 0
+}
+
+// Subprocess writes packets from In to the stdin of a child process and emits
+// records the child writes to its stdout on Out. Stderr lines are sent to Err
+// and exit codes to Exit. Err and Exit are optional.
+type Subprocess struct {
+	In   <-chan string // Records written to the child's stdin
+	Out  chan<- string // Records read from the child's stdout
+	Err  chan<- string // Lines read from the child's stderr
+	Exit chan<- int    // Exit code of every finished child
+
+	conf Config
+}
+
+// New creates a new Subprocess component instance.
+func New(conf Config) *Subprocess {
+	return &Subprocess{conf: conf}
+}
+
+// Constructor returns a goflow.Constructor creating Subprocess instances for a given command.
+func Constructor(conf Config) goflow.Constructor {
+	return func() (interface{}, error) {
+		if conf.Path == "" {
+			return nil, errors.New("subprocess: command path is empty")
+		}
+
+		return New(conf), nil
+	}
+}
+
+// Register registers a command as a component with a given name in a factory.
+func Register(f *goflow.Factory, componentName string, conf Config) error {
+	if err := f.Register(componentName, Constructor(conf)); err != nil {
+		return err
+	}
+
+	return f.Annotate(componentName, goflow.Annotation{
+		Description: fmt.Sprintf("Runs '%s' as a subprocess", strings.Join(append([]string{conf.Path}, conf.Args...), " ")),
+		Icon:        "terminal",
+	})
+}
+
+// Delays before starting a child again after it has failed to start. They grow
+// twice with every failure in a row from RestartDelay or minStartBackoff, whichever
+// is longer, up to maxStartBackoff.
+const (
+	minStartBackoff = 10 * time.Millisecond
+	maxStartBackoff = 10 * time.Second
+)
+
+// Process runs the child process until In is closed, restarting it according to the policy.
+// Failures to start the child count as restarts and are retried with a growing delay.
+func (c *Subprocess) Process() {
+	var (
+		pending  *string // packet which could not be delivered to a dead child
+		restarts int
+		backoff  time.Duration // Delay after a failed start, 0 if the last start succeeded
+	)
+
+	for {
+		code, inClosed, started, err := c.runOnce(&pending)
+		if err != nil {
+			c.sendErr(err.Error())
+		}
+
+		if c.Exit != nil {
+			c.Exit <- code
+		}
+
+		if inClosed {
+			return
+		}
+
+		if !c.shouldRestart(code, err, restarts) {
+			break
+		}
+
+		restarts++
+
+		delay := c.conf.RestartDelay
+
+		switch {
+		case started:
+			backoff = 0
+		case backoff == 0:
+			backoff = delay
+			if backoff < minStartBackoff {
+				backoff = minStartBackoff
+			}
+		default:
+			backoff *= 2
+			if backoff > maxStartBackoff {
+				backoff = maxStartBackoff
+			}
+		}
+
+		if backoff > delay {
+			delay = backoff
+		}
+
+		if delay > 0 && !c.pause(delay, &pending) {
+			return
+		}
+	}
+
+	// The child is gone for good, drain the input so that upstream is not blocked
+	for range c.In {
+	}
+}
+
+// pause waits before a restart. A packet arriving meanwhile is kept in pending.
+// It returns false if In is closed before the delay is over.
+func (c *Subprocess) pause(delay time.Duration, pending **string) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		in := c.In
+		if *pending != nil {
+			in = nil
+		}
+
+		select {
+		case <-timer.C:
+			return true
+		case p, ok := <-in:
+			if !ok {
+				return false
+			}
+
+			*pending = &p
+		}
+	}
+}
+
+func (c *Subprocess) shouldRestart(code int, err error, restarts int) bool {
+	if c.conf.MaxRestarts > 0 && restarts >= c.conf.MaxRestarts {
+		return false
+	}
+
+	switch c.conf.Restart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return code != 0 || err != nil
+	default:
+		return false
+	}
+}
+
+// runOnce starts a child and feeds it until either In is closed or the child exits.
+// A packet which has been read from In but could not be written is kept in pending.
+// It tells if the child has been started at all.
+func (c *Subprocess) runOnce(pending **string) (code int, inClosed, started bool, err error) {
+	cmd := exec.Command(c.conf.Path, c.conf.Args...)
+	cmd.Dir = c.conf.Dir
+	cmd.Env = c.conf.Env
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return -1, false, false, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return -1, false, false, err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return -1, false, false, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return -1, false, false, fmt.Errorf("subprocess: %w", err)
+	}
+
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+
+	go func() {
+		c.readRecords(stdout)
+		wg.Done()
+	}()
+
+	go func() {
+		c.readErrors(stderr)
+		wg.Done()
+	}()
+
+	outputDone := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(outputDone)
+	}()
+
+	inClosed = c.feed(stdin, pending, outputDone)
+	stdin.Close()
+
+	// A child which keeps writing output after its input is closed is only
+	// killed if the stop timeout is set
+	if c.conf.StopTimeout > 0 {
+		select {
+		case <-outputDone:
+		case <-time.After(c.conf.StopTimeout):
+			_ = cmd.Process.Kill()
+			<-outputDone
+		}
+	} else {
+		<-outputDone
+	}
+
+	return exitCode(cmd.Wait()), inClosed, true, nil
+}
+
+// feed writes packets to the child's stdin. It returns true if In has been closed
+// or false if the child has exited before that.
+func (c *Subprocess) feed(stdin io.Writer, pending **string, outputDone <-chan struct{}) bool {
+	if *pending != nil {
+		if c.framable(**pending) {
+			if err := c.writeRecord(stdin, **pending); err != nil {
+				return false
+			}
+		}
+
+		*pending = nil
+	}
+
+	for {
+		select {
+		case p, ok := <-c.In:
+			if !ok {
+				return true
+			}
+
+			if !c.framable(p) {
+				continue
+			}
+
+			if err := c.writeRecord(stdin, p); err != nil {
+				*pending = &p
+				return false
+			}
+		case <-outputDone:
+			return false
+		}
+	}
+}
+
+// framable tells if a packet can be written as a single record. Packets which
+// would arrive to the child as several lines are rejected with an error.
+func (c *Subprocess) framable(p string) bool {
+	if c.conf.Framing == Lines && strings.ContainsRune(p, '\n') {
+		c.sendErr(fmt.Sprintf("subprocess: packet %q contains a line break, which Lines framing cannot carry", p))
+		return false
+	}
+
+	return true
+}
+
+func (c *Subprocess) writeRecord(w io.Writer, p string) error {
+	if c.conf.Framing == LengthPrefixed {
+		var size [4]byte
+
+		binary.BigEndian.PutUint32(size[:], uint32(len(p)))
+
+		if _, err := w.Write(size[:]); err != nil {
+			return err
+		}
+
+		_, err := io.WriteString(w, p)
+
+		return err
+	}
+
+	_, err := io.WriteString(w, p+"\n")
+
+	return err
+}
+
+func (c *Subprocess) readRecords(r io.Reader) {
+	br := bufio.NewReader(r)
+
+	for {
+		rec, err := c.readRecord(br)
+		if err != nil {
+			if err != io.EOF {
+				c.sendErr(err.Error())
+			}
+
+			// Keep reading until EOF so the child never blocks on a full pipe
+			_, _ = io.Copy(ioutil.Discard, br)
+
+			return
+		}
+
+		if c.Out != nil {
+			c.Out <- rec
+		}
+	}
+}
+
+func (c *Subprocess) readRecord(r *bufio.Reader) (string, error) {
+	if c.conf.Framing == LengthPrefixed {
+		var size [4]byte
+
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return "", err
+		}
+
+		buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", fmt.Errorf("subprocess: truncated record: %w", err)
+		}
+
+		return string(buf), nil
+	}
+
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		// Last line without a trailing newline
+		return line, nil
+	}
+
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+func (c *Subprocess) readErrors(r io.Reader) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		c.sendErr(s.Text())
+	}
+
+	_, _ = io.Copy(ioutil.Discard, r)
+}
+
+func (c *Subprocess) sendErr(msg string) {
+	if c.Err != nil {
+		c.Err <- msg
+	}
+}
+
+// exitCode extracts the exit code from the result of cmd.Wait().
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
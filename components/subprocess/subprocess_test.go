@@ -0,0 +1,279 @@
+package subprocess
+
+import (
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trustmaster/goflow"
+)
+
+func requireCommand(t *testing.T, name string) {
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is not available: %v", name, err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	requireCommand(t, "cat")
+
+	in := make(chan string)
+	out := make(chan string)
+	exit := make(chan int, 1)
+	c := New(Config{Path: "cat"})
+	c.In = in
+	c.Out = out
+	c.Exit = exit
+
+	wait := goflow.Run(c)
+
+	data := []string{"foo", "bar baz", ""}
+
+	go func() {
+		for _, s := range data {
+			in <- s
+		}
+
+		close(in)
+	}()
+
+	for _, expected := range data {
+		if actual := <-out; actual != expected {
+			t.Errorf("'%s' != '%s'", actual, expected)
+		}
+	}
+
+	<-wait
+
+	if code := <-exit; code != 0 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+}
+
+func TestLinesRejectLineBreaks(t *testing.T) {
+	requireCommand(t, "cat")
+
+	in := make(chan string)
+	out := make(chan string)
+	errs := make(chan string, 1)
+	c := New(Config{Path: "cat"})
+	c.In = in
+	c.Out = out
+	c.Err = errs
+
+	wait := goflow.Run(c)
+
+	go func() {
+		in <- "multi\nline"
+		in <- "single"
+		close(in)
+	}()
+
+	if actual := <-out; actual != "single" {
+		t.Errorf("'%s' != 'single'", actual)
+	}
+
+	<-wait
+
+	if msg := <-errs; !strings.Contains(msg, "line break") {
+		t.Errorf("Unexpected error '%s'", msg)
+	}
+}
+
+func TestLengthPrefixed(t *testing.T) {
+	requireCommand(t, "cat")
+
+	in := make(chan string)
+	out := make(chan string)
+	c := New(Config{Path: "cat", Framing: LengthPrefixed})
+	c.In = in
+	c.Out = out
+
+	wait := goflow.Run(c)
+
+	data := []string{"multi\nline", "", "tail"}
+
+	go func() {
+		for _, s := range data {
+			in <- s
+		}
+
+		close(in)
+	}()
+
+	for _, expected := range data {
+		if actual := <-out; actual != expected {
+			t.Errorf("'%s' != '%s'", actual, expected)
+		}
+	}
+
+	<-wait
+}
+
+func TestStderrAndExitCode(t *testing.T) {
+	requireCommand(t, "sh")
+
+	in := make(chan string)
+	errs := make(chan string, 1)
+	exit := make(chan int, 1)
+	c := New(Config{Path: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}})
+	c.In = in
+	c.Err = errs
+	c.Exit = exit
+
+	wait := goflow.Run(c)
+
+	if msg := <-errs; msg != "oops" {
+		t.Errorf("'%s' != 'oops'", msg)
+	}
+
+	if code := <-exit; code != 3 {
+		t.Errorf("%d != 3", code)
+	}
+
+	// The child is dead and not restarted, input must be drained
+	in <- "ignored"
+	close(in)
+	<-wait
+}
+
+func TestStopTimeout(t *testing.T) {
+	requireCommand(t, "sh")
+
+	// Output written after stdin is closed is not lost without a stop timeout
+	in := make(chan string)
+	out := make(chan string, 2)
+	c := New(Config{Path: "sh", Args: []string{"-c", "cat; sleep 0.2; echo late"}})
+	c.In = in
+	c.Out = out
+
+	wait := goflow.Run(c)
+
+	in <- "early"
+	close(in)
+	<-wait
+	close(out)
+
+	var res []string
+	for s := range out {
+		res = append(res, s)
+	}
+
+	if len(res) != 2 || res[1] != "late" {
+		t.Errorf("Unexpected output %v", res)
+	}
+
+	// A child which does not exit is killed after the stop timeout
+	in = make(chan string)
+	c = New(Config{Path: "sh", Args: []string{"-c", "cat; exec sleep 10"}, StopTimeout: 50 * time.Millisecond})
+	c.In = in
+
+	start := time.Now()
+	wait = goflow.Run(c)
+
+	close(in)
+	<-wait
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("The child was not killed in %s", d)
+	}
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	requireCommand(t, "sh")
+
+	in := make(chan string)
+	out := make(chan string)
+	exit := make(chan int)
+	// Echoes lines and fails on "fail"
+	c := New(Config{
+		Path:        "sh",
+		Args:        []string{"-c", "while read line; do echo \"got $line\"; [ \"$line\" = fail ] && exit 1; done"},
+		Restart:     RestartOnFailure,
+		MaxRestarts: 2,
+	})
+	c.In = in
+	c.Out = out
+	c.Exit = exit
+
+	wait := goflow.Run(c)
+
+	for i := 0; i < 3; i++ {
+		for _, s := range []string{"a", "fail"} {
+			in <- s
+
+			if actual := <-out; actual != "got "+s {
+				t.Errorf("'%s' != 'got %s'", actual, s)
+			}
+		}
+
+		if code := <-exit; code != 1 {
+			t.Errorf("%d != 1", code)
+		}
+	}
+
+	// Restart limit is reached, further input is discarded
+	in <- "b"
+	close(in)
+	<-wait
+}
+
+func TestStartFailureBackoff(t *testing.T) {
+	in := make(chan string)
+	errs := make(chan string)
+	c := New(Config{Path: "/nonexistent/command", Restart: RestartAlways})
+	c.In = in
+	c.Err = errs
+
+	var count int32
+
+	go func() {
+		for range errs {
+			atomic.AddInt32(&count, 1)
+		}
+	}()
+
+	wait := goflow.Run(c)
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Closing the input stops the retries
+	close(in)
+	<-wait
+	close(errs)
+
+	// Failures are retried after 10ms, 20ms, 40ms, 80ms and so on
+	if n := atomic.LoadInt32(&count); n == 0 || n > 10 {
+		t.Errorf("Unexpected number of start failures %d", n)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	f := goflow.NewFactory()
+
+	if err := Register(f, "cat", Config{Path: "cat"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	instance, err := f.Create("cat")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := instance.(*Subprocess); !ok {
+		t.Errorf("%+v is not a Subprocess", instance)
+	}
+
+	if err := f.Register("empty", Constructor(Config{})); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := f.Create("empty"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -0,0 +1,208 @@
+package timing
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers. Components use the real clock
+// by default, tests can inject a FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer sends the time on its channel once after a duration.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// RealClock is the system clock.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// clockOf returns the real clock if c is nil.
+func clockOf(c Clock) Clock {
+	if c == nil {
+		return RealClock
+	}
+
+	return c
+}
+
+// FakeClock is a clock which moves only when told to. Components in this package
+// create a new timer every time they start waiting, so BlockUntil tells that a
+// component has handled its input and the clock can be advanced.
+type FakeClock struct {
+	lock    sync.Locker
+	cond    *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+	created int // Number of timers created so far
+	synced  int // Value of created at the last synchronization
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	due    time.Time
+	seq    int // Creation number of the timer
+	c      chan time.Time
+	active bool
+}
+
+// NewFakeClock creates a fake clock showing a given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	lock := new(sync.Mutex)
+
+	return &FakeClock{
+		lock: lock,
+		cond: sync.NewCond(lock),
+		now:  now,
+	}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// NewTimer creates a timer which fires when the clock is advanced past its duration.
+// Timers with non-positive durations fire immediately.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.created++
+
+	t := &fakeTimer{
+		clock:  c,
+		due:    c.now.Add(d),
+		seq:    c.created,
+		c:      make(chan time.Time, 1),
+		active: true,
+	}
+
+	if d <= 0 {
+		t.fire(c.now)
+	} else {
+		c.timers = append(c.timers, t)
+	}
+
+	c.cond.Broadcast()
+
+	return t
+}
+
+// Advance moves the clock forward firing the timers which are due in their order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	c.synced = c.created
+
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].due.Before(c.timers[j].due) })
+
+	active := c.timers[:0]
+
+	for _, t := range c.timers {
+		if !t.active {
+			continue
+		}
+
+		if !t.due.After(c.now) {
+			t.fire(t.due)
+			continue
+		}
+
+		active = append(active, t)
+	}
+
+	c.timers = active
+}
+
+// BlockUntil waits until n timers created after the previous call of BlockUntil
+// or Advance are waiting to fire.
+func (c *FakeClock) BlockUntil(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.waiting() < n {
+		c.cond.Wait()
+	}
+
+	c.synced = c.created
+}
+
+// waiting counts active timers created since the last synchronization.
+// It must be called with the clock locked.
+func (c *FakeClock) waiting() int {
+	count := 0
+
+	for _, t := range c.timers {
+		if t.active && t.seq > c.synced {
+			count++
+		}
+	}
+
+	return count
+}
+
+// fire sends the time on the timer channel. It must be called with the clock locked.
+func (t *fakeTimer) fire(now time.Time) {
+	t.active = false
+	t.c <- now
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	t.clock.cond.Broadcast()
+
+	return wasActive
+}
+
+// timerAt creates a timer which fires at a given time.
+func timerAt(c Clock, t time.Time) Timer {
+	return c.NewTimer(t.Sub(c.Now()))
+}
+
+// stopTimer stops a timer if there is one.
+func stopTimer(t Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
@@ -0,0 +1,107 @@
+package timing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// expect receives a packet from a channel failing after a real timeout.
+func expect(t *testing.T, ch interface{}, want interface{}) {
+	t.Helper()
+
+	chosen, got, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(time.After(time.Second))},
+	})
+	if chosen == 1 {
+		t.Fatalf("Timed out waiting for %v", want)
+	}
+
+	if !reflect.DeepEqual(got.Interface(), want) {
+		t.Fatalf("%v != %v", got, want)
+	}
+}
+
+// start runs a component and returns a channel closed when it finishes.
+func start(c interface{ Process() }) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	return done
+}
+
+// wait waits for a component to finish.
+func wait(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Component has not finished")
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(epoch)
+
+	t1 := clock.NewTimer(2 * time.Second)
+	t2 := clock.NewTimer(time.Second)
+	t3 := clock.NewTimer(3 * time.Second)
+
+	if !t3.Stop() {
+		t.Error("Active timer has not been stopped")
+	}
+
+	clock.Advance(2 * time.Second)
+
+	if now := <-t2.C(); !now.Equal(epoch.Add(time.Second)) {
+		t.Errorf("%v != %v", now, epoch.Add(time.Second))
+	}
+
+	if now := <-t1.C(); !now.Equal(epoch.Add(2 * time.Second)) {
+		t.Errorf("%v != %v", now, epoch.Add(2*time.Second))
+	}
+
+	clock.Advance(time.Second)
+
+	select {
+	case <-t3.C():
+		t.Error("Stopped timer has fired")
+	default:
+	}
+
+	if t1.Stop() {
+		t.Error("Fired timer reported as active")
+	}
+
+	if now := <-clock.NewTimer(0).C(); !now.Equal(epoch.Add(3 * time.Second)) {
+		t.Errorf("%v != %v", now, epoch.Add(3*time.Second))
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	fired := make(chan time.Time)
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			fired <- <-clock.NewTimer(time.Second).C()
+		}
+	}()
+
+	for i := 1; i <= 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		if now := <-fired; !now.Equal(epoch.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("%v != %v", now, epoch.Add(time.Duration(i)*time.Second))
+		}
+	}
+}
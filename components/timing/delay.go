@@ -0,0 +1,111 @@
+package timing
+
+import "time"
+
+// Delay sends every packet to Out after the Duration has passed since it arrived.
+// The order of packets is preserved. Packets pass without delay if Duration is
+// not connected or closed without a value.
+type Delay struct {
+	Duration <-chan time.Duration `required:"false"`
+	In       <-chan interface{}
+	Out      chan<- interface{}
+	Clock    Clock // Real clock if nil
+}
+
+// durationOf reads a duration from a config port. It is 0 if the port is not connected.
+func durationOf(ch <-chan time.Duration) time.Duration {
+	if ch == nil {
+		return 0
+	}
+
+	return <-ch
+}
+
+// delayed is a packet waiting to be sent.
+type delayed struct {
+	packet interface{}
+	due    time.Time
+}
+
+// Process delays packets until In is closed and all packets are sent.
+func (c *Delay) Process() {
+	clock := clockOf(c.Clock)
+	d := durationOf(c.Duration)
+	in := c.In
+
+	var queue []delayed
+
+	for in != nil || len(queue) > 0 {
+		var timer Timer
+
+		var fired <-chan time.Time
+
+		if len(queue) > 0 {
+			timer = timerAt(clock, queue[0].due)
+			fired = timer.C()
+		}
+
+		select {
+		case p, ok := <-in:
+			if !ok {
+				in = nil
+				break
+			}
+
+			queue = append(queue, delayed{p, clock.Now().Add(d)})
+		case <-fired:
+			c.Out <- queue[0].packet
+			queue = queue[1:]
+		}
+
+		stopTimer(timer)
+	}
+}
+
+// Debounce sends a packet to Out only when no other packet has arrived during
+// the Duration after it, so a burst of packets results in its last packet.
+// A pending packet is sent when In is closed. Without a Duration every packet
+// is sent as soon as the next one does not follow it immediately.
+type Debounce struct {
+	Duration <-chan time.Duration `required:"false"`
+	In       <-chan interface{}
+	Out      chan<- interface{}
+	Clock    Clock // Real clock if nil
+}
+
+// Process debounces packets until In is closed.
+func (c *Debounce) Process() {
+	clock := clockOf(c.Clock)
+	d := durationOf(c.Duration)
+
+	var last *delayed
+
+	for {
+		var timer Timer
+
+		var fired <-chan time.Time
+
+		if last != nil {
+			timer = timerAt(clock, last.due)
+			fired = timer.C()
+		}
+
+		select {
+		case p, ok := <-c.In:
+			stopTimer(timer)
+
+			if !ok {
+				if last != nil {
+					c.Out <- last.packet
+				}
+
+				return
+			}
+
+			last = &delayed{p, clock.Now().Add(d)}
+		case <-fired:
+			c.Out <- last.packet
+			last = nil
+		}
+	}
+}
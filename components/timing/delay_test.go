@@ -0,0 +1,99 @@
+package timing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	d := make(chan time.Duration, 1)
+	in := make(chan interface{})
+	out := make(chan interface{})
+
+	d <- 10 * time.Second
+
+	done := start(&Delay{Duration: d, In: in, Out: out, Clock: clock})
+
+	in <- "a"
+
+	clock.BlockUntil(1)
+	clock.Advance(4 * time.Second)
+
+	in <- "b"
+
+	clock.BlockUntil(1)
+	clock.Advance(6 * time.Second)
+	expect(t, out, "a")
+
+	if now := clock.Now(); !now.Equal(epoch.Add(10 * time.Second)) {
+		t.Errorf("%v != %v", now, epoch.Add(10*time.Second))
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(4 * time.Second)
+	expect(t, out, "b")
+
+	// Pending packets are sent after In is closed
+	in <- "c"
+
+	close(in)
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	expect(t, out, "c")
+	wait(t, done)
+}
+
+func TestDebounce(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	d := make(chan time.Duration, 1)
+	in := make(chan interface{})
+	out := make(chan interface{})
+
+	d <- 5 * time.Second
+
+	done := start(&Debounce{Duration: d, In: in, Out: out, Clock: clock})
+
+	for _, p := range []string{"a", "b", "c"} {
+		in <- p
+
+		clock.BlockUntil(1)
+		clock.Advance(3 * time.Second)
+	}
+
+	clock.Advance(2 * time.Second)
+	expect(t, out, "c")
+
+	// The last packet is sent when In is closed
+	in <- "d"
+
+	close(in)
+	expect(t, out, "d")
+	wait(t, done)
+}
+
+func TestDelayWithoutDuration(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	in := make(chan interface{})
+	out := make(chan interface{})
+
+	// Packets pass without delay if Duration is not connected
+	done := start(&Delay{In: in, Out: out, Clock: clock})
+
+	for _, p := range []string{"a", "b"} {
+		in <- p
+		expect(t, out, p)
+	}
+
+	close(in)
+	wait(t, done)
+
+	in = make(chan interface{})
+	done = start(&Debounce{In: in, Out: out, Clock: clock})
+
+	in <- "c"
+	expect(t, out, "c")
+
+	close(in)
+	wait(t, done)
+}
@@ -0,0 +1,155 @@
+package timing
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate configures a token bucket: Count packets pass per Per period and up to
+// Burst packets may pass at once after a pause.
+type Rate struct {
+	Count int
+	Per   time.Duration // A second if 0
+	Burst int           // Count if 0
+}
+
+// Throttle limits the rate of packets with a token bucket configured by the
+// Rate IIP. Packets from In[i] are sent to Out[i] and all elements share the
+// bucket, so several streams can be limited together. Packets wait for a token,
+// so a throttled stream slows down its senders. Packets pass without limits
+// if Rate is not connected or closed without a value.
+type Throttle struct {
+	Rate  <-chan Rate `required:"false"`
+	In    []<-chan interface{}
+	Out   []chan<- interface{}
+	Clock Clock // Real clock if nil
+}
+
+// Process throttles packets until all elements of In are closed.
+func (c *Throttle) Process() {
+	var (
+		rate Rate
+		ok   bool
+	)
+
+	if c.Rate != nil {
+		rate, ok = <-c.Rate
+	}
+
+	b := newBucket(clockOf(c.Clock), rate, ok)
+	wg := new(sync.WaitGroup)
+
+	for i := range c.In {
+		if c.In[i] == nil {
+			continue
+		}
+
+		var out chan<- interface{}
+		if i < len(c.Out) {
+			out = c.Out[i]
+		}
+
+		wg.Add(1)
+
+		go func(in <-chan interface{}, out chan<- interface{}) {
+			defer wg.Done()
+
+			for p := range in {
+				b.take()
+
+				if out != nil {
+					out <- p
+				}
+			}
+		}(c.In[i], out)
+	}
+
+	wg.Wait()
+
+	for _, out := range c.Out {
+		if out != nil {
+			close(out)
+		}
+	}
+}
+
+// bucket is a token bucket shared by the elements of a Throttle.
+type bucket struct {
+	clock    Clock
+	lock     sync.Locker
+	limited  bool
+	interval time.Duration // Time to earn a token
+	size     time.Duration // Credit of a full bucket
+	credit   time.Duration // Tokens in the bucket measured in time
+	last     time.Time
+}
+
+func newBucket(clock Clock, rate Rate, limited bool) *bucket {
+	if rate.Count <= 0 {
+		limited = false
+	}
+
+	if rate.Per <= 0 {
+		rate.Per = time.Second
+	}
+
+	if rate.Burst <= 0 {
+		rate.Burst = rate.Count
+	}
+
+	b := &bucket{
+		clock:   clock,
+		lock:    new(sync.Mutex),
+		limited: limited,
+		last:    clock.Now(),
+	}
+
+	if limited {
+		b.interval = rate.Per / time.Duration(rate.Count)
+		b.size = b.interval * time.Duration(rate.Burst)
+		b.credit = b.size
+	}
+
+	return b
+}
+
+// take waits until a token is available and takes it.
+func (b *bucket) take() {
+	if !b.limited {
+		return
+	}
+
+	for {
+		wait := b.reserve()
+		if wait <= 0 {
+			return
+		}
+
+		timer := b.clock.NewTimer(wait)
+		<-timer.C()
+	}
+}
+
+// reserve takes a token if there is one, otherwise it returns the time until
+// the next token is earned.
+func (b *bucket) reserve() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := b.clock.Now()
+	if now.After(b.last) {
+		b.credit += now.Sub(b.last)
+		if b.credit > b.size {
+			b.credit = b.size
+		}
+
+		b.last = now
+	}
+
+	if b.credit >= b.interval {
+		b.credit -= b.interval
+		return 0
+	}
+
+	return b.interval - b.credit
+}
@@ -0,0 +1,77 @@
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestThrottle(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	rate := make(chan Rate, 1)
+	in := []chan interface{}{make(chan interface{}), make(chan interface{})}
+	out := []chan interface{}{make(chan interface{}), make(chan interface{})}
+
+	rate <- Rate{Count: 2, Per: time.Second}
+
+	done := start(&Throttle{
+		Rate:  rate,
+		In:    []<-chan interface{}{in[0], in[1]},
+		Out:   []chan<- interface{}{out[0], out[1]},
+		Clock: clock,
+	})
+
+	// The burst passes at once
+	in[0] <- "a"
+	expect(t, out[0], "a")
+	in[0] <- "b"
+	expect(t, out[0], "b")
+
+	// Then a token is earned every 500ms shared by both streams
+	in[0] <- "c"
+
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	expect(t, out[0], "c")
+
+	in[1] <- "d"
+
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	expect(t, out[1], "d")
+
+	close(in[0])
+	close(in[1])
+	wait(t, done)
+
+	for i := range out {
+		if _, ok := <-out[i]; ok {
+			t.Errorf("Out[%d] is not closed", i)
+		}
+	}
+}
+
+func TestThrottleUnlimited(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Throttle) }, []goflowtest.Case{
+		{
+			Name: "no rate",
+			In: map[string]interface{}{
+				"Rate":  []Rate{},
+				"In[0]": []interface{}{1, 2, 3},
+				"In[1]": []interface{}{4},
+			},
+			Out: map[string]interface{}{
+				"Out[0]": []interface{}{1, 2, 3},
+				"Out[1]": []interface{}{4},
+			},
+		},
+		{
+			Name: "unconnected rate",
+			In: map[string]interface{}{
+				"In[0]": []interface{}{1, 2, 3},
+			},
+			Out: map[string]interface{}{"Out[0]": []interface{}{1, 2, 3}},
+		},
+	})
+}
@@ -0,0 +1,107 @@
+package timing
+
+import "time"
+
+// Ticker sends the current time to Out every Interval until In is closed.
+// Packets received on In are ignored. Ticks which cannot be sent in time
+// because Out is blocked are skipped. Nothing is sent without an Interval.
+type Ticker struct {
+	Interval <-chan time.Duration `required:"false"`
+	In       <-chan interface{}
+	Out      chan<- time.Time
+	Clock    Clock // Real clock if nil
+}
+
+// Process sends ticks until In is closed.
+func (c *Ticker) Process() {
+	clock := clockOf(c.Clock)
+
+	interval := durationOf(c.Interval)
+	if interval <= 0 {
+		// Nothing to tick, wait for the end of the stream
+		for range c.In {
+		}
+
+		return
+	}
+
+	next := clock.Now().Add(interval)
+
+	for {
+		timer := timerAt(clock, next)
+
+		select {
+		case _, ok := <-c.In:
+			timer.Stop()
+
+			if !ok {
+				return
+			}
+		case now := <-timer.C():
+			c.Out <- now
+
+			for now := clock.Now(); !next.After(now); {
+				next = next.Add(interval)
+			}
+		}
+	}
+}
+
+// Timeout passes packets from In to Out and sends the time to Expired when no
+// packet has arrived during the Duration since the start or since the last
+// packet. Expired is sent once per pause and the timeout starts again
+// with the next packet. Packets keep passing while the time waits to be taken
+// from Expired, and it is not sent at all if Expired is not connected.
+// Without a Duration packets pass and never expire.
+type Timeout struct {
+	Duration <-chan time.Duration `required:"false"`
+	In       <-chan interface{}
+	Out      chan<- interface{}
+	Expired  chan<- time.Time `required:"false"`
+	Clock    Clock            // Real clock if nil
+}
+
+// Process watches packets until In is closed.
+func (c *Timeout) Process() {
+	clock := clockOf(c.Clock)
+	d := durationOf(c.Duration)
+	armed := d > 0
+	due := clock.Now().Add(d)
+
+	var (
+		expired chan<- time.Time // Set while an expiry waits to be sent
+		expiry  time.Time
+	)
+
+	for {
+		var timer Timer
+
+		var fired <-chan time.Time
+
+		if armed {
+			timer = timerAt(clock, due)
+			fired = timer.C()
+		}
+
+		select {
+		case p, ok := <-c.In:
+			stopTimer(timer)
+
+			if !ok {
+				return
+			}
+
+			armed = d > 0
+			due = clock.Now().Add(d)
+
+			c.Out <- p
+		case now := <-fired:
+			armed = false
+			expired, expiry = c.Expired, now
+		case expired <- expiry:
+			stopTimer(timer)
+
+			expired = nil
+		}
+	}
+}
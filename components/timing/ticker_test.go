@@ -0,0 +1,140 @@
+package timing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	interval := make(chan time.Duration, 1)
+	in := make(chan interface{})
+	out := make(chan time.Time)
+
+	interval <- time.Second
+
+	done := start(&Ticker{Interval: interval, In: in, Out: out, Clock: clock})
+
+	for i := 1; i <= 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		expect(t, out, epoch.Add(time.Duration(i)*time.Second))
+	}
+
+	// Packets do not affect ticks
+	in <- "x"
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	expect(t, out, epoch.Add(3*time.Second))
+
+	// Missed ticks are skipped
+	clock.BlockUntil(1)
+	clock.Advance(2500 * time.Millisecond)
+	expect(t, out, epoch.Add(4*time.Second))
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	expect(t, out, epoch.Add(6*time.Second))
+
+	close(in)
+	wait(t, done)
+}
+
+func TestTimeout(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	d := make(chan time.Duration, 1)
+	in := make(chan interface{})
+	out := make(chan interface{})
+	expired := make(chan time.Time)
+
+	d <- 5 * time.Second
+
+	done := start(&Timeout{Duration: d, In: in, Out: out, Expired: expired, Clock: clock})
+
+	// Silence since the start
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expect(t, expired, epoch.Add(5*time.Second))
+
+	in <- "a"
+	expect(t, out, "a")
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Second)
+
+	in <- "b"
+	expect(t, out, "b")
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Second)
+
+	select {
+	case now := <-expired:
+		t.Errorf("Expired too early at %v", now)
+	default:
+	}
+
+	clock.Advance(2 * time.Second)
+	expect(t, expired, epoch.Add(13*time.Second))
+
+	close(in)
+	wait(t, done)
+}
+
+func TestTimeoutExpiredNotTaken(t *testing.T) {
+	for _, expired := range []chan time.Time{nil, make(chan time.Time)} {
+		clock := NewFakeClock(epoch)
+		d := make(chan time.Duration, 1)
+		in := make(chan interface{})
+		out := make(chan interface{})
+
+		d <- time.Second
+
+		done := start(&Timeout{Duration: d, In: in, Out: out, Expired: expired, Clock: clock})
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		// Nobody reads Expired, packets still pass
+		for _, p := range []string{"a", "b"} {
+			in <- p
+			expect(t, out, p)
+		}
+
+		close(in)
+		wait(t, done)
+	}
+}
+
+func TestTickerWithoutInterval(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	in := make(chan interface{})
+	out := make(chan time.Time)
+
+	// Nothing is ticked if Interval is not connected
+	done := start(&Ticker{In: in, Out: out, Clock: clock})
+
+	in <- "ignored"
+
+	close(in)
+	wait(t, done)
+}
+
+func TestTimeoutWithoutDuration(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	in := make(chan interface{})
+	out := make(chan interface{})
+	expired := make(chan time.Time, 1)
+
+	// Packets pass and never expire if Duration is not connected
+	done := start(&Timeout{In: in, Out: out, Expired: expired, Clock: clock})
+
+	in <- "a"
+	expect(t, out, "a")
+	clock.Advance(time.Hour)
+
+	close(in)
+	wait(t, done)
+
+	if len(expired) > 0 {
+		t.Errorf("Unexpected expiry at %v", <-expired)
+	}
+}
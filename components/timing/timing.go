@@ -0,0 +1,61 @@
+// Package timing provides components which delay, limit and group packets
+// in time. Packets are passed as interface{} like in the core library.
+//
+// Durations and rates are usually sent as IIPs:
+//
+//	n.AddIIP("delay", "Duration", 100*time.Millisecond)
+//
+// Every component has a Clock field. It is the real clock by default, tests
+// can set a FakeClock to control time deterministically.
+package timing
+
+import "github.com/trustmaster/goflow"
+
+// Library is the namespace of the components in a factory.
+const Library = "timing"
+
+// components lists the components of the package with their annotations.
+var components = []struct {
+	name        string
+	constructor func(clock Clock) interface{}
+	annotation  goflow.Annotation
+}{
+	{"Delay", func(clock Clock) interface{} { return &Delay{Clock: clock} },
+		goflow.Annotation{Description: "Sends packets after a delay", Icon: "hourglass"}},
+	{"Debounce", func(clock Clock) interface{} { return &Debounce{Clock: clock} },
+		goflow.Annotation{Description: "Sends the last packet of a burst after a pause", Icon: "compress"}},
+	{"Throttle", func(clock Clock) interface{} { return &Throttle{Clock: clock} },
+		goflow.Annotation{Description: "Limits the rate of packets with a token bucket", Icon: "tachometer"}},
+	{"Ticker", func(clock Clock) interface{} { return &Ticker{Clock: clock} },
+		goflow.Annotation{Description: "Sends the time at intervals until its inport is closed", Icon: "clock-o"}},
+	{"Timeout", func(clock Clock) interface{} { return &Timeout{Clock: clock} },
+		goflow.Annotation{Description: "Signals when no packet arrives in time", Icon: "bell"}},
+	{"Window", func(clock Clock) interface{} { return &Window{Clock: clock} },
+		goflow.Annotation{Description: "Groups packets into tumbling or sliding time windows", Icon: "th-large"}},
+}
+
+// Register registers the components in a factory as "timing/Delay", "timing/Ticker", etc.
+func Register(f *goflow.Factory) error {
+	return RegisterClock(f, RealClock)
+}
+
+// RegisterClock registers the components using a given clock.
+func RegisterClock(f *goflow.Factory, clock Clock) error {
+	for _, c := range components {
+		name := Library + "/" + c.name
+		constructor := c.constructor
+
+		err := f.Register(name, func() (interface{}, error) {
+			return constructor(clock), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := f.Annotate(name, c.annotation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
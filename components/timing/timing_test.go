@@ -0,0 +1,38 @@
+package timing
+
+import (
+	"testing"
+
+	"github.com/trustmaster/goflow"
+)
+
+func TestRegister(t *testing.T) {
+	f := goflow.NewFactory()
+	clock := NewFakeClock(epoch)
+
+	if err := RegisterClock(f, clock); err != nil {
+		t.Error(err)
+		return
+	}
+
+	list := f.List("timing/")
+	if len(list) != len(components) {
+		t.Errorf("%d != %d", len(list), len(components))
+	}
+
+	for _, info := range list {
+		if info.Description == "" {
+			t.Errorf("Component %s is not annotated", info.Name)
+		}
+	}
+
+	c, err := f.Create("timing/Delay")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if d, ok := c.(*Delay); !ok || d.Clock != clock {
+		t.Errorf("Component does not use the clock: %#v", c)
+	}
+}
@@ -0,0 +1,110 @@
+package timing
+
+import "time"
+
+// WindowSpec configures a Window. Windows of Size length start every Slide.
+// If Slide is 0 or not shorter than Size, windows are tumbling: every packet
+// belongs to exactly one window. Shorter slides make overlapping sliding windows.
+type WindowSpec struct {
+	Size  time.Duration
+	Slide time.Duration
+}
+
+// Window collects packets arriving during time windows and sends them to Out
+// as a batch at the end of every window, so they can be aggregated e.g. by a
+// core/Map. Empty windows are not sent. When In is closed, packets of the
+// window in progress are sent. Without a spec all packets are sent as one batch
+// when In is closed.
+type Window struct {
+	Spec  <-chan WindowSpec `required:"false"`
+	In    <-chan interface{}
+	Out   chan<- []interface{}
+	Clock Clock // Real clock if nil
+}
+
+// stamped is a packet with its arrival time.
+type stamped struct {
+	packet interface{}
+	at     time.Time
+}
+
+// Process collects windows until In is closed.
+func (c *Window) Process() {
+	clock := clockOf(c.Clock)
+
+	var spec WindowSpec
+	if c.Spec != nil {
+		spec = <-c.Spec
+	}
+
+	if spec.Slide <= 0 || spec.Slide > spec.Size {
+		spec.Slide = spec.Size
+	}
+
+	if spec.Size <= 0 {
+		var batch []interface{}
+		for p := range c.In {
+			batch = append(batch, p)
+		}
+
+		if len(batch) > 0 {
+			c.Out <- batch
+		}
+
+		return
+	}
+
+	// The window in progress lasts from end-Size inclusive to end exclusive
+	end := clock.Now().Add(spec.Slide)
+
+	var buf []stamped
+
+	for {
+		timer := timerAt(clock, end)
+
+		select {
+		case p, ok := <-c.In:
+			now := clock.Now()
+
+			timer.Stop()
+
+			if !ok {
+				c.send(buf, end.Add(-spec.Size), now.Add(1))
+				return
+			}
+
+			buf = append(buf, stamped{p, now})
+		case <-timer.C():
+			c.send(buf, end.Add(-spec.Size), end)
+
+			end = end.Add(spec.Slide)
+			buf = since(buf, end.Add(-spec.Size))
+		}
+	}
+}
+
+// send sends the packets which have arrived from start inclusive to end exclusive as a batch.
+func (c *Window) send(buf []stamped, start, end time.Time) {
+	var batch []interface{}
+
+	for _, s := range since(buf, start) {
+		if !s.at.Before(end) {
+			break
+		}
+
+		batch = append(batch, s.packet)
+	}
+
+	if len(batch) > 0 {
+		c.Out <- batch
+	}
+}
+
+// since drops packets which have arrived before a given time.
+func since(buf []stamped, start time.Time) []stamped {
+	for len(buf) > 0 && buf[0].at.Before(start) {
+		buf = buf[1:]
+	}
+
+	return buf
+}
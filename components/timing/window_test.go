@@ -0,0 +1,94 @@
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestWindowTumbling(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	spec := make(chan WindowSpec, 1)
+	in := make(chan interface{})
+	out := make(chan []interface{})
+
+	spec <- WindowSpec{Size: 10 * time.Second}
+
+	done := start(&Window{Spec: spec, In: in, Out: out, Clock: clock})
+
+	clock.BlockUntil(1)
+
+	in <- "a"
+
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+
+	in <- "b"
+
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expect(t, out, []interface{}{"a", "b"})
+
+	// Empty windows are skipped
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	clock.BlockUntil(1)
+
+	in <- "c"
+
+	close(in)
+	expect(t, out, []interface{}{"c"})
+	wait(t, done)
+}
+
+func TestWindowSliding(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	spec := make(chan WindowSpec, 1)
+	in := make(chan interface{})
+	out := make(chan []interface{})
+
+	spec <- WindowSpec{Size: 10 * time.Second, Slide: 5 * time.Second}
+
+	done := start(&Window{Spec: spec, In: in, Out: out, Clock: clock})
+
+	clock.BlockUntil(1)
+
+	in <- "a"
+
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expect(t, out, []interface{}{"a"})
+	clock.BlockUntil(1)
+
+	in <- "b"
+
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expect(t, out, []interface{}{"a", "b"})
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expect(t, out, []interface{}{"b"})
+	clock.BlockUntil(1)
+
+	close(in)
+	wait(t, done)
+}
+
+func TestWindowWithoutSpec(t *testing.T) {
+	goflowtest.RunCases(t, func() interface{} { return new(Window) }, []goflowtest.Case{
+		{
+			Name: "single batch",
+			In: map[string]interface{}{
+				"Spec": []WindowSpec{},
+				"In":   []interface{}{1, 2, 3},
+			},
+			Out: map[string]interface{}{"Out": [][]interface{}{{1, 2, 3}}},
+		},
+		{
+			Name: "unconnected spec",
+			In:   map[string]interface{}{"In": []interface{}{1, 2, 3}},
+			Out:  map[string]interface{}{"Out": [][]interface{}{{1, 2, 3}}},
+		},
+	})
+}
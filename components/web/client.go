@@ -0,0 +1,83 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// errNoURL is reported for requests without a URL.
+var errNoURL = errors.New("no URL")
+
+// HTTPClient performs requests received on In and sends the responses to Out
+// with the IDs of the requests. Requests which fail are reported to Err as
+// *RequestError. The optional Timeout IIP limits the time of every request.
+// Requests are performed one at a time in the order of arrival.
+type HTTPClient struct {
+	Timeout <-chan time.Duration
+	In      <-chan Request
+	Out     chan<- Response
+	Err     chan<- error
+	Client  *http.Client // http.DefaultClient if nil
+}
+
+// Process performs requests until In is closed.
+func (c *HTTPClient) Process() {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if c.Timeout != nil {
+		if d, ok := <-c.Timeout; ok && d > 0 {
+			withTimeout := *client
+			withTimeout.Timeout = d
+			client = &withTimeout
+		}
+	}
+
+	for req := range c.In {
+		resp, err := do(client, req)
+		if err != nil {
+			sendErr(c.Err, &RequestError{req.ID, err})
+			continue
+		}
+
+		c.Out <- resp
+	}
+}
+
+func do(client *http.Client, req Request) (Response, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	if req.URL == nil {
+		return Response{}, errNoURL
+	}
+
+	r, err := http.NewRequest(method, req.URL.String(), bytes.NewReader(req.Body))
+	if err != nil {
+		return Response{}, err
+	}
+
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{ID: req.ID, Status: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
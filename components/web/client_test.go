@@ -0,0 +1,70 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trustmaster/goflow/goflowtest"
+)
+
+func TestHTTPClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+	}))
+	defer ts.Close()
+
+	get, err := NewRequest("", ts.URL+"/a", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	get.ID = 1
+	get.Header.Set("X-Token", "secret")
+
+	post, err := NewRequest(http.MethodPost, ts.URL+"/b", []byte("data"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	post.ID = 2
+
+	res, err := goflowtest.New(&HTTPClient{Client: ts.Client()}).
+		In("In", []Request{get, {ID: 3}, post}).
+		Out("Out", "Err").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	resps := res["Out"].([]Response)
+	if len(resps) != 2 {
+		t.Errorf("Unexpected responses %v", resps)
+		return
+	}
+
+	for i, want := range []string{"GET /a ", "POST /b data"} {
+		if resps[i].ID != uint64(i+1) || resps[i].Status != http.StatusOK || string(resps[i].Body) != want {
+			t.Errorf("Unexpected response %d %d %s", resps[i].ID, resps[i].Status, resps[i].Body)
+		}
+	}
+
+	if token := resps[0].Header.Get("X-Token"); token != "secret" {
+		t.Errorf("%s != secret", token)
+	}
+
+	errs := res["Err"].([]error)
+
+	var reqErr *RequestError
+	if len(errs) != 1 || !errors.As(errs[0], &reqErr) || reqErr.ID != 3 || reqErr.Err != errNoURL {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
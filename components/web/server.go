@@ -0,0 +1,237 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ShutdownTimeout limits the time HTTPServer waits for active connections when it stops.
+const ShutdownTimeout = 5 * time.Second
+
+// DefaultMaxBodySize limits the size of request bodies HTTPServer reads
+// unless its MaxBodySize is set.
+const DefaultMaxBodySize = 10 << 20
+
+// HTTPServer sends incoming requests to Out and completes them with responses
+// received on Response which have the same ID. It listens on the address
+// received on Addr, usually an IIP, and sends the actual address to Listening
+// if it is connected, e.g. when the port is chosen by the system with ":0".
+//
+// HTTPServer is also an http.Handler, so it can be mounted in another server
+// or in httptest without an address.
+//
+// The server stops when Stop receives a packet or is closed, or when Response
+// is closed. Requests which have not been answered by then get 503 Service
+// Unavailable. Requests with bodies larger than MaxBodySize get 413 Request
+// Entity Too Large.
+type HTTPServer struct {
+	Addr      <-chan string
+	Response  <-chan Response
+	Stop      <-chan interface{}
+	Out       chan<- Request
+	Listening chan<- string
+	Err       chan<- error
+
+	MaxBodySize int64 // DefaultMaxBodySize if 0
+
+	init    sync.Once
+	lock    sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan Response
+	active  sync.WaitGroup // Handlers which may send to Out
+	stopped chan struct{}
+}
+
+func (c *HTTPServer) setup() {
+	c.init.Do(func() {
+		c.pending = make(map[uint64]chan Response)
+		c.stopped = make(chan struct{})
+	})
+}
+
+// Process serves requests until the server is stopped.
+func (c *HTTPServer) Process() {
+	c.setup()
+
+	var srv *http.Server
+
+	if c.Addr != nil {
+		if addr, ok := <-c.Addr; ok {
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				sendErr(c.Err, fmt.Errorf("web: %w", err))
+				c.shutdown(nil)
+
+				return
+			}
+
+			if c.Listening != nil {
+				c.Listening <- l.Addr().String()
+			}
+
+			srv = &http.Server{Handler: c}
+
+			go func() {
+				if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					sendErr(c.Err, fmt.Errorf("web: %w", err))
+				}
+			}()
+		}
+	}
+
+	for {
+		select {
+		case resp, ok := <-c.Response:
+			if !ok {
+				c.shutdown(srv)
+				return
+			}
+
+			c.complete(resp)
+		case <-c.Stop:
+			c.shutdown(srv)
+			return
+		}
+	}
+}
+
+// complete passes a response to the handler of its request.
+func (c *HTTPServer) complete(resp Response) {
+	c.lock.Lock()
+	ch, ok := c.pending[resp.ID]
+	delete(c.pending, resp.ID)
+	c.lock.Unlock()
+
+	if !ok {
+		sendErr(c.Err, &RequestError{resp.ID, errors.New("request is not pending")})
+		return
+	}
+
+	ch <- resp
+}
+
+// shutdown stops accepting requests and waits for the handlers to leave Out.
+func (c *HTTPServer) shutdown(srv *http.Server) {
+	c.lock.Lock()
+	close(c.stopped)
+	c.lock.Unlock()
+
+	if srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			sendErr(c.Err, fmt.Errorf("web: %w", err))
+		}
+	}
+
+	c.active.Wait()
+}
+
+// ServeHTTP sends a request to Out and writes the response to it.
+func (c *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.setup()
+
+	limit := c.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		status := http.StatusBadRequest
+		if int64(len(body)) == limit {
+			// The reader fails only after the whole limit has been read
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+
+	ch := make(chan Response, 1)
+
+	c.lock.Lock()
+
+	select {
+	case <-c.stopped:
+		c.lock.Unlock()
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+		return
+	default:
+	}
+
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.active.Add(1)
+	c.lock.Unlock()
+
+	resp, ok := c.exchange(r.Context(), Request{
+		ID:         id,
+		Method:     r.Method,
+		URL:        r.URL,
+		Header:     r.Header,
+		Body:       body,
+		RemoteAddr: r.RemoteAddr,
+	}, ch)
+	if !ok {
+		c.lock.Lock()
+		delete(c.pending, id)
+		c.lock.Unlock()
+
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+		return
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+
+	if resp.Status == 0 {
+		resp.Status = http.StatusOK
+	}
+
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// exchange sends a request to Out and waits for its response. It fails if the
+// client goes away or the server stops.
+func (c *HTTPServer) exchange(ctx context.Context, req Request, ch <-chan Response) (Response, bool) {
+	if !c.send(ctx, req) {
+		return Response{}, false
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, true
+	case <-ctx.Done():
+		return Response{}, false
+	case <-c.stopped:
+		return Response{}, false
+	}
+}
+
+// send sends a request to Out unless the client goes away or the server stops.
+func (c *HTTPServer) send(ctx context.Context, req Request) bool {
+	defer c.active.Done()
+
+	select {
+	case c.Out <- req:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-c.stopped:
+		return false
+	}
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upperHandler answers requests with their bodies in upper case.
+func upperHandler(requests <-chan Request, responses chan<- Response) {
+	for req := range requests {
+		resp := req.Reply(http.StatusCreated, bytes.ToUpper(req.Body))
+		resp.Header.Set("X-Path", req.URL.Path)
+		responses <- resp
+	}
+}
+
+func TestHTTPServerHandler(t *testing.T) {
+	requests := make(chan Request)
+	responses := make(chan Response)
+	stop := make(chan interface{})
+	done := make(chan struct{})
+
+	c := &HTTPServer{Response: responses, Stop: stop, Out: requests}
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	go upperHandler(requests, responses)
+
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/shout", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || string(body) != "HELLO" || resp.Header.Get("X-Path") != "/shout" {
+		t.Errorf("Unexpected response %d %s %v", resp.StatusCode, body, resp.Header)
+	}
+
+	close(stop)
+	<-done
+
+	// A stopped server does not accept requests
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("%d != %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHTTPServerListen(t *testing.T) {
+	addr := make(chan string, 1)
+	listening := make(chan string, 1)
+	requests := make(chan Request)
+	responses := make(chan Response)
+	done := make(chan struct{})
+
+	addr <- "127.0.0.1:0"
+	close(addr)
+
+	c := &HTTPServer{Addr: addr, Response: responses, Out: requests, Listening: listening}
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	go func() {
+		req := <-requests
+		responses <- req.Reply(0, []byte(req.Method))
+
+		// Closing Response stops the server
+		close(responses)
+	}()
+
+	resp, err := http.Get("http://" + <-listening + "/")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != http.MethodGet {
+		t.Errorf("Unexpected response %d %s", resp.StatusCode, body)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Server has not stopped")
+	}
+}
+
+func TestHTTPServerUnknownResponse(t *testing.T) {
+	responses := make(chan Response)
+	errs := make(chan error)
+	done := make(chan struct{})
+
+	c := &HTTPServer{Response: responses, Out: make(chan Request), Err: errs}
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	responses <- Response{ID: 42}
+
+	var reqErr *RequestError
+	if err := <-errs; !errors.As(err, &reqErr) || reqErr.ID != 42 {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	close(responses)
+	<-done
+}
+
+func TestHTTPServerMaxBodySize(t *testing.T) {
+	requests := make(chan Request)
+	responses := make(chan Response)
+	done := make(chan struct{})
+
+	c := &HTTPServer{Response: responses, Out: requests, MaxBodySize: 5}
+
+	go func() {
+		c.Process()
+		close(done)
+	}()
+
+	go upperHandler(requests, responses)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "HELLO" {
+		t.Errorf("Unexpected response %d %s", rec.Code, rec.Body)
+	}
+
+	// Larger bodies are rejected without reaching the graph
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello!")))
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%d != %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	close(responses)
+	<-done
+}
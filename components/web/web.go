@@ -0,0 +1,105 @@
+// Package web provides components which serve and perform HTTP requests.
+//
+// HTTPServer sends every incoming request to its Out port and waits for a
+// Response with the same ID on its Response port, so a handler is a graph:
+//
+//	INPORT=server.ADDR:ADDR
+//	server(web/HTTPServer) OUT -> IN handler(app/Handler) OUT -> RESPONSE server
+//
+// HTTPClient performs requests and returns responses with the IDs of the
+// requests, so a client can answer server requests directly.
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/trustmaster/goflow"
+)
+
+// Library is the namespace of the components in a factory.
+const Library = "web"
+
+// Request is an HTTP request. The ID identifies the request a Response belongs to.
+type Request struct {
+	ID         uint64
+	Method     string
+	URL        *url.URL
+	Header     http.Header
+	Body       []byte
+	RemoteAddr string // Set by the server
+}
+
+// NewRequest creates a request for HTTPClient.
+func NewRequest(method, rawURL string, body []byte) (Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Request{}, err
+	}
+
+	return Request{Method: method, URL: u, Header: make(http.Header), Body: body}, nil
+}
+
+// Reply creates a response to the request.
+func (r Request) Reply(status int, body []byte) Response {
+	return Response{ID: r.ID, Status: status, Header: make(http.Header), Body: body}
+}
+
+// Response is an HTTP response to the request with the same ID.
+type Response struct {
+	ID     uint64
+	Status int // http.StatusOK if 0
+	Header http.Header
+	Body   []byte
+}
+
+// RequestError is an error of a request.
+type RequestError struct {
+	ID  uint64
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("web: request %d: %s", e.ID, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// components lists the components of the package with their annotations.
+var components = []struct {
+	name        string
+	constructor goflow.Constructor
+	annotation  goflow.Annotation
+}{
+	{"HTTPServer", func() (interface{}, error) { return new(HTTPServer), nil },
+		goflow.Annotation{Description: "Serves HTTP requests with a graph", Icon: "server"}},
+	{"HTTPClient", func() (interface{}, error) { return new(HTTPClient), nil },
+		goflow.Annotation{Description: "Performs HTTP requests", Icon: "globe"}},
+}
+
+// Register registers the components in a factory as "web/HTTPServer" and "web/HTTPClient".
+func Register(f *goflow.Factory) error {
+	for _, c := range components {
+		name := Library + "/" + c.name
+
+		if err := f.Register(name, c.constructor); err != nil {
+			return err
+		}
+
+		if err := f.Annotate(name, c.annotation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendErr sends an error to an optional Err outport.
+func sendErr(ch chan<- error, err error) {
+	if ch != nil {
+		ch <- err
+	}
+}
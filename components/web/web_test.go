@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/trustmaster/goflow"
+)
+
+// echo answers requests with their paths.
+type echo struct {
+	In  <-chan Request
+	Out chan<- Response
+}
+
+func (c *echo) Process() {
+	for req := range c.In {
+		c.Out <- req.Reply(http.StatusOK, []byte(req.URL.Path))
+	}
+}
+
+func TestRegister(t *testing.T) {
+	f := goflow.NewFactory()
+
+	if err := Register(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if list := f.List("web/"); len(list) != len(components) {
+		t.Errorf("%d != %d", len(list), len(components))
+	}
+
+	if err := f.Register("test/Echo", func() (interface{}, error) { return new(echo), nil }); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// A graph serves requests
+	n, err := goflow.ParseFBP([]byte(`
+INPORT=server.ADDR:ADDR
+INPORT=server.STOP:STOP
+OUTPORT=server.LISTENING:LISTENING
+server(web/HTTPServer) OUT -> IN handler(test/Echo) OUT -> RESPONSE server
+`), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	addr := make(chan string, 1)
+	stop := make(chan interface{})
+	listening := make(chan string, 1)
+
+	for name, ch := range map[string]interface{}{"Addr": addr, "Stop": stop} {
+		if err := n.SetInPort(name, ch); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := n.SetOutPort("Listening", listening); err != nil {
+		t.Error(err)
+		return
+	}
+
+	addr <- "127.0.0.1:0"
+	close(addr)
+
+	wait := goflow.Run(n)
+
+	resp, err := http.Get("http://" + <-listening + "/hello")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "/hello" {
+		t.Errorf("%s != /hello", body)
+	}
+
+	close(stop)
+	<-wait
+}
@@ -1,7 +1,10 @@
 package goflow
 
 import (
+	"errors"
+	"strconv"
 	"sync"
+	"time"
 )
 
 // doubler doubles its input.
@@ -16,6 +19,69 @@ func (c *doubler) Process() {
 	}
 }
 
+// slowDoubler doubles its input in a helper goroutine after a delay.
+type slowDoubler struct {
+	Acks
+	In    <-chan int
+	Out   chan<- int
+	delay time.Duration
+}
+
+func (c *slowDoubler) Process() {
+	for i := range c.In {
+		res := make(chan int)
+
+		go func(i int) {
+			time.Sleep(c.delay)
+			res <- 2 * i
+		}(i)
+
+		c.Out <- <-res
+		c.Ack("In")
+	}
+}
+
+// scaler multiplies its input by the Factor, 1 if it is not connected.
+type scaler struct {
+	Factor <-chan int `required:"false"`
+	In     <-chan int
+	Out    chan<- int
+}
+
+func (c *scaler) Process() {
+	factor := 1
+	if c.Factor != nil {
+		factor = <-c.Factor
+	}
+
+	for i := range c.In {
+		c.Out <- factor * i
+	}
+}
+
+// pair sums a packet from First with the next one from Second, reading them in order.
+type pair struct {
+	First  <-chan int
+	Second <-chan int
+	Out    chan<- int
+}
+
+func (c *pair) Process() {
+	for {
+		a, ok := <-c.First
+		if !ok {
+			return
+		}
+
+		b, ok := <-c.Second
+		if !ok {
+			return
+		}
+
+		c.Out <- a + b
+	}
+}
+
 // doubleOnce is a non-resident version of doubler.
 type doubleOnce struct {
 	In  <-chan int
@@ -183,6 +249,179 @@ func (c *irouter) Process() {
 	wg.Wait()
 }
 
+// panicker panics on negative input.
+type panicker struct {
+	In  <-chan int
+	Out chan<- int
+}
+
+func (c *panicker) Process() {
+	for i := range c.In {
+		if i < 0 {
+			panic("negative input")
+		}
+
+		c.Out <- i
+	}
+}
+
+// failer stops with an error on negative input.
+type failer struct {
+	In  <-chan int
+	Out chan<- int
+
+	err error
+}
+
+func (c *failer) Process() {
+	c.err = nil
+
+	for i := range c.In {
+		if i < 0 {
+			c.err = errors.New("negative input")
+			return
+		}
+
+		c.Out <- i
+	}
+}
+
+func (c *failer) ProcessError() error {
+	return c.err
+}
+
+// rejecter reports negative input to its Err port and passes the rest.
+type rejecter struct {
+	In  <-chan int
+	Out chan<- int
+	Err chan<- error
+}
+
+func (c *rejecter) Process() {
+	for i := range c.In {
+		if i < 0 {
+			c.Err <- &PacketError{Packet: i, Err: errors.New("negative input")}
+			continue
+		}
+
+		c.Out <- i
+	}
+}
+
+// tagger sets a header of envelopes it forwards.
+type tagger struct {
+	Tag <-chan string
+	In  <-chan *IP
+	Out chan<- *IP
+}
+
+func (c *tagger) Process() {
+	tag := <-c.Tag
+
+	for ip := range c.In {
+		c.Out <- ip.Derive(ip.Value).SetHeader("tag", tag)
+	}
+}
+
+// summer sends running sums of its input and keeps the sum in checkpoints.
+type summer struct {
+	Acks
+	In   <-chan int
+	Out  chan<- int
+	lock sync.Mutex
+	sum  int
+}
+
+func (c *summer) Process() {
+	for i := range c.In {
+		c.lock.Lock()
+		c.sum += i
+		sum := c.sum
+		c.lock.Unlock()
+
+		c.Out <- sum
+		c.Ack("In")
+	}
+}
+
+func (c *summer) Snapshot() ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return []byte(strconv.Itoa(c.sum)), nil
+}
+
+func (c *summer) Restore(state []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	sum, err := strconv.Atoi(string(state))
+	c.sum = sum
+
+	return err
+}
+
+// accumulator sends running sums of its input starting from the Start value
+// and keeps the sum in checkpoints.
+type accumulator struct {
+	Acks
+	Start <-chan int
+	In    <-chan int
+	Out   chan<- int
+	lock  sync.Mutex
+	sum   int
+}
+
+func (c *accumulator) Process() {
+	if start, ok := <-c.Start; ok {
+		c.lock.Lock()
+		c.sum = start
+		c.lock.Unlock()
+	}
+
+	for i := range c.In {
+		c.lock.Lock()
+		c.sum += i
+		sum := c.sum
+		c.lock.Unlock()
+
+		c.Out <- sum
+		c.Ack("In")
+	}
+}
+
+func (c *accumulator) Snapshot() ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return []byte(strconv.Itoa(c.sum)), nil
+}
+
+func (c *accumulator) Restore(state []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	sum, err := strconv.Atoi(string(state))
+	c.sum = sum
+
+	return err
+}
+
+// gate passes packets after it is opened.
+type gate struct {
+	In   <-chan int
+	Out  chan<- int
+	open chan struct{}
+}
+
+func (c *gate) Process() {
+	<-c.open
+
+	for i := range c.In {
+		c.Out <- i
+	}
+}
+
 func RegisterTestComponents(f *Factory) error {
 	f.Register("echo", func() (interface{}, error) {
 		return new(echo), nil
@@ -212,6 +451,13 @@ func RegisterTestComponents(f *Factory) error {
 		Description: "Sums integers coming to its inports",
 		Icon:        "plus-circle",
 	})
+	f.Register("tagger", func() (interface{}, error) {
+		return new(tagger), nil
+	})
+	f.Annotate("tagger", Annotation{
+		Description: "Sets the tag header of IPs",
+		Icon:        "tag",
+	})
 
 	return nil
 }
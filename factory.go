@@ -1,17 +1,28 @@
 package goflow
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LibrarySeparator separates a library namespace from a component name, e.g. "core/Split".
+const LibrarySeparator = "/"
 
 // Factory registers components and creates their instances at run-time.
-// Not safe for concurrent use.
+// It is safe for concurrent use.
 type Factory struct {
 	registry map[string]registryEntry // map by the component name
+	lock     *sync.RWMutex            // Used to synchronize access to the registry
 }
 
 // registryEntry contains runtime information about a component.
 type registryEntry struct {
 	constructor Constructor   // Function for creating component instances at run-time
 	info        ComponentInfo // Run-time component description
+	version     semVersion    // Parsed version if info.Version is set
 }
 
 // Constructor is used to create a component instance at run-time.
@@ -21,25 +32,217 @@ type Constructor func() (interface{}, error)
 func NewFactory() *Factory {
 	return &Factory{
 		registry: make(map[string]registryEntry),
+		lock:     new(sync.RWMutex),
 	}
 }
 
+// SplitComponentName splits a full component name into a library namespace and
+// a name within that library. The library is empty for components registered
+// without a namespace.
+func SplitComponentName(componentName string) (library, name string) {
+	pos := strings.LastIndex(componentName, LibrarySeparator)
+	if pos < 0 {
+		return "", componentName
+	}
+
+	return componentName[:pos], componentName[pos+1:]
+}
+
+// validateComponentName checks that none of the namespace parts are empty.
+func validateComponentName(componentName string) error {
+	for _, part := range strings.Split(componentName, LibrarySeparator) {
+		if part == "" {
+			return fmt.Errorf("registry error: invalid component name '%s'", componentName)
+		}
+	}
+
+	return nil
+}
+
 // Register registers a component so that it can be instantiated at run-time.
+// The name may be prefixed with a library namespace, e.g. "myteam/Parse", and
+// suffixed with a semantic version, e.g. "myteam/Parse@1.2.0". Multiple versions
+// of a component can be registered at the same time. The constructor is called
+// once to describe the ports of the component, which are not listed if it fails.
 func (f *Factory) Register(componentName string, constructor Constructor) error {
-	if _, exists := f.registry[componentName]; exists {
-		return fmt.Errorf("registry error: component '%s' already registered", componentName)
+	name, ver := splitComponentRef(componentName)
+	if err := validateComponentName(name); err != nil {
+		return err
 	}
 
-	f.registry[componentName] = registryEntry{
+	entry := registryEntry{
 		constructor: constructor,
 		info: ComponentInfo{
-			Name: componentName,
+			Name: name,
 		},
 	}
 
+	if instance, err := constructor(); err == nil {
+		entry.info.InPorts, entry.info.OutPorts = describePorts(instance)
+	}
+
+	key := name
+
+	if ver != "" {
+		v, _, err := parseVersion(ver)
+		if err != nil {
+			return fmt.Errorf("registry error: component '%s': %w", componentName, err)
+		}
+
+		entry.version = v
+		entry.info.Version = v.String()
+		key = name + VersionSeparator + entry.info.Version
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, exists := f.registry[key]; exists {
+		return fmt.Errorf("registry error: component '%s' already registered", key)
+	}
+
+	f.registry[key] = entry
+
 	return nil
 }
 
+// describePorts lists the ports of a component instance. Ports tagged
+// `required:"false"`, array and map ports and Err outports are not required.
+// A `description` tag describes a port.
+func describePorts(instance interface{}) (in, out []PortInfo) {
+	if n, ok := instance.(*Graph); ok {
+		return n.describePorts()
+	}
+
+	val := reflect.ValueOf(instance)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	val = val.Elem()
+
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+
+		t := portChanType(field.Type)
+		if t == nil || field.PkgPath != "" {
+			continue
+		}
+
+		addressable := field.Type.Kind() != reflect.Chan
+		port := PortInfo{
+			ID:          field.Name,
+			Type:        t.Elem().String(),
+			Description: field.Tag.Get("description"),
+			Addressable: addressable,
+			Required:    !addressable && !isOptionalPort(field, val.Field(i)),
+		}
+
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			in = append(in, port)
+		case reflect.SendDir:
+			out = append(out, port)
+		}
+	}
+
+	return in, out
+}
+
+// describePorts lists the exported ports of a graph with the types of the process
+// ports they are mapped to.
+func (n *Graph) describePorts() (in, out []PortInfo) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	describe := func(ports map[string]port, dir reflect.ChanDir) []PortInfo {
+		var res []PortInfo
+
+		for _, name := range sortedPortNames(ports) {
+			info := PortInfo{ID: name, Required: true}
+
+			addr := ports[name].addr
+			if p, err := n.getProcPort(addr.proc, addr.port, dir); err == nil {
+				if t := packetType(p); t != nil {
+					info.Type = t.String()
+				}
+			}
+
+			res = append(res, info)
+		}
+
+		return res
+	}
+
+	return describe(n.inPorts, reflect.RecvDir), describe(n.outPorts, reflect.SendDir)
+}
+
+// resolve finds the registry key of the best component match for a reference.
+// A reference without a version matches an unversioned component or the latest
+// release. A reference like "Split@^1.2" matches the latest version satisfying
+// the constraint. Pre-releases are matched only by constraints which name one.
+// It must be called with the factory locked.
+func (f *Factory) resolve(ref string) (string, error) {
+	name, constraint := splitComponentRef(ref)
+
+	if constraint == "" {
+		if _, exists := f.registry[name]; exists {
+			return name, nil
+		}
+	}
+
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("component '%s': %w", ref, err)
+	}
+
+	best := ""
+
+	for key, entry := range f.registry {
+		if entry.info.Name != name || entry.info.Version == "" || !c.match(entry.version) {
+			continue
+		}
+
+		if best == "" || entry.version.compare(f.registry[best].version) > 0 {
+			best = key
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("component '%s' does not exist", ref)
+	}
+
+	return best, nil
+}
+
+// resolveRef returns an exact reference to the component which a reference resolves to.
+func (f *Factory) resolveRef(ref string) (string, error) {
+	info, err := f.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Version == "" {
+		return info.Name, nil
+	}
+
+	return info.Name + VersionSeparator + info.Version, nil
+}
+
+// Resolve returns information about the component which a reference like
+// "Split" or "Split@^1.2" resolves to.
+func (f *Factory) Resolve(ref string) (ComponentInfo, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	key, err := f.resolve(ref)
+	if err != nil {
+		return ComponentInfo{}, fmt.Errorf("factory error: %w", err)
+	}
+
+	return f.registry[key].info, nil
+}
+
 // Annotation provides reference information about a component to graph designers and operators.
 type Annotation struct {
 	Description string // Description of what the component does
@@ -48,40 +251,149 @@ type Annotation struct {
 
 // Annotate adds human-readable documentation for a component to the runtime.
 func (f *Factory) Annotate(componentName string, annotation Annotation) error {
-	entry, exists := f.registry[componentName]
-	if !exists {
-		return fmt.Errorf("registry annotation error: component '%s' is not registered", componentName)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key, err := f.resolve(componentName)
+	if err != nil {
+		return fmt.Errorf("registry annotation error: %w", err)
 	}
 
+	entry := f.registry[key]
 	entry.info.Description = annotation.Description
 	entry.info.Icon = annotation.Icon
-	f.registry[componentName] = entry
+	f.registry[key] = entry
 
 	return nil
 }
 
 // Unregister removes a component with a given name from the component registry and returns true
-// or returns false if no such component is registered.
+// or returns false if no such component is registered. A versioned component is removed
+// only if the version is specified exactly, e.g. "Split@1.2.0".
 func (f *Factory) Unregister(componentName string) error {
-	if _, exists := f.registry[componentName]; !exists {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := componentName
+
+	if name, ver := splitComponentRef(componentName); ver != "" {
+		if v, _, err := parseVersion(ver); err == nil {
+			key = name + VersionSeparator + v.String()
+		}
+	}
+
+	if _, exists := f.registry[key]; !exists {
 		return fmt.Errorf("registry error: component '%s' is not registered", componentName)
 	}
 
-	delete(f.registry, componentName)
+	delete(f.registry, key)
 
 	return nil
 }
 
 // Create creates a new instance of a component registered under a specific name.
+// The name can contain a version constraint, e.g. "Split@^1.2", in which case the
+// latest matching version is used.
 func (f *Factory) Create(componentName string) (interface{}, error) {
-	info, exists := f.registry[componentName]
-	if !exists {
-		return nil, fmt.Errorf("factory error: component '%s' does not exist", componentName)
+	f.lock.RLock()
+
+	key, err := f.resolve(componentName)
+	if err != nil {
+		f.lock.RUnlock()
+		return nil, fmt.Errorf("factory error: %w", err)
 	}
 
+	info := f.registry[key]
+	f.lock.RUnlock()
+
+	// The constructor is called without the lock, so it can use the factory too
 	return info.constructor()
 }
 
+// Merge registers all components of another factory in this one. It fails
+// without registering anything if any of the names is already taken.
+func (f *Factory) Merge(other *Factory) error {
+	if f == other {
+		return nil
+	}
+
+	// Copy the other registry first, so that the factories are never locked together
+	other.lock.RLock()
+	entries := make(map[string]registryEntry, len(other.registry))
+
+	for name, entry := range other.registry {
+		entries[name] = entry
+	}
+	other.lock.RUnlock()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for name := range entries {
+		if _, exists := f.registry[name]; exists {
+			return fmt.Errorf("registry error: component '%s' already registered", name)
+		}
+	}
+
+	for name, entry := range entries {
+		f.registry[name] = entry
+	}
+
+	return nil
+}
+
+// List returns information about components whose names start with a given prefix,
+// sorted by name and version. Pass a library name with a trailing separator, e.g. "core/",
+// to list a single library or an empty string to list all components.
+func (f *Factory) List(prefix string) []ComponentInfo {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	entries := make([]registryEntry, 0, len(f.registry))
+
+	for name, entry := range f.registry {
+		if strings.HasPrefix(name, prefix) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].info.Name != entries[j].info.Name {
+			return entries[i].info.Name < entries[j].info.Name
+		}
+
+		return entries[i].version.compare(entries[j].version) < 0
+	})
+
+	list := make([]ComponentInfo, len(entries))
+	for i := range entries {
+		list[i] = entries[i].info
+	}
+
+	return list
+}
+
+// Libraries returns sorted names of all library namespaces in the registry.
+func (f *Factory) Libraries() []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	seen := make(map[string]bool)
+	libs := []string{}
+
+	for _, entry := range f.registry {
+		lib, _ := SplitComponentName(entry.info.Name)
+		if lib != "" && !seen[lib] {
+			seen[lib] = true
+			libs = append(libs, lib)
+		}
+	}
+
+	sort.Strings(libs)
+
+	return libs
+}
+
 // // UpdateComponentInfo extracts run-time information about a
 // // component and its ports. It is called when an FBP protocol client
 // // requests component information.
@@ -0,0 +1,105 @@
+package goflow
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RegisterGraphFile registers a graph definition file in JSON or FBP format as a
+// component. Every instance created by the factory is a fresh graph whose exported
+// ports act as component ports. The file is parsed on registration to report syntax
+// errors early, while the processes are created by Create, so the graph can use
+// components registered later, including other graphs.
+func (f *Factory) RegisterGraphFile(componentName, filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("registry error: %w", err)
+	}
+
+	return f.registerGraph(componentName, filePath, data)
+}
+
+// RegisterGraphFS registers every JSON and FBP graph definition in a directory of
+// a file system as a component. Components are named after the files without
+// extensions, e.g. "parse.fbp" becomes "parse".
+func (f *Factory) RegisterGraphFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("registry error: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(path.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".json" && ext != ".fbp") {
+			continue
+		}
+
+		filePath := path.Join(dir, entry.Name())
+
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return fmt.Errorf("registry error: %w", err)
+		}
+
+		if err := f.registerGraph(strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())), filePath, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registerGraph registers a graph definition as a component.
+func (f *Factory) registerGraph(componentName, filePath string, data []byte) error {
+	descr, err := parseGraphDescription(filePath, data)
+	if err != nil {
+		return fmt.Errorf("registry error: graph '%s': %w", filePath, err)
+	}
+
+	err = f.Register(componentName, func() (interface{}, error) {
+		return descr.build(f)
+	})
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key, err := f.resolve(componentName)
+	if err != nil {
+		return err
+	}
+
+	entry := f.registry[key]
+	entry.info.Subgraph = true
+	entry.info.Description = descr.Properties.Description
+	entry.info.Icon = descr.Properties.Icon
+
+	if entry.info.InPorts == nil && entry.info.OutPorts == nil {
+		// A graph using components which are not registered yet is described by port names
+		entry.info.InPorts = exportInfo(descr.Inports)
+		entry.info.OutPorts = exportInfo(descr.Outports)
+	}
+
+	f.registry[key] = entry
+
+	return nil
+}
+
+// exportInfo lists exported ports of a graph description sorted by name.
+func exportInfo(exports map[string]exportDescription) []PortInfo {
+	var res []PortInfo
+
+	for name := range exports {
+		res = append(res, PortInfo{ID: name, Required: true})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+
+	return res
+}
@@ -0,0 +1,131 @@
+package goflow
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRegisterGraphFS(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	fsys := fstest.MapFS{
+		"graphs/double.fbp": {Data: []byte(doublerFBP)},
+		"graphs/quadruple.json": {Data: []byte(`{
+			"properties": {"description": "Doubles twice"},
+			"processes": {
+				"d1": {"component": "double"},
+				"d2": {"component": "double"}
+			},
+			"connections": [
+				{"src": {"process": "d1", "port": "Out"}, "tgt": {"process": "d2", "port": "In"}}
+			],
+			"inports": {"In": {"process": "d1", "port": "In"}},
+			"outports": {"Out": {"process": "d2", "port": "Out"}}
+		}`)},
+		"graphs/README.md": {Data: []byte("Not a graph")},
+	}
+
+	if err := f.RegisterGraphFS(fsys, "graphs"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	info, err := f.Resolve("quadruple")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !info.Subgraph || info.Description != "Doubles twice" {
+		t.Errorf("Unexpected info %+v", info)
+	}
+
+	if len(info.InPorts) != 1 || info.InPorts[0].ID != "In" || len(info.OutPorts) != 1 || info.OutPorts[0].ID != "Out" {
+		t.Errorf("Unexpected ports %+v %+v", info.InPorts, info.OutPorts)
+	}
+
+	n := NewGraph()
+
+	if err := n.AddNew("q", "quadruple", f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.AddNew("e", "echo", f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("q", "Out", "e", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "q", "In")
+	n.MapOutPort("Out", "e", "Out")
+
+	res := runIntGraph(n, []int{1, 2, 3})
+
+	// Pooled doublers may reorder packets
+	sort.Ints(res)
+	expectInts(t, res, []int{4, 8, 12})
+}
+
+func TestRegisterGraphFile(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "double.fbp")
+
+	if err := ioutil.WriteFile(path, []byte(doublerFBP), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := f.RegisterGraphFile("double", path); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Each instance is a new graph
+	g1, err := f.Create("double")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g2, err := f.Create("double")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if g1 == g2 {
+		t.Errorf("Instances must be different")
+	}
+
+	if err := f.RegisterGraphFile("missing", filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	bad := filepath.Join(dir, "bad.txt")
+	if err := ioutil.WriteFile(bad, []byte("{}"), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := f.RegisterGraphFile("bad", bad); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -1,6 +1,8 @@
 package goflow
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -66,6 +68,61 @@ func TestFactoryRegistration(t *testing.T) {
 	}
 }
 
+func TestFactoryPorts(t *testing.T) {
+	f := NewFactory()
+
+	for name, c := range map[string]interface{}{"scaler": new(scaler), "rejecter": new(rejecter), "router": new(router)} {
+		c := c
+		if err := f.Register(name, func() (interface{}, error) { return c, nil }); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := RegisterTestGraph(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cases := []struct {
+		component string
+		in, out   []PortInfo
+	}{
+		{
+			"scaler",
+			[]PortInfo{{ID: "Factor", Type: "int"}, {ID: "In", Type: "int", Required: true}},
+			[]PortInfo{{ID: "Out", Type: "int", Required: true}},
+		},
+		{
+			"rejecter",
+			[]PortInfo{{ID: "In", Type: "int", Required: true}},
+			[]PortInfo{{ID: "Out", Type: "int", Required: true}, {ID: "Err", Type: "error"}},
+		},
+		{
+			"router",
+			[]PortInfo{{ID: "In", Type: "int", Addressable: true}},
+			[]PortInfo{{ID: "Out", Type: "int", Addressable: true}},
+		},
+		{
+			"doubleEcho",
+			[]PortInfo{{ID: "In", Type: "int", Required: true}},
+			[]PortInfo{{ID: "Out", Type: "int", Required: true}},
+		},
+	}
+
+	for _, c := range cases {
+		info, err := f.Resolve(c.component)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !reflect.DeepEqual(info.InPorts, c.in) || !reflect.DeepEqual(info.OutPorts, c.out) {
+			t.Errorf("%s: unexpected ports %+v %+v", c.component, info.InPorts, info.OutPorts)
+		}
+	}
+}
+
 func TestFactoryGraph(t *testing.T) {
 	f := NewFactory()
 
@@ -106,3 +163,205 @@ func TestFactoryGraph(t *testing.T) {
 
 	testGraphWithNumberSequence(n, t)
 }
+
+func TestFactoryNamespaces(t *testing.T) {
+	f := NewFactory()
+
+	constructor := func() (interface{}, error) {
+		return new(echo), nil
+	}
+
+	for _, name := range []string{"core/Echo", "core/Split", "myteam/Parse", "myteam/io/Read", "plain"} {
+		if err := f.Register(name, constructor); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	for _, name := range []string{"/Echo", "core/", "core//Echo", ""} {
+		if err := f.Register(name, constructor); err == nil {
+			t.Errorf("Expected an error for '%s'", name)
+		}
+	}
+
+	list := f.List("core/")
+	if len(list) != 2 || list[0].Name != "core/Echo" || list[1].Name != "core/Split" {
+		t.Errorf("Unexpected list %+v", list)
+	}
+
+	if all := f.List(""); len(all) != 5 {
+		t.Errorf("%d != 5", len(all))
+	}
+
+	libs := f.Libraries()
+	if len(libs) != 3 || libs[0] != "core" || libs[1] != "myteam" || libs[2] != "myteam/io" {
+		t.Errorf("Unexpected libraries %v", libs)
+	}
+
+	lib, name := SplitComponentName("myteam/io/Read")
+	if lib != "myteam/io" || name != "Read" {
+		t.Errorf("Unexpected split '%s' '%s'", lib, name)
+	}
+
+	if _, err := f.Create("core/Echo"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFactoryMerge(t *testing.T) {
+	f := NewFactory()
+	other := NewFactory()
+
+	if err := RegisterTestComponents(other); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := f.Merge(other); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(f.List("")) != len(other.List("")) {
+		t.Errorf("%d != %d", len(f.List("")), len(other.List("")))
+	}
+
+	if err := f.Merge(other); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestFactoryConcurrentUse(t *testing.T) {
+	f := NewFactory()
+	done := make(chan struct{})
+
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("lib%d/echo", i)
+
+		go func() {
+			defer func() { done <- struct{}{} }()
+
+			if err := f.Register(name, func() (interface{}, error) {
+				return new(echo), nil
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if _, err := f.Create(name); err != nil {
+				t.Error(err)
+			}
+
+			f.List("")
+
+			if err := f.Unregister(name); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+}
+
+func TestFactoryVersions(t *testing.T) {
+	f := NewFactory()
+
+	for _, name := range []string{"core/Echo@1.0.0", "core/Echo@1.2.0", "core/Echo@v1.10", "core/Echo@2.0.0"} {
+		name := name
+		if err := f.Register(name, func() (interface{}, error) {
+			return new(echo), nil
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := f.Register("core/Echo@1.2", func() (interface{}, error) {
+		return new(echo), nil
+	}); err == nil {
+		t.Errorf("Expected an error for a duplicate version")
+	}
+
+	if err := f.Register("core/Echo@one", func() (interface{}, error) {
+		return new(echo), nil
+	}); err == nil {
+		t.Errorf("Expected an error for an invalid version")
+	}
+
+	cases := []struct {
+		ref      string
+		expected string
+	}{
+		{"core/Echo", "2.0.0"},
+		{"core/Echo@^1.2", "1.10.0"},
+		{"core/Echo@~1.2", "1.2.0"},
+		{"core/Echo@1.0.0", "1.0.0"},
+		{"core/Echo@<1.2", "1.0.0"},
+	}
+
+	for _, c := range cases {
+		info, err := f.Resolve(c.ref)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if info.Name != "core/Echo" || info.Version != c.expected {
+			t.Errorf("'%s' resolved to %s@%s", c.ref, info.Name, info.Version)
+		}
+	}
+
+	if _, err := f.Create("core/Echo@^3"); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if err := f.Annotate("core/Echo@^1", Annotation{Description: "Latest 1.x"}); err != nil {
+		t.Error(err)
+	}
+
+	list := f.List("core/")
+	if len(list) != 4 || list[0].Version != "1.0.0" || list[3].Version != "2.0.0" || list[2].Description != "Latest 1.x" {
+		t.Errorf("Unexpected list %+v", list)
+	}
+
+	n := NewGraph()
+	if err := n.AddNew("e", "core/Echo@^1.0", f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if ref := n.ComponentOf("e"); ref != "core/Echo@1.10.0" {
+		t.Errorf("'%s' != 'core/Echo@1.10.0'", ref)
+	}
+
+	// Pre-releases are only used if they are asked for
+	if err := f.Register("core/Echo@3.0.0-beta.2", func() (interface{}, error) {
+		return new(echo), nil
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for ref, expected := range map[string]string{
+		"core/Echo":                "2.0.0",
+		"core/Echo@>=2":            "2.0.0",
+		"core/Echo@^3.0.0-beta":    "3.0.0-beta.2",
+		"core/Echo@3.0.0-beta.2":   "3.0.0-beta.2",
+		"core/Echo@>=1 <4":         "2.0.0",
+		"core/Echo@>=3.0.0-beta.1": "3.0.0-beta.2",
+	} {
+		if info, err := f.Resolve(ref); err != nil || info.Version != expected {
+			t.Errorf("'%s' resolved to '%s' != '%s': %v", ref, info.Version, expected, err)
+		}
+	}
+
+	if err := f.Unregister("core/Echo@1.10"); err != nil {
+		t.Error(err)
+	}
+
+	if err := f.Unregister("core/Echo"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
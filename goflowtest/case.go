@@ -0,0 +1,76 @@
+package goflowtest
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// Case is a table-driven test case for a component.
+type Case struct {
+	Name      string
+	In        map[string]interface{} // Packets sent to inports by address
+	Out       map[string]interface{} // Packets expected from outports by address
+	Unordered bool                   // Outports may produce packets in any order
+	Timeout   time.Duration          // DefaultTimeout if 0
+}
+
+// RunCases runs each case as a subtest with a new component instance.
+func RunCases(t *testing.T, newComponent func() interface{}, cases []Case) {
+	t.Helper()
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Helper()
+			tc.Check(t, newComponent())
+		})
+	}
+}
+
+// Check runs a component with the inputs of the case and reports differences
+// from the expected outputs.
+func (tc Case) Check(t testing.TB, c interface{}) {
+	t.Helper()
+
+	h := New(c)
+	if tc.Timeout > 0 {
+		h.Timeout(tc.Timeout)
+	}
+
+	for _, addr := range sortedKeys(tc.In) {
+		h.In(addr, tc.In[addr])
+	}
+
+	outs := sortedKeys(tc.Out)
+	h.Out(outs...)
+
+	res, err := h.Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	compare := Equal
+	if tc.Unordered {
+		compare = EqualUnordered
+	}
+
+	for _, addr := range outs {
+		if err := compare(res[addr], tc.Out[addr]); err != nil {
+			t.Errorf("%s: %s", addr, err)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	res := make([]string, 0, len(m))
+	for key := range m {
+		res = append(res, key)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
@@ -0,0 +1,92 @@
+package goflowtest
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Equal checks that packets got from a port are the same as expected and
+// arrive in the same order. Expected elements are converted to the type of
+// got elements, so want may be e.g. []interface{}.
+func Equal(got, want interface{}) error {
+	g, w, err := packetLists(got, want)
+	if err != nil {
+		return err
+	}
+
+	if len(g) != len(w) {
+		return fmt.Errorf("got %d packets %v, want %d packets %v", len(g), got, len(w), want)
+	}
+
+	for i := range g {
+		if !reflect.DeepEqual(g[i], w[i]) {
+			return fmt.Errorf("packet %d: got %v, want %v", i, g[i], w[i])
+		}
+	}
+
+	return nil
+}
+
+// EqualUnordered checks that packets got from a port are the same as expected
+// in any order, e.g. when they are produced by a pool of processes.
+func EqualUnordered(got, want interface{}) error {
+	g, w, err := packetLists(got, want)
+	if err != nil {
+		return err
+	}
+
+	var missing []interface{}
+
+	for _, v := range w {
+		found := false
+
+		for i := range g {
+			if reflect.DeepEqual(g[i], v) {
+				g = append(g[:i], g[i+1:]...)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, v)
+		}
+	}
+
+	if len(missing) > 0 || len(g) > 0 {
+		return fmt.Errorf("got %v, want %v in any order: missing %v, unexpected %v", got, want, missing, g)
+	}
+
+	return nil
+}
+
+// packetLists converts packet slices to lists of values of the same type.
+func packetLists(got, want interface{}) (g, w []interface{}, err error) {
+	gv, wv := reflect.ValueOf(got), reflect.ValueOf(want)
+
+	if want == nil {
+		wv = reflect.ValueOf([]interface{}{})
+	}
+
+	if gv.Kind() != reflect.Slice || wv.Kind() != reflect.Slice {
+		return nil, nil, fmt.Errorf("cannot compare %T with %T, slices expected", got, want)
+	}
+
+	t := gv.Type().Elem()
+
+	for i := 0; i < gv.Len(); i++ {
+		g = append(g, gv.Index(i).Interface())
+	}
+
+	for i := 0; i < wv.Len(); i++ {
+		v, err := convertValue(wv.Index(i), t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("expected packet %d: %w", i, err)
+		}
+
+		w = append(w, v.Interface())
+	}
+
+	return g, w, nil
+}
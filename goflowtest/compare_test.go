@@ -0,0 +1,71 @@
+package goflowtest
+
+import (
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	if err := Equal([]int{1, 2}, []interface{}{1, 2}); err != nil {
+		t.Error(err)
+	}
+
+	if err := Equal([]int{}, nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := Equal([]int{1, 2}, []int{2, 1}); err == nil || err.Error() != "packet 0: got 1, want 2" {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if err := Equal([]int{1}, []int{1, 2}); err == nil {
+		t.Error("Expected a length mismatch")
+	}
+
+	if err := Equal(1, []int{1}); err == nil {
+		t.Error("Expected an error for a value which is not a slice")
+	}
+}
+
+func TestEqualUnordered(t *testing.T) {
+	if err := EqualUnordered([]string{"a", "b", "a"}, []string{"a", "a", "b"}); err != nil {
+		t.Error(err)
+	}
+
+	err := EqualUnordered([]string{"a", "c"}, []string{"a", "b"})
+	if err == nil || err.Error() != "got [a c], want [a b] in any order: missing [b], unexpected [c]" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestRunCases(t *testing.T) {
+	RunCases(t, func() interface{} { return &splitter{} }, []Case{
+		{
+			Name: "empty",
+			In:   map[string]interface{}{"In": []int{}},
+			Out:  map[string]interface{}{"Out[0]": nil},
+		},
+		{
+			Name: "split",
+			In:   map[string]interface{}{"In": []int{1, 2, 3}},
+			Out: map[string]interface{}{
+				"Out[0]": []int{2},
+				"Out[1]": []int{1, 3},
+			},
+		},
+	})
+
+	RunCases(t, func() interface{} { return &router{} }, []Case{
+		{
+			Name: "unordered",
+			In: map[string]interface{}{
+				"In[x]": []int{1, 2},
+				"In[y]": []int{3, 4},
+			},
+			Out: map[string]interface{}{
+				"Out[x]": []int{2, 1},
+				"Out[y]": []int{4, 3},
+			},
+			Unordered: true,
+		},
+	})
+}
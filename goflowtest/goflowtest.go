@@ -0,0 +1,373 @@
+// Package goflowtest provides a harness for testing components and graphs.
+//
+// A harness feeds inports from slices, collects outports into slices and
+// closes the inports once all packets have been sent:
+//
+//	res, err := goflowtest.New(&Adder{}).
+//		In("Op1", []int{1, 2}).
+//		In("Op2", []int{3, 4}).
+//		Out("Sum").
+//		Run()
+//
+// Ports are addressed like in graph connections, so array and map ports are
+// addressed as "Op[1]" or "Op[key]". Graphs are addressed by their exported ports.
+package goflowtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trustmaster/goflow"
+)
+
+// DefaultTimeout limits the time a harness waits for a component to finish.
+const DefaultTimeout = 5 * time.Second
+
+// procName is the name of the tested process in the wrapping graph.
+const procName = "c"
+
+// Result holds packets collected from outports by port address. Every value is
+// a slice of the port element type, e.g. []int.
+type Result map[string]interface{}
+
+// Harness runs a component or a graph with the given inputs.
+type Harness struct {
+	c       interface{}
+	ins     []input
+	outs    []string
+	timeout time.Duration
+	err     error
+}
+
+// input is a list of packets for an inport.
+type input struct {
+	addr    string
+	packets reflect.Value
+}
+
+// New creates a harness for a component or a *goflow.Graph.
+func New(c interface{}) *Harness {
+	return &Harness{
+		c:       c,
+		timeout: DefaultTimeout,
+	}
+}
+
+// In sets packets sent to an inport, packets must be a slice. Elements are
+// converted to the port type if possible, so []interface{} can be used for any port.
+// The inport is closed after the last packet.
+func (h *Harness) In(addr string, packets interface{}) *Harness {
+	val := reflect.ValueOf(packets)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		h.fail(fmt.Errorf("packets of inport '%s' must be a slice, got %T", addr, packets))
+		return h
+	}
+
+	h.ins = append(h.ins, input{addr, val})
+
+	return h
+}
+
+// Out collects packets sent to outports.
+func (h *Harness) Out(addrs ...string) *Harness {
+	h.outs = append(h.outs, addrs...)
+	return h
+}
+
+// Timeout sets the time a harness waits for the component to finish.
+func (h *Harness) Timeout(d time.Duration) *Harness {
+	h.timeout = d
+	return h
+}
+
+func (h *Harness) fail(err error) {
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+// portState tracks the progress of a port for timeout reports.
+type portState struct {
+	addr   string
+	out    bool
+	total  int
+	count  int
+	closed bool
+	values reflect.Value
+}
+
+func (s *portState) String() string {
+	switch {
+	case !s.out && s.closed:
+		return fmt.Sprintf("inport %s: %d of %d packets sent, closed", s.addr, s.count, s.total)
+	case !s.out:
+		return fmt.Sprintf("inport %s: %d of %d packets sent, blocked", s.addr, s.count, s.total)
+	case s.closed:
+		return fmt.Sprintf("outport %s: %d packets received, closed", s.addr, s.count)
+	default:
+		return fmt.Sprintf("outport %s: %d packets received, open", s.addr, s.count)
+	}
+}
+
+// TimeoutError is returned if a component does not finish in time.
+// It tells which ports are stuck.
+type TimeoutError struct {
+	Timeout    time.Duration
+	Ports      []string // State of every port
+	Validation error    // Ports which have not been connected, if any
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("goflowtest: timed out after %s:\n\t%s", e.Timeout, strings.Join(e.Ports, "\n\t"))
+	if e.Validation != nil {
+		msg += "\n\t" + e.Validation.Error()
+	}
+
+	return msg
+}
+
+// Run runs the component until it finishes and returns the packets collected
+// from outports. On timeout it returns the packets collected so far along with
+// a *TimeoutError, the component keeps running in the background.
+// A panic of the component is returned as an error.
+func (h *Harness) Run() (Result, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	n := goflow.NewGraph()
+	if err := n.Add(procName, h.c); err != nil {
+		return nil, err
+	}
+
+	if _, isGraph := h.c.(*goflow.Graph); !isGraph {
+		if err := n.Supervise(procName, goflow.Supervision{Escalate: true}); err != nil {
+			return nil, err
+		}
+	}
+
+	var lock sync.Mutex
+
+	ins := make([]*portState, len(h.ins))
+	outs := make([]*portState, len(h.outs))
+	chans := make([]reflect.Value, len(h.ins)+len(h.outs))
+
+	for i, in := range h.ins {
+		ch, err := mapPort(n, in.addr, false)
+		if err != nil {
+			return nil, err
+		}
+
+		packets, err := convertPackets(in.packets, ch.Type().Elem())
+		if err != nil {
+			return nil, fmt.Errorf("goflowtest: inport '%s': %w", in.addr, err)
+		}
+
+		chans[i] = ch
+		ins[i] = &portState{addr: in.addr, total: packets.Len(), values: packets}
+	}
+
+	for i, addr := range h.outs {
+		ch, err := mapPort(n, addr, true)
+		if err != nil {
+			return nil, err
+		}
+
+		chans[len(h.ins)+i] = ch
+		outs[i] = &portState{addr: addr, out: true, values: reflect.MakeSlice(reflect.SliceOf(ch.Type().Elem()), 0, 0)}
+	}
+
+	validation := n.Validate()
+	wait := goflow.Run(n)
+	finished := make(chan struct{})
+	collected := new(sync.WaitGroup)
+
+	for i, s := range ins {
+		go feed(chans[i], s, &lock, finished)
+	}
+
+	for i, s := range outs {
+		collected.Add(1)
+
+		go collect(chans[len(h.ins)+i], s, &lock, finished, collected)
+	}
+
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-wait:
+		close(finished)
+		collected.Wait()
+	case <-timer.C:
+		lock.Lock()
+		defer lock.Unlock()
+
+		err := &TimeoutError{Timeout: h.timeout, Validation: validation}
+		for _, s := range append(ins, outs...) {
+			err.Ports = append(err.Ports, s.String())
+		}
+
+		return collectedResult(outs), err
+	}
+
+	return collectedResult(outs), n.ProcessError()
+}
+
+// mapPort exports a port of the tested process and attaches a new channel to it.
+func mapPort(n *goflow.Graph, addr string, out bool) (reflect.Value, error) {
+	var t reflect.Type
+
+	var err error
+
+	if out {
+		n.MapOutPort(addr, procName, addr)
+		t, err = n.OutPortType(addr)
+	} else {
+		n.MapInPort(addr, procName, addr)
+		t, err = n.InPortType(addr)
+	}
+
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("goflowtest: %w", err)
+	}
+
+	ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t), 0)
+
+	if out {
+		err = n.SetOutPort(addr, ch.Interface())
+	} else {
+		err = n.SetInPort(addr, ch.Interface())
+	}
+
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("goflowtest: %w", err)
+	}
+
+	return ch, nil
+}
+
+// feed sends packets to an inport and closes it. It stops if the process
+// finishes without reading all of them.
+func feed(ch reflect.Value, s *portState, lock *sync.Mutex, finished <-chan struct{}) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(finished)},
+	}
+
+	for i := 0; i < s.total; i++ {
+		cases[0].Send = s.values.Index(i)
+
+		if chosen, _, _ := reflect.Select(cases); chosen == 1 {
+			return
+		}
+
+		lock.Lock()
+		s.count++
+		lock.Unlock()
+	}
+
+	ch.Close()
+
+	lock.Lock()
+	s.closed = true
+	lock.Unlock()
+}
+
+// collect receives packets from an outport until it is closed or the process
+// has finished and no more packets are pending.
+func collect(ch reflect.Value, s *portState, lock *sync.Mutex, finished <-chan struct{}, collected *sync.WaitGroup) {
+	defer collected.Done()
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(finished)},
+	}
+
+	draining := false
+
+	for {
+		chosen, v, ok := reflect.Select(cases)
+		if chosen == 1 {
+			if draining {
+				return
+			}
+
+			// Array and map outports may be left open, take what is left
+			cases[1] = reflect.SelectCase{Dir: reflect.SelectDefault}
+			draining = true
+
+			continue
+		}
+
+		lock.Lock()
+
+		if !ok {
+			s.closed = true
+			lock.Unlock()
+
+			return
+		}
+
+		s.values = reflect.Append(s.values, v)
+		s.count++
+		lock.Unlock()
+	}
+}
+
+func collectedResult(outs []*portState) Result {
+	res := make(Result, len(outs))
+	for _, s := range outs {
+		res[s.addr] = s.values.Interface()
+	}
+
+	return res
+}
+
+// convertPackets converts a slice of packets to the element type of a port.
+func convertPackets(packets reflect.Value, t reflect.Type) (reflect.Value, error) {
+	res := reflect.MakeSlice(reflect.SliceOf(t), packets.Len(), packets.Len())
+
+	for i := 0; i < packets.Len(); i++ {
+		v, err := convertValue(packets.Index(i), t)
+		if err != nil {
+			return res, fmt.Errorf("packet %d: %w", i, err)
+		}
+
+		res.Index(i).Set(v)
+	}
+
+	return res, nil
+}
+
+// convertValue converts a value to a given type, unwrapping interfaces.
+func convertValue(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if v.Kind() == reflect.Interface && t.Kind() != reflect.Interface {
+		if v.IsNil() {
+			return reflect.Zero(t), nil
+		}
+
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return reflect.Zero(t), nil
+	}
+
+	switch {
+	case v.Type().AssignableTo(t):
+		res := reflect.New(t).Elem()
+		res.Set(v)
+
+		return res, nil
+	case v.Type().ConvertibleTo(t) && v.Kind() != reflect.String && t.Kind() != reflect.String:
+		return v.Convert(t), nil
+	case v.Kind() == reflect.String && t.Kind() == reflect.String:
+		return v.Convert(t), nil
+	default:
+		return v, fmt.Errorf("cannot convert %s to %s", v.Type(), t)
+	}
+}
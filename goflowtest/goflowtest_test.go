@@ -0,0 +1,226 @@
+package goflowtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trustmaster/goflow"
+)
+
+// adder sums pairs of operands.
+type adder struct {
+	Op1 <-chan int
+	Op2 <-chan int
+	Sum chan<- int
+}
+
+func (c *adder) Process() {
+	for a := range c.Op1 {
+		c.Sum <- a + <-c.Op2
+	}
+}
+
+// router routes map inports to the outports with the same keys.
+type router struct {
+	In  map[string]<-chan int
+	Out map[string]chan<- int
+}
+
+func (c *router) Process() {
+	for k, ch := range c.In {
+		for n := range ch {
+			c.Out[k] <- n
+		}
+	}
+}
+
+// splitter sends even numbers to Out[0] and odd ones to Out[1].
+type splitter struct {
+	In  <-chan int
+	Out [](chan<- int)
+}
+
+func (c *splitter) Process() {
+	for n := range c.In {
+		c.Out[n%2] <- n
+	}
+
+	for _, ch := range c.Out {
+		close(ch)
+	}
+}
+
+// stuck never reads its second inport.
+type stuck struct {
+	In     <-chan int
+	Ignore <-chan int
+	Out    chan<- int
+}
+
+func (c *stuck) Process() {
+	for n := range c.In {
+		c.Out <- n
+	}
+
+	<-c.Ignore
+}
+
+// panicker panics on negative input.
+type panicker struct {
+	In <-chan int
+}
+
+func (c *panicker) Process() {
+	for i := range c.In {
+		if i < 0 {
+			panic("negative input")
+		}
+	}
+}
+
+func TestHarness(t *testing.T) {
+	res, err := New(&adder{}).
+		In("Op1", []int{1, 2, 3}).
+		In("op2", []interface{}{10, 20, 30}).
+		Out("Sum").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sums, ok := res["Sum"].([]int)
+	if !ok {
+		t.Errorf("Unexpected result type %T", res["Sum"])
+		return
+	}
+
+	if err := Equal(sums, []int{11, 22, 33}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestArrayAndMapPorts(t *testing.T) {
+	res, err := New(&splitter{}).
+		In("In", []int{1, 2, 3, 4, 5}).
+		Out("Out[0]", "Out[1]").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := Equal(res["Out[0]"], []int{2, 4}); err != nil {
+		t.Error(err)
+	}
+
+	if err := Equal(res["Out[1]"], []int{1, 3, 5}); err != nil {
+		t.Error(err)
+	}
+
+	// Map outports are not closed by the component
+	res, err = New(&router{}).
+		In("In[a]", []int{1, 2}).
+		In("In[b]", []int{3}).
+		Out("Out[a]", "Out[b]").
+		Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := Equal(res["Out[a]"], []int{1, 2}); err != nil {
+		t.Error(err)
+	}
+
+	if err := Equal(res["Out[b]"], []int{3}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	n := goflow.NewGraph()
+
+	if err := n.Add("s", &splitter{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Add("a", &adder{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("s", "Out[0]", "a", "Op1"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("s", "Out[1]", "a", "Op2"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "s", "In")
+	n.MapOutPort("Out", "a", "Sum")
+
+	res, err := New(n).In("In", []int{2, 1, 4, 3}).Out("Out").Run()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := Equal(res["Out"], []int{3, 7}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	res, err := New(&stuck{}).
+		In("In", []int{1, 2}).
+		Out("Out").
+		Timeout(100 * time.Millisecond).
+		Run()
+
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("Expected a timeout, got %v", err)
+		return
+	}
+
+	for _, s := range []string{
+		"timed out after 100ms",
+		"inport In: 2 of 2 packets sent, closed",
+		"outport Out: 2 packets received, open",
+		"port 'c.Ignore' is not connected",
+	} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Report does not contain %q:\n%s", s, err)
+		}
+	}
+
+	// Packets received so far are returned
+	if err := Equal(res["Out"], []int{1, 2}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := New(&panicker{}).In("In", []int{1, -1}).Run(); err == nil || !strings.Contains(err.Error(), "negative input") {
+		t.Errorf("Expected a panic, got %v", err)
+	}
+
+	if _, err := New(&adder{}).In("Op1", 1).Run(); err == nil {
+		t.Error("Expected an error for packets which are not a slice")
+	}
+
+	if _, err := New(&adder{}).In("Op1", []string{"x"}).Run(); err == nil || !strings.Contains(err.Error(), "cannot convert") {
+		t.Errorf("Expected a conversion error, got %v", err)
+	}
+
+	if _, err := New(&adder{}).Out("Missing").Run(); err == nil {
+		t.Error("Expected an error for a missing port")
+	}
+}
@@ -14,15 +14,36 @@ type GraphConfig struct {
 
 // Graph represents a graph of processes connected with packet channels.
 type Graph struct {
-	conf                   GraphConfig            // Graph configuration
-	waitGrp                *sync.WaitGroup        // Wait group for a graceful termination
-	procs                  map[string]interface{} // Network processes
-	inPorts                map[string]port        // Map of network incoming ports to component ports
-	outPorts               map[string]port        // Map of network outgoing ports to component ports
-	connections            []connection           // Network graph edges (inter-process connections)
-	chanListenersCount     map[uintptr]uint       // Tracks how many outports use the same channel
-	chanListenersCountLock sync.Locker            // Used to synchronize operations on the chanListenersCount map
-	iips                   []iip                  // Initial Information Packets to be sent to the network on start
+	conf                   GraphConfig              // Graph configuration
+	lock                   sync.Locker              // Used to synchronize changes of the graph structure
+	procs                  map[string]interface{}   // Network processes
+	components             map[string]string        // Components the processes were created from, with versions
+	factory                *Factory                 // Factory the components were created with
+	inPorts                map[string]port          // Map of network incoming ports to component ports
+	outPorts               map[string]port          // Map of network outgoing ports to component ports
+	connections            []connection             // Network graph edges (inter-process connections)
+	chanListenersCount     map[uintptr]uint         // Tracks how many outports use the same channel
+	chanListenersCountLock sync.Locker              // Used to synchronize operations on the chanListenersCount map
+	iips                   []iip                    // Initial Information Packets to be sent to the network on start
+	supervisors            map[string]Supervision   // Supervision settings by process name
+	pools                  map[string][]interface{} // Extra replicas of pooled processes
+	err                    error                    // Failure escalated by a supervised process
+	errLock                sync.Locker              // Used to synchronize access to err
+	running                bool                     // Tells if the network is running
+	active                 int                      // Number of process goroutines still running
+	done                   chan struct{}            // Closed when the running network has finished
+	started                map[string]chan struct{} // Started processes, their channels are closed when they finish
+	pending                map[string]bool          // Processes added at run-time which are not started yet
+	deadLetterSink         func(l DeadLetter)       // Receives errors of unconnected Err ports
+	deadLetterClose        func()                   // Closes the dead letter channel set with SetOutPort
+	deadLetterParent       func(l DeadLetter)       // Sink of the parent graph used if there is no own sink
+	deadLetters            *deadLetterQueue         // Dead letters of the running network
+	checkpointConf         *CheckpointConfig        // Checkpoint settings, nil if checkpoints are disabled
+	checkpoints            *checkpointer            // Checkpoints of the running network
+	params                 map[string]Param         // Declared parameters
+	paramValues            map[string]interface{}   // Parameter values set for this graph
+	paramParent            *Graph                   // Graph this one inherits parameters from
+	paramLock              sync.Locker              // Used to synchronize access to parameters
 }
 
 // NewGraph returns a new initialized empty graph instance.
@@ -34,12 +55,21 @@ func NewGraph(config ...GraphConfig) *Graph {
 
 	return &Graph{
 		conf:                   conf,
-		waitGrp:                new(sync.WaitGroup),
+		lock:                   new(sync.Mutex),
 		procs:                  make(map[string]interface{}),
+		components:             make(map[string]string),
 		inPorts:                make(map[string]port),
 		outPorts:               make(map[string]port),
 		chanListenersCount:     make(map[uintptr]uint),
 		chanListenersCountLock: new(sync.Mutex),
+		supervisors:            make(map[string]Supervision),
+		pools:                  make(map[string][]interface{}),
+		errLock:                new(sync.Mutex),
+		started:                make(map[string]chan struct{}),
+		pending:                make(map[string]bool),
+		params:                 make(map[string]Param),
+		paramValues:            make(map[string]interface{}),
+		paramLock:              new(sync.Mutex),
 	}
 }
 
@@ -58,7 +88,36 @@ func NewDefaultGraph() interface{} {
 // }
 
 // Add adds a new process with a given name to the network.
+// If the network is running, the process is started as soon as all of its ports are connected.
 func (n *Graph) Add(name string, c interface{}) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.add(name, c)
+}
+
+func (n *Graph) add(name string, c interface{}) error {
+	if err := n.checkAdd(name, c); err != nil {
+		return err
+	}
+
+	// Add to the map of processes
+	n.procs[name] = c
+
+	if sub, ok := c.(*Graph); ok {
+		n.setParamParent(sub)
+	}
+
+	if n.running {
+		n.pending[name] = true
+		n.startIfReady(name)
+	}
+
+	return nil
+}
+
+// checkAdd tells why a process cannot be added, if it cannot.
+func (n *Graph) checkAdd(name string, c interface{}) error {
 	// c should be either graph or a component
 	_, isComponent := c.(Component)
 	_, isGraph := c.(Graph)
@@ -66,8 +125,12 @@ func (n *Graph) Add(name string, c interface{}) error {
 	if !isComponent && !isGraph {
 		return fmt.Errorf("could not add process '%s': instance is neither Component nor Graph", name)
 	}
-	// Add to the map of processes
-	n.procs[name] = c
+
+	if n.running {
+		if _, exists := n.procs[name]; exists {
+			return fmt.Errorf("could not add process '%s': name is taken in a running graph", name)
+		}
+	}
 
 	return nil
 }
@@ -79,28 +142,132 @@ func (n *Graph) AddGraph(name string) error {
 }
 
 // AddNew creates a new process instance using component factory and adds it to the network.
+// The component name may contain a version constraint, e.g. "Split@^1.2". The graph
+// records the exact component version the process has been created from.
 func (n *Graph) AddNew(processName string, componentName string, f *Factory) error {
-	proc, err := f.Create(componentName)
+	ref, err := f.resolveRef(componentName)
+	if err != nil {
+		return err
+	}
+
+	proc, err := f.Create(ref)
 	if err != nil {
 		return err
 	}
 
-	return n.Add(processName, proc)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if err := n.add(processName, proc); err != nil {
+		return err
+	}
+
+	n.components[processName] = ref
+	n.factory = f
+
+	return nil
+}
+
+// ComponentOf returns the name and version of the component a process has been
+// created from using a factory, e.g. "Split@1.2.0". It returns an empty string
+// for processes added as instances.
+func (n *Graph) ComponentOf(processName string) string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.components[processName]
 }
 
 // Remove deletes a process from the graph. First it stops the process if running.
 // Then it disconnects it from other processes and removes the connections from
 // the graph. Then it drops the process itself.
+//
+// A running process is stopped by letting it drain: Remove waits until the process
+// has consumed its input and finished. Its inbound connections must be closed by
+// then, so a running process cannot be removed while processes which send to it
+// are still running; remove them first. Channels of graph inports are closed by
+// their owners. A process which has not been started yet releases its outports,
+// so the channels are closed if nobody else writes to them.
 func (n *Graph) Remove(processName string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	if _, exists := n.procs[processName]; !exists {
 		return fmt.Errorf("could not remove process: '%s' does not exist", processName)
 	}
 
+	if procDone, started := n.started[processName]; started {
+		if sender := n.runningSender(processName); sender != "" {
+			return fmt.Errorf("could not remove process '%s': it receives from running process '%s'", processName, sender)
+		}
+
+		// Let the process drain without blocking the rest of the graph
+		n.lock.Unlock()
+		<-procDone
+		n.lock.Lock()
+	} else if n.pending[processName] {
+		n.closeProcOuts(n.procs[processName])
+	}
+
+	n.disconnectProc(processName)
+
 	delete(n.procs, processName)
+	delete(n.components, processName)
+	delete(n.supervisors, processName)
+	delete(n.pools, processName)
+	delete(n.started, processName)
+	delete(n.pending, processName)
 
 	return nil
 }
 
+// runningSender returns the name of a process which sends to a given one and
+// has not finished yet, if there is any. It must be called with the graph locked.
+func (n *Graph) runningSender(processName string) string {
+	for _, c := range n.connections {
+		if c.tgt.proc != processName || c.src.proc == processName {
+			continue
+		}
+
+		if n.pending[c.src.proc] {
+			return c.src.proc
+		}
+
+		if procDone, started := n.started[c.src.proc]; started {
+			select {
+			case <-procDone:
+			default:
+				return c.src.proc
+			}
+		}
+	}
+
+	return ""
+}
+
+// disconnectProc removes all connections and IIPs of a process from the graph.
+func (n *Graph) disconnectProc(processName string) {
+	conns := n.connections[:0]
+
+	for _, c := range n.connections {
+		if c.src.proc != processName && c.tgt.proc != processName {
+			conns = append(conns, c)
+		}
+	}
+
+	n.connections = conns
+
+	iips := n.iips[:0]
+
+	for _, ip := range n.iips {
+		if ip.addr.proc != processName {
+			iips = append(iips, ip)
+		}
+	}
+
+	n.iips = iips
+}
+
 // // Rename changes a process name in all connections, external ports, IIPs and the
 // // graph itself.
 // func (n *Graph) Rename(processName, newName string) bool {
@@ -154,41 +321,128 @@ func (n *Graph) Remove(processName string) error {
 
 // Process runs the network.
 func (n *Graph) Process() {
-	err := n.sendIIPs()
+	n.lock.Lock()
+
+	last, err := n.lastCheckpoint()
+	if err != nil {
+		n.lock.Unlock()
+		panic(fmt.Errorf("checkpoints: %w", err))
+	}
+
+	err = n.sendIIPs(last)
 	if err != nil {
+		n.lock.Unlock()
 		// TODO provide a nicer way to handle graph errors
 		panic(err)
 	}
 
-	for _, i := range n.procs {
-		c, ok := i.(Component)
-		if !ok {
-			continue
+	if n.checkpointConf != nil {
+		if err := n.startCheckpoints(last); err != nil {
+			n.lock.Unlock()
+			panic(fmt.Errorf("checkpoints: %w", err))
 		}
+	}
+
+	n.running = true
+	n.done = make(chan struct{})
+	n.started = make(map[string]chan struct{})
+	n.pending = make(map[string]bool)
+	n.deadLetters = newDeadLetterQueue(n.deliverDeadLetter(), n.deadLetterClose)
+	done := n.done
+	deadLetters := n.deadLetters
+	checkpoints := n.checkpoints
+
+	n.startAdapters()
+	n.startDurableEdges()
+	n.startPeriodic(deadLetters)
+
+	for name := range n.procs {
+		n.startProc(name)
+	}
+
+	if n.active == 0 {
+		n.finish()
+	}
 
-		n.waitGrp.Add(1)
+	n.lock.Unlock()
 
-		w := Run(c)
-		proc := i
+	<-done
+
+	if checkpoints != nil {
+		checkpoints.close()
+	}
+
+	deadLetters.close()
+}
+
+// startProc launches all goroutines of a process. It must be called with the graph locked.
+func (n *Graph) startProc(name string) {
+	c, ok := n.procs[name].(Component)
+	if !ok {
+		return
+	}
+
+	n.routeDeadLetters(name)
+
+	replicas := append([]Component{c}, n.preparePool(name)...)
+	s, supervised := n.supervisors[name]
+	procDone := make(chan struct{})
+	wg := new(sync.WaitGroup)
+
+	n.started[name] = procDone
+	delete(n.pending, name)
+	n.active += len(replicas)
+	wg.Add(len(replicas))
+
+	if n.checkpoints != nil {
+		n.checkpoints.starting(name, procDone)
+	}
+
+	for _, r := range replicas {
+		r := r
 
 		go func() {
-			<-w
-			n.closeProcOuts(proc)
-			n.waitGrp.Done()
+			if supervised {
+				n.runSupervised(name, r, s)
+			} else {
+				<-Run(r)
+			}
+
+			n.closeProcOuts(r)
+			wg.Done()
+			n.procFinished()
 		}()
 	}
 
-	n.waitGrp.Wait()
+	go func() {
+		wg.Wait()
+		close(procDone)
+	}()
+}
+
+// procFinished accounts for a finished process goroutine.
+func (n *Graph) procFinished() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.active--
+	if n.active == 0 {
+		n.finish()
+	}
+}
+
+// finish marks the network as stopped. It must be called with the graph locked.
+func (n *Graph) finish() {
+	n.running = false
+	close(n.done)
 }
 
 func (n *Graph) closeProcOuts(proc interface{}) {
 	val := reflect.ValueOf(proc).Elem()
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Field(i)
-		fieldType := field.Type()
 
-		if !(field.IsValid() && field.Kind() == reflect.Chan && field.CanSet() &&
-			fieldType.ChanDir()&reflect.SendDir != 0 && fieldType.ChanDir()&reflect.RecvDir == 0) {
+		if !isOutChan(field) {
 			continue
 		}
 
@@ -197,3 +451,14 @@ func (n *Graph) closeProcOuts(proc interface{}) {
 		}
 	}
 }
+
+// isOutChan tells if a struct field is a plain outport.
+func isOutChan(field reflect.Value) bool {
+	if !(field.IsValid() && field.Kind() == reflect.Chan && field.CanSet()) {
+		return false
+	}
+
+	dir := field.Type().ChanDir()
+
+	return dir&reflect.SendDir != 0 && dir&reflect.RecvDir == 0
+}
@@ -0,0 +1,992 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stateful is implemented by components which keep state between packets,
+// like aggregations, so that it can be saved in checkpoints and restored
+// when the graph is restarted. Snapshot is called while the process is running,
+// so components have to guard their state against concurrent access.
+type Stateful interface {
+	Snapshot() ([]byte, error)
+	Restore(state []byte) error
+}
+
+// Acks lets a process acknowledge the packets it has handled. Processes embed it
+// and call Ack for every packet they receive, so that checkpoints wait for the
+// packets they are still handling before their states are saved. Processes which
+// do not embed it are snapshot as soon as their inputs are held, so a packet they
+// are handling at that moment is missing from the checkpoint.
+type Acks struct {
+	lock    sync.Mutex
+	counts  map[string]int64
+	changed chan struct{} // Closed on the next acknowledgement
+}
+
+// Ack acknowledges a packet received on an inport like "In" or "In[0]" once the
+// process has handled it, including sending the packets it results in.
+func (a *Acks) Ack(port string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.counts == nil {
+		a.counts = make(map[string]int64)
+	}
+
+	a.counts[port]++
+
+	if a.changed != nil {
+		close(a.changed)
+		a.changed = nil
+	}
+}
+
+// acked returns the number of packets acknowledged on a port and a channel
+// which is closed on the next acknowledgement.
+func (a *Acks) acked(port string) (int64, <-chan struct{}) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.changed == nil {
+		a.changed = make(chan struct{})
+	}
+
+	return a.counts[port], a.changed
+}
+
+// resetAcks forgets the packets acknowledged in a previous run.
+func (a *Acks) resetAcks() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.counts = nil
+}
+
+// acknowledger is implemented by processes which embed Acks.
+type acknowledger interface {
+	acked(port string) (int64, <-chan struct{})
+	resetAcks()
+}
+
+// Checkpoint is a consistent snapshot of a running graph.
+type Checkpoint struct {
+	ID       int                          `json:"id"`
+	Time     time.Time                    `json:"time"`
+	States   map[string][]byte            `json:"states,omitempty"`   // States of Stateful processes by process name
+	InFlight map[string][]json.RawMessage `json:"inFlight,omitempty"` // Packets not yet received by ports like "proc.In" or graph outports
+	InPorts  map[string]int               `json:"inPorts,omitempty"`  // Packets read from graph inports before the checkpoint
+	OutPorts map[string]int               `json:"outPorts,omitempty"` // Packets sent to graph outports before the checkpoint, including in-flight ones
+}
+
+// CheckpointStore saves checkpoints and loads the last one.
+type CheckpointStore interface {
+	Save(c *Checkpoint) error
+	// Last returns the last saved checkpoint or nil if there is none.
+	Last() (*Checkpoint, error)
+}
+
+// DirStore keeps the last checkpoint as a JSON file in a local directory.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a store writing checkpoints to dir, which is created if needed.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+const checkpointFilePrefix = "checkpoint-"
+
+// Save writes a checkpoint to a temporary file and renames it, so an interrupted
+// save does not damage the previous checkpoint, which is removed afterwards.
+func (s *DirStore) Save(c *Checkpoint) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(s.dir, ".checkpoint")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	name := filepath.Join(s.dir, fmt.Sprintf("%s%09d.json", checkpointFilePrefix, c.ID))
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	old, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range old {
+		if f != name {
+			os.Remove(f)
+		}
+	}
+
+	return nil
+}
+
+// Last loads the checkpoint with the highest ID.
+func (s *DirStore) Last() (*Checkpoint, error) {
+	files, err := s.files()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(files[len(files)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(Checkpoint)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", files[len(files)-1], err)
+	}
+
+	return c, nil
+}
+
+// files lists checkpoint files sorted by ID.
+func (s *DirStore) files() ([]string, error) {
+	res, err := filepath.Glob(filepath.Join(s.dir, checkpointFilePrefix+"*.json"))
+	sort.Strings(res)
+
+	return res, err
+}
+
+// CheckpointConfig sets up checkpointing of a graph.
+type CheckpointConfig struct {
+	Store    CheckpointStore
+	Interval time.Duration // Checkpoints are taken periodically if it is not 0
+	// ReplayInputs tells that a restarted graph is fed from the beginning of its
+	// sources, so packets of graph inports read before the checkpoint are skipped.
+	// Otherwise the sources should be resumed from the InPorts of the last checkpoint.
+	ReplayInputs bool
+}
+
+// SetCheckpoints enables checkpoints of the graph. When the graph starts, it is
+// restored from the last checkpoint of the store if there is one: Stateful
+// processes get their states back and packets which were in flight are sent again.
+// IIPs are not sent again to processes which get their states back, as their
+// states already reflect them.
+//
+// Checkpoints follow the Chandy–Lamport algorithm. Every connection and graph port
+// gets a relay, and a checkpoint sends markers from graph inports and processes
+// which have no inputs. When a marker first reaches a process, its inputs are held
+// and, if the process embeds Acks, the checkpoint waits until it has acknowledged
+// every packet delivered to it. Then its state is saved and the markers are passed
+// to its outputs. Packets which arrive to a process after its snapshot but before
+// the marker of their connection are saved as in-flight.
+//
+// A relay queues up to the buffer size of its connection, at least one packet,
+// before its sender blocks. Held relays queue packets without a limit, so that
+// checkpoints do not block senders. Failures of periodic checkpoints are sent
+// to the dead letters of the graph.
+//
+// Subgraphs, process pools and ports which several connections send to or receive
+// from are not supported, Merge and Split components can be used instead.
+// The graph must not be running.
+func (n *Graph) SetCheckpoints(conf CheckpointConfig) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.checkpointConf = &conf
+}
+
+// Checkpoint takes a checkpoint of the running graph, saves it to the store and returns it.
+func (n *Graph) Checkpoint() (*Checkpoint, error) {
+	n.lock.Lock()
+	cp := n.checkpoints
+	running := n.running
+	n.lock.Unlock()
+
+	if cp == nil {
+		return nil, fmt.Errorf("checkpoint: checkpoints are not enabled")
+	}
+
+	if !running {
+		return nil, fmt.Errorf("checkpoint: graph is not running")
+	}
+
+	c, err := cp.take()
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: %w", err)
+	}
+
+	return c, nil
+}
+
+// LastCheckpoint returns the last checkpoint taken or restored, or nil if there is none.
+func (n *Graph) LastCheckpoint() *Checkpoint {
+	n.lock.Lock()
+	cp := n.checkpoints
+	n.lock.Unlock()
+
+	if cp == nil {
+		return nil
+	}
+
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	return cp.last
+}
+
+// checkpointer takes checkpoints of a running graph.
+type checkpointer struct {
+	conf    CheckpointConfig
+	procs   map[string]interface{}
+	running map[string]<-chan struct{} // Closed when all goroutines of a process have finished
+	relays  []*cpRelay
+	inputs  map[string][]*cpRelay // Relays by receiving process
+	outputs map[string][]*cpRelay // Relays by sending process
+	ins     []*cpRelay            // Relays of graph inports
+	taking  sync.Mutex            // Allows one checkpoint at a time
+	lock    sync.Mutex
+	last    *Checkpoint
+	drained sync.WaitGroup // Relays which have not closed their receivers yet
+	ticking sync.WaitGroup // Periodic checkpoints
+	stop    chan struct{}
+}
+
+// cpRound is a checkpoint in progress.
+type cpRound struct {
+	c       *Checkpoint
+	started map[string]bool // Processes whose snapshot has started
+	pending int             // Processes and relays which have not finished
+	err     error
+	done    chan struct{}
+}
+
+// finish accounts for a process or relay which has finished. It must be called with the checkpointer locked.
+func (r *cpRound) finish(err error) {
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+
+	r.pending--
+	if r.pending == 0 {
+		close(r.done)
+	}
+}
+
+// cpItem is a packet or a marker in the queue of a relay.
+type cpItem struct {
+	packet reflect.Value
+	marker int // Checkpoint ID of a marker, 0 for packets
+}
+
+// cpRelay passes packets of a connection or a graph port, queueing them so that
+// senders are not blocked by checkpoints.
+type cpRelay struct {
+	name      string // Receiving port like "proc.In" or graph port name
+	port      string // Receiving port of the process like "In" or "In[0]"
+	send      string // Sending process, empty for graph inports
+	recv      string // Receiving process, empty for graph outports
+	from      reflect.Value
+	to        reflect.Value
+	ctrl      chan func()
+	queue     []cpItem
+	limit     int   // Packets queued before the sender is blocked
+	skip      int   // Packets to drop from a replayed graph inport
+	received  int64 // Packets received from the sender, accessed atomically
+	delivered int64 // Packets sent to the receiver
+	held      bool
+	closed    bool
+	// State of the checkpoint in progress
+	round     int
+	marked    bool // The marker has arrived
+	scanned   bool // The receiving process has been snapshot
+	recording bool // Packets are saved as in-flight until the marker arrives
+	inFlight  []json.RawMessage
+}
+
+// lastCheckpoint loads the checkpoint the graph is restored from, nil if checkpoints
+// are disabled or there is none. It must be called with the graph locked.
+func (n *Graph) lastCheckpoint() (*Checkpoint, error) {
+	if n.checkpointConf == nil {
+		return nil, nil
+	}
+
+	return n.checkpointConf.Store.Last()
+}
+
+// restores tells if a process gets its state back from the checkpoint.
+func (c *Checkpoint) restores(proc string) bool {
+	if c == nil {
+		return false
+	}
+
+	_, ok := c.States[proc]
+
+	return ok
+}
+
+// startCheckpoints installs relays and restores the graph from the last checkpoint,
+// which may be nil. It must be called with the graph locked before the processes
+// are started.
+func (n *Graph) startCheckpoints(last *Checkpoint) error {
+	cp := &checkpointer{
+		conf:    *n.checkpointConf,
+		procs:   n.procs,
+		running: make(map[string]<-chan struct{}),
+		inputs:  make(map[string][]*cpRelay),
+		outputs: make(map[string][]*cpRelay),
+		stop:    make(chan struct{}),
+	}
+
+	if err := n.checkCheckpoints(); err != nil {
+		return err
+	}
+
+	for _, c := range n.connections {
+		r, err := n.relayTo(c.tgt, c.channel)
+		if err != nil {
+			return err
+		}
+
+		r.send = c.src.proc
+		r.limit = c.channel.Cap()
+		cp.add(r)
+	}
+
+	for _, name := range sortedPortNames(n.inPorts) {
+		p := n.inPorts[name]
+		if isNilChan(p.channel) {
+			continue
+		}
+
+		r, err := n.relayTo(p.addr, p.channel)
+		if err != nil {
+			return err
+		}
+
+		r.name = name
+		r.limit = p.channel.Cap()
+		cp.add(r)
+		cp.ins = append(cp.ins, r)
+	}
+
+	for _, name := range sortedPortNames(n.outPorts) {
+		p := n.outPorts[name]
+		if isNilChan(p.channel) {
+			continue
+		}
+
+		port, err := n.getProcPort(p.addr.proc, p.addr.port, reflect.SendDir)
+		if err != nil {
+			return err
+		}
+
+		from, err := attachPort(port, p.addr, reflect.SendDir, reflect.MakeChan(reflect.ChanOf(reflect.BothDir, p.channel.Type().Elem()), 0), 0)
+		if err != nil {
+			return err
+		}
+
+		cp.add(&cpRelay{name: name, send: p.addr.proc, from: from, to: p.channel, limit: p.channel.Cap()})
+	}
+
+	if last != nil {
+		if err := cp.restore(last); err != nil {
+			return err
+		}
+	}
+
+	for _, proc := range cp.procs {
+		if a, ok := proc.(acknowledger); ok {
+			a.resetAcks()
+		}
+	}
+
+	for _, r := range cp.relays {
+		if r.limit < 1 {
+			r.limit = 1
+		}
+
+		r.ctrl = make(chan func())
+		cp.drained.Add(1)
+
+		go r.run(cp)
+	}
+
+	n.checkpoints = cp
+
+	return nil
+}
+
+// startPeriodic takes checkpoints at the configured interval, if any, and sends
+// failures to the dead letters. It must be called with the graph locked.
+func (n *Graph) startPeriodic(letters *deadLetterQueue) {
+	cp := n.checkpoints
+	if cp == nil || cp.conf.Interval <= 0 {
+		return
+	}
+
+	cp.ticking.Add(1)
+
+	go cp.periodic(letters)
+}
+
+// starting records a process which is about to run, done is closed when it finishes.
+func (cp *checkpointer) starting(name string, done <-chan struct{}) {
+	cp.lock.Lock()
+	cp.running[name] = done
+	cp.lock.Unlock()
+}
+
+// checkCheckpoints tells if the graph can be checkpointed. It must be called with the graph locked.
+func (n *Graph) checkCheckpoints() error {
+	for _, name := range sortedProcNames(n.procs) {
+		if _, ok := n.procs[name].(*Graph); ok {
+			return fmt.Errorf("subgraph '%s' cannot be checkpointed", name)
+		}
+
+		if len(n.pools[name]) > 0 {
+			return fmt.Errorf("pool '%s' cannot be checkpointed", name)
+		}
+	}
+
+	senders := make(map[address]bool)
+	receivers := make(map[address]bool)
+
+	for _, c := range n.connections {
+		if c.durable != nil {
+			return fmt.Errorf("durable connection '%s -> %s' cannot be checkpointed", c.src, c.tgt)
+		}
+
+		if senders[c.src] {
+			return fmt.Errorf("port '%s' sends to several connections, which cannot be checkpointed", c.src)
+		}
+
+		if receivers[c.tgt] {
+			return fmt.Errorf("port '%s' receives from several connections, which cannot be checkpointed", c.tgt)
+		}
+
+		senders[c.src] = true
+		receivers[c.tgt] = true
+	}
+
+	return nil
+}
+
+// relayTo attaches a new channel to a receiving port and returns a relay which
+// passes packets from ch to it. It must be called with the graph locked.
+func (n *Graph) relayTo(addr address, ch reflect.Value) (*cpRelay, error) {
+	port, err := n.getProcPort(addr.proc, addr.port, reflect.RecvDir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := packetType(port)
+	if t == nil {
+		return nil, fmt.Errorf("port '%s' is not a channel", addr)
+	}
+
+	to, err := attachPort(port, addr, reflect.RecvDir, reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t), 0), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	name := endpointOf(addr).portName()
+
+	return &cpRelay{name: addr.proc + "." + name, port: name, recv: addr.proc, from: ch, to: to}, nil
+}
+
+func (cp *checkpointer) add(r *cpRelay) {
+	cp.relays = append(cp.relays, r)
+
+	if r.recv != "" {
+		cp.inputs[r.recv] = append(cp.inputs[r.recv], r)
+	}
+
+	if r.send != "" {
+		cp.outputs[r.send] = append(cp.outputs[r.send], r)
+	}
+}
+
+// restore brings the states of processes and the in-flight packets back from a checkpoint.
+func (cp *checkpointer) restore(c *Checkpoint) error {
+	for _, name := range sortedProcNames(cp.procs) {
+		state, ok := c.States[name]
+		if !ok {
+			continue
+		}
+
+		s, ok := cp.procs[name].(Stateful)
+		if !ok {
+			return fmt.Errorf("process '%s' has a saved state but is not Stateful", name)
+		}
+
+		if err := s.Restore(state); err != nil {
+			return fmt.Errorf("restore '%s': %w", name, err)
+		}
+	}
+
+	for _, r := range cp.relays {
+		for _, raw := range c.InFlight[r.name] {
+			v, err := replayPacket(raw, r.to.Type().Elem())
+			if err != nil {
+				return fmt.Errorf("in-flight packet of '%s': %w", r.name, err)
+			}
+
+			r.queue = append(r.queue, cpItem{packet: v})
+		}
+	}
+
+	// Packet counts of graph ports go on from the checkpoint
+	for _, r := range cp.relays {
+		switch {
+		case r.send == "" && cp.conf.ReplayInputs:
+			r.skip = c.InPorts[r.name]
+		case r.send == "":
+			r.received = int64(c.InPorts[r.name])
+		case r.recv == "":
+			r.received = int64(c.OutPorts[r.name])
+		}
+	}
+
+	cp.last = c
+
+	return nil
+}
+
+// periodic takes checkpoints at the configured interval until the graph finishes.
+func (cp *checkpointer) periodic(letters *deadLetterQueue) {
+	defer cp.ticking.Done()
+
+	ticker := time.NewTicker(cp.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := cp.take(); err != nil {
+				letters.add(DeadLetter{Time: time.Now(), Err: fmt.Errorf("checkpoint: %w", err)})
+			}
+		case <-cp.stop:
+			return
+		}
+	}
+}
+
+// close stops the relays once they have passed all packets.
+func (cp *checkpointer) close() {
+	cp.drained.Wait()
+
+	cp.taking.Lock()
+	close(cp.stop)
+	cp.taking.Unlock()
+
+	cp.ticking.Wait()
+}
+
+// take runs a checkpoint and saves it to the store.
+func (cp *checkpointer) take() (*Checkpoint, error) {
+	cp.taking.Lock()
+	defer cp.taking.Unlock()
+
+	select {
+	case <-cp.stop:
+		return nil, fmt.Errorf("graph has finished")
+	default:
+	}
+
+	cp.lock.Lock()
+
+	id := 1
+	if cp.last != nil {
+		id = cp.last.ID + 1
+	}
+
+	round := &cpRound{
+		c: &Checkpoint{
+			ID:       id,
+			Time:     time.Now(),
+			States:   make(map[string][]byte),
+			InFlight: make(map[string][]json.RawMessage),
+			InPorts:  make(map[string]int),
+			OutPorts: make(map[string]int),
+		},
+		started: make(map[string]bool),
+		pending: len(cp.procs) + len(cp.relays),
+		done:    make(chan struct{}),
+	}
+
+	// Processes without inputs start the checkpoint along with graph inports
+	var sources []string
+
+	for _, name := range sortedProcNames(cp.procs) {
+		if len(cp.inputs[name]) == 0 {
+			round.started[name] = true
+			sources = append(sources, name)
+		}
+	}
+	cp.lock.Unlock()
+
+	for _, r := range cp.ins {
+		r := r
+		r.do(func() { cp.arrive(r, round) })
+	}
+
+	for _, name := range sources {
+		go cp.snapshotProcess(name, round)
+	}
+
+	<-round.done
+
+	if round.err != nil {
+		return nil, round.err
+	}
+
+	if err := cp.conf.Store.Save(round.c); err != nil {
+		return nil, err
+	}
+
+	cp.lock.Lock()
+	cp.last = round.c
+	cp.lock.Unlock()
+
+	return round.c, nil
+}
+
+// snapshotProcess holds the inputs of a process, saves its state and passes the markers on.
+func (cp *checkpointer) snapshotProcess(name string, round *cpRound) {
+	inputs := cp.inputs[name]
+	delivered := make([]int64, len(inputs))
+
+	for i, r := range inputs {
+		i, r := i, r
+		r.do(func() {
+			cp.scan(r, round)
+			delivered[i] = r.delivered
+		})
+	}
+
+	cp.awaitAcks(name, inputs, delivered)
+
+	var (
+		state []byte
+		err   error
+	)
+
+	if s, ok := cp.procs[name].(Stateful); ok {
+		if state, err = s.Snapshot(); err != nil {
+			err = fmt.Errorf("snapshot '%s': %w", name, err)
+		}
+	}
+
+	for _, r := range cp.outputs[name] {
+		r := r
+		r.do(func() { cp.arrive(r, round) })
+	}
+
+	for _, r := range inputs {
+		r := r
+		r.do(func() { r.release(round.c.ID) })
+	}
+
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if state != nil {
+		round.c.States[name] = state
+	}
+
+	round.finish(err)
+}
+
+// awaitAcks waits until a process which embeds Acks has acknowledged the packets
+// delivered to its inputs or has finished.
+func (cp *checkpointer) awaitAcks(name string, inputs []*cpRelay, delivered []int64) {
+	a, ok := cp.procs[name].(acknowledger)
+	if !ok {
+		return
+	}
+
+	cp.lock.Lock()
+	done := cp.running[name]
+	cp.lock.Unlock()
+
+	for i, r := range inputs {
+		for {
+			acked, changed := a.acked(r.port)
+			if acked >= delivered[i] {
+				break
+			}
+
+			select {
+			case <-changed:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// arrive handles a marker sent to a relay. It must be called from the relay goroutine.
+func (cp *checkpointer) arrive(r *cpRelay, round *cpRound) {
+	// Packets buffered in the channel have been sent before the marker
+	if r.send != "" {
+		for {
+			v, ok := r.from.TryRecv()
+			if !ok {
+				break
+			}
+
+			r.receive(v)
+		}
+	}
+
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	r.reset(round.c.ID)
+	r.marked = true
+
+	switch {
+	case r.send == "":
+		round.c.InPorts[r.name] = int(atomic.LoadInt64(&r.received))
+	case r.recv == "":
+		round.c.OutPorts[r.name] = int(atomic.LoadInt64(&r.received))
+	}
+
+	switch {
+	case r.recv == "":
+		// Packets not yet taken from a graph outport are sent again on restart
+		for _, item := range r.queue {
+			if item.marker == 0 {
+				r.record(item.packet)
+			}
+		}
+
+		cp.saveInFlight(r, round)
+	case r.scanned:
+		r.recording = false
+		cp.saveInFlight(r, round)
+	default:
+		r.queue = append(r.queue, cpItem{marker: round.c.ID})
+
+		if !round.started[r.recv] {
+			round.started[r.recv] = true
+
+			go cp.snapshotProcess(r.recv, round)
+		}
+	}
+}
+
+// scan holds a relay while its receiving process is snapshot and finds the packets
+// in flight. It must be called from the relay goroutine.
+func (cp *checkpointer) scan(r *cpRelay, round *cpRound) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	r.reset(round.c.ID)
+	r.scanned = true
+	r.held = true
+
+	for _, item := range r.queue {
+		if item.marker == round.c.ID {
+			break
+		}
+
+		if item.marker == 0 {
+			r.record(item.packet)
+		}
+	}
+
+	if r.marked {
+		cp.saveInFlight(r, round)
+		return
+	}
+
+	r.recording = true
+}
+
+// saveInFlight adds the packets in flight of a relay to the checkpoint. It must
+// be called with the checkpointer locked.
+func (cp *checkpointer) saveInFlight(r *cpRelay, round *cpRound) {
+	var err error
+
+	for _, p := range r.inFlight {
+		if p == nil {
+			err = fmt.Errorf("in-flight packet of '%s' cannot be encoded", r.name)
+		}
+	}
+
+	if len(r.inFlight) > 0 {
+		round.c.InFlight[r.name] = r.inFlight
+	}
+
+	round.finish(err)
+}
+
+// reset clears the state of a previous checkpoint.
+func (r *cpRelay) reset(id int) {
+	if r.round == id {
+		return
+	}
+
+	r.round = id
+	r.marked = false
+	r.scanned = false
+	r.recording = false
+	r.inFlight = nil
+}
+
+// record saves an in-flight packet, nil stands for one which cannot be encoded.
+func (r *cpRelay) record(v reflect.Value) {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		data = nil
+	}
+
+	r.inFlight = append(r.inFlight, data)
+}
+
+// release lets packets through after a snapshot.
+func (r *cpRelay) release(id int) {
+	r.held = false
+
+	for i, item := range r.queue {
+		if item.marker == id {
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+			break
+		}
+	}
+}
+
+// do runs f in the relay goroutine and waits for it.
+func (r *cpRelay) do(f func()) {
+	done := make(chan struct{})
+
+	r.ctrl <- func() {
+		f()
+		close(done)
+	}
+
+	<-done
+}
+
+// receive queues a packet from the sender.
+func (r *cpRelay) receive(v reflect.Value) {
+	atomic.AddInt64(&r.received, 1)
+
+	if r.skip > 0 {
+		r.skip--
+		return
+	}
+
+	r.queue = append(r.queue, cpItem{packet: v})
+
+	if r.recording {
+		r.record(v)
+	}
+}
+
+// run passes packets until the graph finishes.
+func (r *cpRelay) run(cp *checkpointer) {
+	drained := false
+
+	for {
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r.ctrl)},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cp.stop)},
+		}
+
+		recv, send := -1, -1
+
+		if !r.closed && !r.full() {
+			recv = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: r.from})
+		}
+
+		if !r.held && len(r.queue) > 0 && r.queue[0].marker == 0 {
+			send = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: r.to, Send: r.queue[0].packet})
+		}
+
+		if r.closed && !drained && !r.hasPackets() {
+			r.to.Close()
+			cp.drained.Done()
+
+			drained = true
+		}
+
+		chosen, v, ok := reflect.Select(cases)
+
+		switch chosen {
+		case 0:
+			v.Interface().(func())()
+		case 1:
+			return
+		case recv:
+			if !ok {
+				r.closed = true
+				continue
+			}
+
+			r.receive(v)
+		case send:
+			r.queue = r.queue[1:]
+			r.delivered++
+		}
+	}
+}
+
+// full tells if the queue has reached its limit. Held relays are never full.
+func (r *cpRelay) full() bool {
+	if r.held {
+		return false
+	}
+
+	count := 0
+
+	for _, item := range r.queue {
+		if item.marker == 0 {
+			count++
+		}
+	}
+
+	return count >= r.limit
+}
+
+// hasPackets tells if there are packets in the queue.
+func (r *cpRelay) hasPackets() bool {
+	for _, item := range r.queue {
+		if item.marker == 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,567 @@
+package goflow
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSummerGraph creates a graph "d" doubler -> "s" summer with checkpoints.
+func newSummerGraph(store CheckpointStore) (*Graph, error) {
+	n := NewGraph()
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Add("s", new(summer)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Connect("d", "Out", "s", "In"); err != nil {
+		return nil, err
+	}
+
+	n.MapInPort("In", "d", "In")
+	n.MapOutPort("Out", "s", "Out")
+	n.SetCheckpoints(CheckpointConfig{Store: store, ReplayInputs: true})
+
+	return n, nil
+}
+
+func TestCheckpointResume(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+
+	n, err := newSummerGraph(store)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	for i := 1; i <= 3; i++ {
+		in <- i
+		<-out
+	}
+
+	c, err := n.Checkpoint()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(c.States["s"]) != "12" || c.InPorts["In"] != 3 || c.OutPorts["Out"] != 3 || len(c.InFlight) != 0 {
+		t.Errorf("Unexpected checkpoint %+v", c)
+	}
+
+	// Packets after the checkpoint are lost along with the graph
+	in <- 4
+	<-out
+	close(in)
+	<-wait
+
+	// The restarted graph is fed from the beginning
+	n, err = newSummerGraph(store)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res, err := runInts(n, []int{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(res, []int{20, 30}) {
+		t.Errorf("%v != [20 30]", res)
+	}
+
+	if last := n.LastCheckpoint(); last == nil || last.ID != 1 {
+		t.Errorf("Unexpected last checkpoint %+v", last)
+	}
+}
+
+func TestCheckpointInFlight(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+
+	newGraph := func(g *gate) (*Graph, error) {
+		n := NewGraph()
+
+		if err := n.Add("e", new(echo)); err != nil {
+			return nil, err
+		}
+
+		if err := n.Add("g", g); err != nil {
+			return nil, err
+		}
+
+		if err := n.Connect("e", "Out", "g", "In"); err != nil {
+			return nil, err
+		}
+
+		n.MapInPort("In", "e", "In")
+		n.MapOutPort("Out", "g", "Out")
+		n.SetCheckpoints(CheckpointConfig{Store: store, ReplayInputs: true})
+
+		return n, nil
+	}
+
+	g := &gate{open: make(chan struct{})}
+
+	n, err := newGraph(g)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	for i := 1; i <= 3; i++ {
+		in <- i
+	}
+
+	// The gate is closed, so the packets are in flight
+	c, err := n.Checkpoint()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Packets are either waiting for the gate or for the echo
+	inFlight := append(append([]json.RawMessage{}, c.InFlight["g.In"]...), c.InFlight["In"]...)
+	expected := []json.RawMessage{json.RawMessage("1"), json.RawMessage("2"), json.RawMessage("3")}
+
+	if !reflect.DeepEqual(inFlight, expected) || c.InPorts["In"] != 3 {
+		t.Errorf("Unexpected checkpoint %+v", c)
+	}
+
+	close(in)
+	close(g.open)
+
+	for range out {
+	}
+
+	<-wait
+
+	// In-flight packets are sent again after a restart
+	g = &gate{open: make(chan struct{})}
+	close(g.open)
+
+	n, err = newGraph(g)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res, err := runInts(n, []int{1, 2, 3})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(res, []int{1, 2, 3}) {
+		t.Errorf("%v != [1 2 3]", res)
+	}
+}
+
+func TestCheckpointSlowProcess(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+
+	newGraph := func() (*Graph, error) {
+		n := NewGraph()
+
+		if err := n.Add("d", &slowDoubler{delay: 50 * time.Millisecond}); err != nil {
+			return nil, err
+		}
+
+		if err := n.Add("s", new(summer)); err != nil {
+			return nil, err
+		}
+
+		if err := n.Connect("d", "Out", "s", "In"); err != nil {
+			return nil, err
+		}
+
+		n.MapInPort("In", "d", "In")
+		n.MapOutPort("Out", "s", "Out")
+		n.SetCheckpoints(CheckpointConfig{Store: store, ReplayInputs: true})
+
+		return n, nil
+	}
+
+	n, err := newGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	// Relays are bounded, so the outport is read while the checkpoint waits
+	go func() {
+		for range out {
+		}
+	}()
+
+	for i := 1; i <= 3; i++ {
+		in <- i
+	}
+
+	// The doubler is still busy with a packet it has taken
+	if _, err := n.Checkpoint(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	close(in)
+	<-wait
+
+	// No packet is lost between the processes
+	n, err = newGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res, err := runInts(n, []int{1, 2, 3, 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(res) == 0 || res[len(res)-1] != 20 {
+		t.Errorf("%v does not end with 20", res)
+	}
+}
+
+func TestCheckpointIIP(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+
+	newGraph := func() (*Graph, error) {
+		n := NewGraph()
+
+		if err := n.Add("a", new(accumulator)); err != nil {
+			return nil, err
+		}
+
+		if err := n.AddIIP("a", "Start", 100); err != nil {
+			return nil, err
+		}
+
+		n.MapInPort("In", "a", "In")
+		n.MapOutPort("Out", "a", "Out")
+		n.SetCheckpoints(CheckpointConfig{Store: store, ReplayInputs: true})
+
+		return n, nil
+	}
+
+	n, err := newGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	for i := 1; i <= 2; i++ {
+		in <- i
+		<-out
+	}
+
+	c, err := n.Checkpoint()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(c.States["a"]) != "103" {
+		t.Errorf("Unexpected checkpoint %+v", c)
+	}
+
+	close(in)
+	<-wait
+
+	// The restored state is not reset by the IIP
+	n, err = newGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res, err := runInts(n, []int{1, 2, 3})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(res, []int{106}) {
+		t.Errorf("%v != [106]", res)
+	}
+}
+
+func TestCheckpointInterval(t *testing.T) {
+	n, err := newSummerGraph(NewDirStore(t.TempDir()))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.SetCheckpoints(CheckpointConfig{Store: NewDirStore(t.TempDir()), Interval: 10 * time.Millisecond})
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	in <- 1
+	<-out
+
+	for deadline := time.Now().Add(time.Second); n.LastCheckpoint() == nil; {
+		if time.Now().After(deadline) {
+			t.Error("No checkpoint has been taken")
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	close(in)
+	<-wait
+
+	if _, err := n.Checkpoint(); err == nil {
+		t.Error("Expected an error for a finished graph")
+	}
+}
+
+// failingStore cannot save checkpoints.
+type failingStore struct{}
+
+func (failingStore) Save(c *Checkpoint) error {
+	return errors.New("disk is full")
+}
+
+func (failingStore) Last() (*Checkpoint, error) {
+	return nil, nil
+}
+
+func TestCheckpointIntervalFailure(t *testing.T) {
+	n, err := newSummerGraph(failingStore{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.SetCheckpoints(CheckpointConfig{Store: failingStore{}, Interval: 10 * time.Millisecond})
+
+	letters := make(chan DeadLetter, 100)
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		select {
+		case letters <- l:
+		default:
+		}
+	})
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	// Failures of periodic checkpoints are reported as dead letters of the graph
+	select {
+	case l := <-letters:
+		if l.Process != "" || l.String() != "checkpoint: disk is full" {
+			t.Errorf("Unexpected dead letter %+v", l)
+		}
+	case <-time.After(time.Second):
+		t.Error("No failure has been reported")
+	}
+
+	close(in)
+	<-wait
+}
+
+func TestCheckpointBackpressure(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.Add("e", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "e", "In")
+	n.MapOutPort("Out", "e", "Out")
+	n.SetCheckpoints(CheckpointConfig{Store: NewDirStore(t.TempDir())})
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	// Relays of unbuffered ports queue one packet each, so the sender is
+	// blocked while nobody reads the outport
+	sent := 0
+
+	for sent < 10 {
+		select {
+		case in <- sent:
+			sent++
+			continue
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		break
+	}
+
+	if sent != 3 {
+		t.Errorf("%d packets sent, expected 3", sent)
+	}
+
+	close(in)
+
+	for range out {
+	}
+
+	<-wait
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	n := NewGraph()
+
+	if _, err := n.Checkpoint(); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Errorf("Expected an error for disabled checkpoints, got %v", err)
+	}
+
+	for _, name := range []string{"d", "e1", "e2"} {
+		if err := n.Add(name, new(echo)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := n.Connect("d", "Out", "e1", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("d", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.checkCheckpoints(); err == nil || !strings.Contains(err.Error(), "port 'd.Out' sends to several connections") {
+		t.Errorf("Expected an error for fan-out, got %v", err)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+
+	if c, err := store.Last(); c != nil || err != nil {
+		t.Errorf("Unexpected checkpoint %v: %v", c, err)
+	}
+
+	for id := 1; id <= 2; id++ {
+		if err := store.Save(&Checkpoint{ID: id, States: map[string][]byte{"p": []byte("state")}}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	c, err := store.Last()
+	if err != nil || c.ID != 2 || string(c.States["p"]) != "state" {
+		t.Errorf("Unexpected checkpoint %+v: %v", c, err)
+	}
+
+	files, err := store.files()
+	if err != nil || len(files) != 1 {
+		t.Errorf("Unexpected files %v: %v", files, err)
+	}
+}
@@ -23,25 +23,66 @@ func (a address) String() string {
 	return fmt.Sprintf("%s.%s", a.proc, a.port)
 }
 
+// portName returns the port name including the array index or map key if any.
+func (a address) portName() string {
+	if a.key != "" {
+		return a.port + "[" + a.key + "]"
+	}
+
+	return a.port
+}
+
 // connection stores information about a connection within the net.
 type connection struct {
 	src     address
 	tgt     address
-	channel reflect.Value
+	channel reflect.Value // Channel of the receiver
 	buffer  int
+	adapter *ipAdapter   // Relay converting envelopes, if the ports differ in using them
+	durable *durableEdge // Disk queue between the ports, if the connection is durable
+}
+
+// sendChan returns the channel the sender writes to. It differs from the receiver
+// channel if the connection converts envelopes or is durable.
+func (c connection) sendChan() reflect.Value {
+	if c.adapter != nil {
+		return c.adapter.from
+	}
+
+	if c.durable != nil {
+		return c.durable.from
+	}
+
+	return c.channel
 }
 
 // Connect a sender to a receiver and create a channel between them using BufferSize graph configuration.
 // Normally such a connection is unbuffered but you can change by setting flow.DefaultBufferSize > 0 or
-// by using ConnectBuf() function instead.
+// by using ConnectBuf() function instead. Ports of IP envelopes can be connected to ports of plain
+// values, the graph wraps and unwraps the packets then.
 // It returns true on success or panics and returns false if error occurs.
 func (n *Graph) Connect(senderName, senderPort, receiverName, receiverPort string) error {
 	return n.ConnectBuf(senderName, senderPort, receiverName, receiverPort, n.conf.BufferSize)
 }
 
 // ConnectBuf connects a sender to a receiver using a channel with a buffer of a given size.
+// If the network is running, a running process keeps the channel it already has
+// and the other end of the connection is attached to it. Options like Durable change
+// the kind of the connection.
 // It returns true on success or panics and returns false if error occurs.
-func (n *Graph) ConnectBuf(senderName, senderPort, receiverName, receiverPort string, bufferSize int) error {
+func (n *Graph) ConnectBuf(senderName, senderPort, receiverName, receiverPort string, bufferSize int, opts ...ConnectOption) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	var o connectOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return n.connect(senderName, senderPort, receiverName, receiverPort, bufferSize, o)
+}
+
+func (n *Graph) connect(senderName, senderPort, receiverName, receiverPort string, bufferSize int, opts connectOptions) error {
 	sendAddr := parseAddress(senderName, senderPort)
 
 	sendPort, err := n.getProcPort(senderName, sendAddr.port, reflect.SendDir)
@@ -56,28 +97,63 @@ func (n *Graph) ConnectBuf(senderName, senderPort, receiverName, receiverPort st
 		return fmt.Errorf("connect: %w", err)
 	}
 
-	isNewChan := false // tells if a new channel will need to be created for this connection
-	// Try to find an existing outbound channel from the same sender,
-	// so it can be used as fan-out FIFO
-	ch := n.findExistingChan(sendAddr, reflect.SendDir)
-	if !ch.IsValid() || ch.IsNil() {
-		// Then try to find an existing inbound channel to the same receiver,
-		// so it can be used as a fan-in FIFO
-		ch = n.findExistingChan(recvAddr, reflect.RecvDir)
-		if ch.IsValid() && !ch.IsNil() {
-			// Increase the number of listeners on this already used channel
-			n.incChanListenersCount(ch)
-		} else {
-			isNewChan = true
+	var (
+		ch           reflect.Value
+		isNewChan    bool // tells if a new channel will need to be created for this connection
+		senderLive   = n.isStarted(senderName)
+		receiverLive = n.isStarted(receiverName)
+	)
+
+	if opts.queue != nil {
+		if senderLive || receiverLive {
+			return fmt.Errorf("connect '%s -> %s': durable connections cannot be made to running processes", sendAddr, recvAddr)
 		}
+
+		return n.connectDurable(sendPort, recvPort, sendAddr, recvAddr, bufferSize, *opts.queue)
 	}
 
-	if ch, err = attachPort(sendPort, sendAddr, reflect.SendDir, ch, bufferSize); err != nil {
-		return fmt.Errorf("connect '%s.%s': %w", senderName, senderPort, err)
+	if n.hasConnection(func(c connection) bool { return c.src == sendAddr && c.durable != nil }) {
+		return fmt.Errorf("connect '%s': port is used by a durable connection and cannot be shared", sendAddr)
 	}
 
-	if _, err = attachPort(recvPort, recvAddr, reflect.RecvDir, ch, bufferSize); err != nil {
-		return fmt.Errorf("connect '%s.%s': %w", receiverName, receiverPort, err)
+	if !senderLive && !receiverLive {
+		sendType, recvType := packetType(sendPort), packetType(recvPort)
+		if sendType != nil && recvType != nil && needsAdapter(sendType, recvType) {
+			return n.connectAdapted(sendPort, recvPort, sendAddr, recvAddr, bufferSize)
+		}
+	}
+
+	if senderLive || receiverLive {
+		if ch, err = n.liveChan(sendPort, recvPort, sendAddr, recvAddr); err != nil {
+			return fmt.Errorf("connect: %w", err)
+		}
+	} else {
+		// Try to find an existing outbound channel from the same sender,
+		// so it can be used as fan-out FIFO
+		ch = n.findExistingChan(sendAddr, reflect.SendDir)
+		if !ch.IsValid() || ch.IsNil() {
+			// Then try to find an existing inbound channel to the same receiver,
+			// so it can be used as a fan-in FIFO
+			ch = n.findExistingChan(recvAddr, reflect.RecvDir)
+			if ch.IsValid() && !ch.IsNil() {
+				// Increase the number of listeners on this already used channel
+				n.incChanListenersCount(ch)
+			} else {
+				isNewChan = true
+			}
+		}
+	}
+
+	if !senderLive {
+		if ch, err = attachPort(sendPort, sendAddr, reflect.SendDir, ch, bufferSize); err != nil {
+			return fmt.Errorf("connect '%s.%s': %w", senderName, senderPort, err)
+		}
+	}
+
+	if !receiverLive {
+		if _, err = attachPort(recvPort, recvAddr, reflect.RecvDir, ch, bufferSize); err != nil {
+			return fmt.Errorf("connect '%s.%s': %w", receiverName, receiverPort, err)
+		}
 	}
 
 	if isNewChan {
@@ -93,6 +169,11 @@ func (n *Graph) ConnectBuf(senderName, senderPort, receiverName, receiverPort st
 		buffer:  bufferSize,
 	})
 
+	if n.running {
+		n.startIfReady(senderName)
+		n.startIfReady(receiverName)
+	}
+
 	return nil
 }
 
@@ -134,7 +215,7 @@ func (n *Graph) getProcPort(procName, portName string, dir reflect.ChanDir) (ref
 
 		p, ok := ports[portName]
 		if !ok {
-			return nilValue, fmt.Errorf("getProcPort: subgraph '%s' does not have inport '%s'", procName, portName)
+			return nilValue, fmt.Errorf("getProcPort: subgraph '%s' does not have port '%s'", procName, portName)
 		}
 
 		portVal, err = net.getProcPort(p.addr.proc, p.addr.port, dir)
@@ -171,6 +252,10 @@ func attachChanPort(port reflect.Value, dir reflect.ChanDir, ch reflect.Value, b
 		return ch, err
 	}
 
+	if err := validateChanType(port.Type(), ch); err != nil {
+		return ch, err
+	}
+
 	if err := validateCanSet(port); err != nil {
 		return ch, err
 	}
@@ -186,6 +271,10 @@ func attachMapPort(port reflect.Value, key string, dir reflect.ChanDir, ch refle
 		return ch, err
 	}
 
+	if err := validateChanType(port.Type().Elem(), ch); err != nil {
+		return ch, err
+	}
+
 	kv := reflect.ValueOf(key)
 	item := port.MapIndex(kv)
 	ch = selectOrMakeChan(ch, item, port.Type().Elem().Elem(), bufSize)
@@ -205,6 +294,10 @@ func attachArrayPort(port reflect.Value, key int, dir reflect.ChanDir, ch reflec
 		return ch, err
 	}
 
+	if err := validateChanType(port.Type().Elem(), ch); err != nil {
+		return ch, err
+	}
+
 	if port.IsNil() {
 		m := reflect.MakeSlice(port.Type(), 0, 32)
 		port.Set(m)
@@ -237,6 +330,15 @@ func validateChanDir(portType reflect.Type, dir reflect.ChanDir) error {
 	return nil
 }
 
+// validateChanType checks that an existing channel carries packets of the port type.
+func validateChanType(portType reflect.Type, ch reflect.Value) error {
+	if isNilChan(ch) || ch.Type().Elem() == portType.Elem() {
+		return nil
+	}
+
+	return fmt.Errorf("channel of %s cannot be attached to a port of %s", ch.Type().Elem(), portType.Elem())
+}
+
 func validateCanSet(portVal reflect.Value) error {
 	if !portVal.CanSet() {
 		return fmt.Errorf("port is not assignable")
@@ -323,6 +425,10 @@ func (n *Graph) findExistingChan(addr address, dir reflect.ChanDir) reflect.Valu
 
 		if a == addr {
 			channel = n.connections[i].channel
+			if dir == reflect.SendDir {
+				channel = n.connections[i].sendChan()
+			}
+
 			break
 		}
 	}
@@ -345,6 +451,30 @@ func (n *Graph) incChanListenersCount(c reflect.Value) {
 	n.chanListenersCount[ptr] = cnt
 }
 
+// incChanListenersCountIfOpen registers one more sender for a channel which has
+// senders left. It returns false if the last sender is gone, so the channel is closed.
+func (n *Graph) incChanListenersCountIfOpen(c reflect.Value) bool {
+	n.chanListenersCountLock.Lock()
+	defer n.chanListenersCountLock.Unlock()
+
+	ptr := c.Pointer()
+	if n.chanListenersCount[ptr] == 0 {
+		return false
+	}
+
+	n.chanListenersCount[ptr]++
+
+	return true
+}
+
+// chanListenersCountOf returns the number of senders registered for a channel.
+func (n *Graph) chanListenersCountOf(c reflect.Value) uint {
+	n.chanListenersCountLock.Lock()
+	defer n.chanListenersCountLock.Unlock()
+
+	return n.chanListenersCount[c.Pointer()]
+}
+
 // decChanListenersCount decrements SendChanRefCount
 // It returns true if the RefCount has reached 0.
 func (n *Graph) decChanListenersCount(c reflect.Value) bool {
@@ -364,43 +494,93 @@ func (n *Graph) decChanListenersCount(c reflect.Value) bool {
 	return cnt == 0
 }
 
-// // Disconnect removes a connection between sender's outport and receiver's inport.
-// func (n *Graph) Disconnect(senderName, senderPort, receiverName, receiverPort string) bool {
-// 	var sender, receiver interface{}
-// 	var ok bool
-// 	sender, ok = n.procs[senderName]
-// 	if !ok {
-// 		return false
-// 	}
-// 	receiver, ok = n.procs[receiverName]
-// 	if !ok {
-// 		return false
-// 	}
-// 	res := unsetProcPort(sender, senderPort, true)
-// 	res = res && unsetProcPort(receiver, receiverPort, false)
-// 	return res
-// }
-
-// // Unsets an port of a given process
-// func unsetProcPort(proc interface{}, portName string, isOut bool) bool {
-// 	v := reflect.ValueOf(proc)
-// 	var ch reflect.Value
-// 	if v.Elem().FieldByName("Graph").IsValid() {
-// 		if subnet, ok := v.Elem().FieldByName("Graph").Addr().Interface().(*Graph); ok {
-// 			if isOut {
-// 				ch = subnet.getOutPort(portName)
-// 			} else {
-// 				ch = subnet.getInPort(portName)
-// 			}
-// 		} else {
-// 			return false
-// 		}
-// 	} else {
-// 		ch = v.Elem().FieldByName(portName)
-// 	}
-// 	if !ch.IsValid() {
-// 		return false
-// 	}
-// 	ch.Set(reflect.Zero(ch.Type()))
-// 	return true
-// }
+// Disconnect removes a connection between sender's outport and receiver's inport.
+// The ports are detached unless they take part in other connections. Processes
+// which are running cannot be disconnected because their ports are in use.
+func (n *Graph) Disconnect(senderName, senderPort, receiverName, receiverPort string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	sendAddr := parseAddress(senderName, senderPort)
+	recvAddr := parseAddress(receiverName, receiverPort)
+
+	i := -1
+
+	for j := range n.connections {
+		if n.connections[j].src == sendAddr && n.connections[j].tgt == recvAddr {
+			i = j
+			break
+		}
+	}
+
+	if i < 0 {
+		return fmt.Errorf("disconnect: connection '%s -> %s' not found", sendAddr, recvAddr)
+	}
+
+	for _, name := range []string{senderName, receiverName} {
+		if n.isStarted(name) {
+			return fmt.Errorf("disconnect: process '%s' is running", name)
+		}
+	}
+
+	// Ports are looked up first, so that a failure leaves the graph as it is
+	sendPort, err := n.getProcPort(sendAddr.proc, sendAddr.port, reflect.SendDir)
+	if err != nil {
+		return fmt.Errorf("disconnect: %w", err)
+	}
+
+	recvPort, err := n.getProcPort(recvAddr.proc, recvAddr.port, reflect.RecvDir)
+	if err != nil {
+		return fmt.Errorf("disconnect: %w", err)
+	}
+
+	conn := n.connections[i]
+	n.connections = append(n.connections[:i], n.connections[i+1:]...)
+
+	if !n.hasConnection(func(c connection) bool { return c.src == sendAddr }) {
+		detachPort(sendPort, sendAddr)
+		n.decChanListenersCount(conn.sendChan())
+	}
+
+	if conn.adapter != nil || conn.durable != nil {
+		n.decChanListenersCount(conn.channel)
+	}
+
+	if conn.durable != nil {
+		close(conn.durable.stop)
+	}
+
+	if !n.hasConnection(func(c connection) bool { return c.tgt == recvAddr }) {
+		detachPort(recvPort, recvAddr)
+	}
+
+	return nil
+}
+
+// hasConnection tells if any connection matches the condition.
+func (n *Graph) hasConnection(match func(c connection) bool) bool {
+	for _, c := range n.connections {
+		if match(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detachPort sets a process port or an element of an array or map port to nil.
+func detachPort(port reflect.Value, addr address) {
+	switch {
+	case addr.index > -1:
+		if port.Kind() == reflect.Slice && addr.index < port.Len() {
+			port.Index(addr.index).Set(reflect.Zero(port.Type().Elem()))
+		}
+	case addr.key != "":
+		if port.Kind() == reflect.Map && !port.IsNil() {
+			port.SetMapIndex(reflect.ValueOf(addr.key), reflect.Value{})
+		}
+	default:
+		port.Set(reflect.Zero(port.Type()))
+	}
+
+}
@@ -0,0 +1,303 @@
+package goflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrPort is the name of the outport components report failures to, e.g.
+//
+//	Err chan<- error
+//
+// A process whose Err outport is not connected has it routed to the dead letters of the graph.
+const ErrPort = "Err"
+
+// DeadLetterPort is the name of the graph outport which receives dead letters
+// when it is set with SetOutPort to a chan DeadLetter. It does not need to be mapped.
+const DeadLetterPort = "DeadLetters"
+
+// PacketError is a failure to process a packet. Components send it to their
+// Err outport to tell which packet has failed.
+type PacketError struct {
+	Packet interface{}
+	Err    error
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("packet %v: %s", e.Packet, e.Err)
+}
+
+func (e *PacketError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetter is an error sent to an Err outport which is not connected.
+// Errors of the graph itself, like failed periodic checkpoints, have no process.
+type DeadLetter struct {
+	Time    time.Time
+	Process string // Process name, prefixed with subgraph names, e.g. "sub.proc"
+	Port    string
+	Packet  interface{} // Offending packet if the error is a PacketError
+	Err     error
+}
+
+// MarshalJSON encodes the error as a message. Packets which cannot be encoded are formatted with %v.
+func (l DeadLetter) MarshalJSON() ([]byte, error) {
+	type letter struct {
+		Time    time.Time   `json:"time"`
+		Process string      `json:"process"`
+		Port    string      `json:"port"`
+		Packet  interface{} `json:"packet,omitempty"`
+		Error   string      `json:"error"`
+	}
+
+	res := letter{l.Time, l.Process, l.Port, l.Packet, l.Err.Error()}
+
+	if _, err := json.Marshal(l.Packet); err != nil {
+		res.Packet = fmt.Sprintf("%v", l.Packet)
+	}
+
+	return json.Marshal(res)
+}
+
+func (l DeadLetter) String() string {
+	if l.Process == "" {
+		return l.Err.Error()
+	}
+
+	if l.Packet != nil {
+		return fmt.Sprintf("%s.%s: %s, packet %v", l.Process, l.Port, l.Err, l.Packet)
+	}
+
+	return fmt.Sprintf("%s.%s: %s", l.Process, l.Port, l.Err)
+}
+
+// newDeadLetter interprets a value sent to an Err port.
+func newDeadLetter(proc, port string, v interface{}) DeadLetter {
+	l := DeadLetter{Time: time.Now(), Process: proc, Port: port}
+
+	switch e := v.(type) {
+	case *PacketError:
+		l.Packet, l.Err = e.Packet, e.Err
+	case PacketError:
+		l.Packet, l.Err = e.Packet, e.Err
+	case error:
+		l.Err = e
+	case string:
+		l.Err = errors.New(e)
+	default:
+		l.Packet, l.Err = v, errors.New("error reported")
+	}
+
+	if l.Err == nil {
+		l.Err = errors.New("error reported")
+	}
+
+	return l
+}
+
+// SetDeadLetterFunc makes the graph call fn for every dead letter. Calls are made
+// one at a time from a separate goroutine, so a slow function does not block
+// the processes. The graph must not be running.
+func (n *Graph) SetDeadLetterFunc(fn func(l DeadLetter)) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.deadLetterSink = fn
+	n.deadLetterClose = nil
+}
+
+// SetDeadLetterWriter makes the graph write dead letters to w as JSON lines.
+// The graph must not be running.
+func (n *Graph) SetDeadLetterWriter(w io.Writer) {
+	enc := json.NewEncoder(w)
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		if err := enc.Encode(l); err != nil {
+			log.Printf("goflow: dead letter %s: %s", l, err)
+		}
+	})
+}
+
+// setDeadLetterChan makes the graph send dead letters to a channel which is
+// closed when the graph finishes. It must be called with the graph locked.
+func (n *Graph) setDeadLetterChan(channel interface{}) error {
+	ch := reflect.ValueOf(channel)
+	if ch.Kind() != reflect.Chan || ch.Type().Elem() != reflect.TypeOf(DeadLetter{}) || ch.Type().ChanDir()&reflect.SendDir == 0 {
+		return fmt.Errorf("setGraphPort: %s must be a chan DeadLetter, got %T", DeadLetterPort, channel)
+	}
+
+	n.deadLetterSink = func(l DeadLetter) {
+		ch.Send(reflect.ValueOf(l))
+	}
+	n.deadLetterClose = ch.Close
+
+	return nil
+}
+
+// deliverDeadLetter returns the function dead letters of a running graph are passed to.
+// Without a sink of its own a subgraph uses the sink of its parent, a top level
+// graph logs them. It must be called with the graph locked.
+func (n *Graph) deliverDeadLetter() func(l DeadLetter) {
+	switch {
+	case n.deadLetterSink != nil:
+		return n.deadLetterSink
+	case n.deadLetterParent != nil:
+		return n.deadLetterParent
+	default:
+		return func(l DeadLetter) {
+			log.Printf("goflow: dead letter %s", l)
+		}
+	}
+}
+
+// routeDeadLetters attaches a dead letter channel to the Err outport of a process
+// if it is not connected, and lets a subgraph without a sink use this graph's one.
+// It must be called with the graph locked.
+func (n *Graph) routeDeadLetters(name string) {
+	queue := n.deadLetters
+
+	if sub, ok := n.procs[name].(*Graph); ok {
+		sub.lock.Lock()
+		sub.deadLetterParent = func(l DeadLetter) {
+			l.Process = name + "." + l.Process
+			queue.add(l)
+		}
+		sub.lock.Unlock()
+
+		return
+	}
+
+	port, ok := errPortOf(n.procs[name])
+	if !ok || !port.IsNil() {
+		return
+	}
+
+	ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, port.Type().Elem()), 0)
+	port.Set(ch)
+	n.incChanListenersCount(ch)
+
+	queue.drain(name, ErrPort, ch)
+}
+
+// errPortOf returns the Err outport of a component if it has one.
+func errPortOf(proc interface{}) (reflect.Value, bool) {
+	val := reflect.ValueOf(proc)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	port := val.Elem().FieldByName(ErrPort)
+
+	return port, isOutChan(port)
+}
+
+// isErrPort tells if a struct field is an Err outport.
+func isErrPort(field reflect.StructField, val reflect.Value) bool {
+	return field.Name == ErrPort && isOutChan(val)
+}
+
+// deadLetterQueue collects dead letters of a running graph and passes them to
+// a sink in order without blocking the senders.
+type deadLetterQueue struct {
+	lock     sync.Locker
+	ready    *sync.Cond
+	letters  []DeadLetter
+	closed   bool
+	drainers *sync.WaitGroup
+	done     chan struct{}
+}
+
+// newDeadLetterQueue starts a queue delivering dead letters to a sink.
+func newDeadLetterQueue(deliver func(l DeadLetter), closeSink func()) *deadLetterQueue {
+	lock := new(sync.Mutex)
+	q := &deadLetterQueue{
+		lock:     lock,
+		ready:    sync.NewCond(lock),
+		drainers: new(sync.WaitGroup),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(q.done)
+
+		for {
+			l, ok := q.next()
+			if !ok {
+				break
+			}
+
+			deliver(l)
+		}
+
+		if closeSink != nil {
+			closeSink()
+		}
+	}()
+
+	return q
+}
+
+// add appends a dead letter to the queue.
+func (q *deadLetterQueue) add(l DeadLetter) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.letters = append(q.letters, l)
+	q.ready.Signal()
+}
+
+// next waits for a dead letter. It returns false when the queue is closed and empty.
+func (q *deadLetterQueue) next() (DeadLetter, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for len(q.letters) == 0 && !q.closed {
+		q.ready.Wait()
+	}
+
+	if len(q.letters) == 0 {
+		return DeadLetter{}, false
+	}
+
+	l := q.letters[0]
+	q.letters = q.letters[1:]
+
+	return l, true
+}
+
+// drain receives errors from an Err channel until it is closed.
+func (q *deadLetterQueue) drain(proc, port string, ch reflect.Value) {
+	q.drainers.Add(1)
+
+	go func() {
+		defer q.drainers.Done()
+
+		for {
+			v, ok := ch.Recv()
+			if !ok {
+				return
+			}
+
+			q.add(newDeadLetter(proc, port, v.Interface()))
+		}
+	}()
+}
+
+// close waits until the Err channels are closed and all dead letters are delivered.
+func (q *deadLetterQueue) close() {
+	q.drainers.Wait()
+
+	q.lock.Lock()
+	q.closed = true
+	q.ready.Signal()
+	q.lock.Unlock()
+
+	<-q.done
+}
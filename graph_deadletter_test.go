@@ -0,0 +1,284 @@
+package goflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newRejecterGraph() (*Graph, error) {
+	n := NewGraph()
+
+	if err := n.Add("r", new(rejecter)); err != nil {
+		return nil, err
+	}
+
+	n.MapInPort("In", "r", "In")
+	n.MapOutPort("Out", "r", "Out")
+
+	return n, nil
+}
+
+// runInts sends numbers to a graph and returns its output.
+func runInts(n *Graph, input []int) ([]int, error) {
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		return nil, err
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		return nil, err
+	}
+
+	wait := Run(n)
+
+	go func() {
+		for _, i := range input {
+			in <- i
+		}
+
+		close(in)
+	}()
+
+	var res []int
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	return res, nil
+}
+
+func TestDeadLetterFunc(t *testing.T) {
+	n, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Validate(); err != nil {
+		t.Errorf("Err port is reported as not connected: %v", err)
+	}
+
+	var letters []DeadLetter
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		letters = append(letters, l)
+	})
+
+	out, err := runInts(n, []int{1, -2, 3, -4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(out) != 2 {
+		t.Errorf("Unexpected output %v", out)
+	}
+
+	// All letters are delivered when the graph finishes
+	if len(letters) != 2 {
+		t.Errorf("Unexpected dead letters %v", letters)
+		return
+	}
+
+	for i, want := range []int{-2, -4} {
+		l := letters[i]
+		if l.Process != "r" || l.Port != ErrPort || l.Packet != want || l.Err.Error() != "negative input" {
+			t.Errorf("Unexpected dead letter %v", l)
+		}
+	}
+}
+
+func TestDeadLetterChannel(t *testing.T) {
+	n, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	letters := make(chan DeadLetter)
+
+	if err := n.SetOutPort(DeadLetterPort, letters); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort(DeadLetterPort, make(chan int)); err == nil {
+		t.Error("Expected an error for a wrong channel type")
+	}
+
+	packets := make(chan interface{}, 3)
+
+	go func() {
+		for l := range letters {
+			packets <- l.Packet
+		}
+
+		close(packets)
+	}()
+
+	if _, err := runInts(n, []int{-1, 2, -3}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var got []interface{}
+	for p := range packets {
+		got = append(got, p)
+	}
+
+	if len(got) != 2 || got[0] != -1 || got[1] != -3 {
+		t.Errorf("Unexpected dead letters %v", got)
+	}
+}
+
+func TestDeadLetterWriter(t *testing.T) {
+	n, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	n.SetDeadLetterWriter(&buf)
+
+	if _, err := runInts(n, []int{-7}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var l struct {
+		Process string
+		Port    string
+		Packet  int
+		Error   string
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &l); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if l.Process != "r" || l.Port != "Err" || l.Packet != -7 || l.Error != "negative input" {
+		t.Errorf("Unexpected dead letter %s", buf.String())
+	}
+}
+
+func TestDeadLettersOfSubgraph(t *testing.T) {
+	sub, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n := NewGraph()
+
+	if err := n.Add("sub", sub); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "sub", "In")
+	n.MapOutPort("Out", "sub", "Out")
+
+	var letters []DeadLetter
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		letters = append(letters, l)
+	})
+
+	if _, err := runInts(n, []int{-1}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(letters) != 1 || letters[0].Process != "sub.r" {
+		t.Errorf("Unexpected dead letters %v", letters)
+	}
+}
+
+func TestConnectedErrPort(t *testing.T) {
+	n, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapOutPort("Err", "r", "Err")
+
+	errs := make(chan error, 1)
+
+	if err := n.SetOutPort("Err", errs); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		t.Errorf("Unexpected dead letter %v", l)
+	})
+
+	if _, err := runInts(n, []int{-1}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "packet -1") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestDeadLettersDoNotBlock(t *testing.T) {
+	n, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	release := make(chan struct{})
+	count := 0
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		<-release
+		count++
+	})
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	// The process keeps working while the sink is stuck
+	for i := 0; i < 100; i++ {
+		in <- -i - 1
+	}
+
+	in <- 1
+
+	if i := <-out; i != 1 {
+		t.Errorf("%d != 1", i)
+	}
+
+	close(in)
+	close(release)
+	<-wait
+
+	if count != 100 {
+		t.Errorf("%d != 100", count)
+	}
+}
@@ -0,0 +1,511 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Patch is a list of graph changes in the form of FBP graph protocol messages,
+// e.g. "addnode", "removeedge" or "addinitial". The messages are ordered so that
+// they can be applied one by one: removals go first, then additions.
+type Patch []Message
+
+// Diff returns a patch turning graph a into graph b. Processes are considered the
+// same if they have the same name, component and pool size. Processes which differ
+// are replaced, so the patch removes and adds them along with their connections,
+// IIPs and exports.
+func Diff(a, b *Graph) Patch {
+	if a == b {
+		return Patch{}
+	}
+
+	// Graphs are copied one at a time, so diffing them both ways concurrently cannot deadlock
+	from, to := a.snapshot(), b.snapshot()
+
+	removed := make(map[string]bool)
+	added := make(map[string]bool)
+
+	for name := range from.procs {
+		if !sameProc(from, to, name) {
+			removed[name] = true
+		}
+	}
+
+	for name := range to.procs {
+		if !sameProc(to, from, name) {
+			added[name] = true
+		}
+	}
+
+	p := Patch{}
+
+	for _, ip := range missingIIPs(from.iips, to.iips, removed) {
+		msg := removeInitial{Tgt: endpointOf(ip.addr)}
+		msg.Src.Data = ip.data
+		p = append(p, Message{"graph", "removeinitial", msg})
+	}
+
+	for _, c := range missingConns(from.connections, to.connections, removed) {
+		msg := removeEdge{Src: endpointOf(c.src), Tgt: endpointOf(c.tgt)}
+		p = append(p, Message{"graph", "removeedge", msg})
+	}
+
+	for _, name := range missingPorts(from.inPorts, to.inPorts, removed) {
+		p = append(p, Message{"graph", "removeinport", removePort{Public: name}})
+	}
+
+	for _, name := range missingPorts(from.outPorts, to.outPorts, removed) {
+		p = append(p, Message{"graph", "removeoutport", removePort{Public: name}})
+	}
+
+	for _, name := range sortedNames(removed) {
+		p = append(p, Message{"graph", "removenode", removeNode{ID: name}})
+	}
+
+	for _, name := range sortedNames(added) {
+		msg := addNode{ID: name, Component: to.components[name]}
+		if size := to.poolSizes[name]; size > 1 {
+			msg.Metadata = map[string]interface{}{"poolSize": size}
+		}
+
+		p = append(p, Message{"graph", "addnode", msg})
+	}
+
+	for _, c := range missingConns(to.connections, from.connections, added) {
+		msg := addEdge{Src: endpointOf(c.src), Tgt: endpointOf(c.tgt)}
+		if c.buffer > 0 || c.durable != nil {
+			msg.Metadata = make(map[string]interface{})
+		}
+
+		if c.buffer > 0 {
+			msg.Metadata["buffer"] = c.buffer
+		}
+
+		if c.durable != nil {
+			msg.Metadata["durable"] = c.durable.conf
+		}
+
+		p = append(p, Message{"graph", "addedge", msg})
+	}
+
+	for _, name := range missingPorts(to.inPorts, from.inPorts, added) {
+		addr := to.inPorts[name].addr
+		p = append(p, Message{"graph", "addinport", addPort{Public: name, Node: addr.proc, Port: addr.portName()}})
+	}
+
+	for _, name := range missingPorts(to.outPorts, from.outPorts, added) {
+		addr := to.outPorts[name].addr
+		p = append(p, Message{"graph", "addoutport", addPort{Public: name, Node: addr.proc, Port: addr.portName()}})
+	}
+
+	for _, ip := range missingIIPs(to.iips, from.iips, added) {
+		msg := addInitial{Tgt: endpointOf(ip.addr)}
+		msg.Src.Data = ip.data
+		p = append(p, Message{"graph", "addinitial", msg})
+	}
+
+	return p
+}
+
+// graphSnapshot is a copy of the graph structure compared by Diff.
+type graphSnapshot struct {
+	procs       map[string]interface{}
+	components  map[string]string
+	poolSizes   map[string]int
+	connections []connection
+	inPorts     map[string]port
+	outPorts    map[string]port
+	iips        []iip
+}
+
+// snapshot copies the graph structure under the graph lock.
+func (n *Graph) snapshot() graphSnapshot {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	s := graphSnapshot{
+		procs:       make(map[string]interface{}, len(n.procs)),
+		components:  make(map[string]string, len(n.components)),
+		poolSizes:   make(map[string]int, len(n.procs)),
+		connections: append([]connection(nil), n.connections...),
+		inPorts:     make(map[string]port, len(n.inPorts)),
+		outPorts:    make(map[string]port, len(n.outPorts)),
+		iips:        append([]iip(nil), n.iips...),
+	}
+
+	for name, proc := range n.procs {
+		s.procs[name] = proc
+		s.poolSizes[name] = len(n.pools[name]) + 1
+	}
+
+	for name, ref := range n.components {
+		s.components[name] = ref
+	}
+
+	for name, p := range n.inPorts {
+		s.inPorts[name] = p
+	}
+
+	for name, p := range n.outPorts {
+		s.outPorts[name] = p
+	}
+
+	return s
+}
+
+// sameProc tells if a process of graph a also exists in graph b.
+func sameProc(a, b graphSnapshot, name string) bool {
+	if _, exists := b.procs[name]; !exists {
+		return false
+	}
+
+	if a.components[name] != b.components[name] || a.poolSizes[name] != b.poolSizes[name] {
+		return false
+	}
+
+	// Instances added without a factory can only be compared by type
+	return a.components[name] != "" || reflect.TypeOf(a.procs[name]) == reflect.TypeOf(b.procs[name])
+}
+
+// missingConns returns connections from list which are not in other
+// or which belong to replaced processes.
+func missingConns(list, other []connection, replaced map[string]bool) []connection {
+	var res []connection
+
+	for _, c := range list {
+		found := !replaced[c.src.proc] && !replaced[c.tgt.proc]
+
+		if found {
+			found = false
+
+			for _, o := range other {
+				if c.src == o.src && c.tgt == o.tgt && c.buffer == o.buffer && sameQueue(c.durable, o.durable) {
+					found = true
+					break
+				}
+			}
+		}
+
+		if !found {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+// sameQueue tells if connections are both in-memory or durable with the same configuration.
+func sameQueue(a, b *durableEdge) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.conf == b.conf
+}
+
+// missingIIPs returns IIPs from list which are not in other or which belong to replaced processes.
+// IIPs are compared as multisets, so an IIP sent twice needs two matches.
+func missingIIPs(list, other []iip, replaced map[string]bool) []iip {
+	var res []iip
+
+	matched := make([]bool, len(other))
+
+	for _, ip := range list {
+		found := false
+
+		for i, o := range other {
+			if !matched[i] && !replaced[ip.addr.proc] && ip.addr == o.addr && reflect.DeepEqual(ip.data, o.data) {
+				matched[i] = true
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			res = append(res, ip)
+		}
+	}
+
+	return res
+}
+
+// missingPorts returns sorted names of exported ports from list which are
+// not in other or which belong to replaced processes.
+func missingPorts(list, other map[string]port, replaced map[string]bool) []string {
+	res := []string{}
+
+	for name, p := range list {
+		if o, exists := other[name]; !exists || o.addr != p.addr || replaced[p.addr.proc] {
+			res = append(res, name)
+		}
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+func sortedNames(set map[string]bool) []string {
+	res := make([]string, 0, len(set))
+	for name := range set {
+		res = append(res, name)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// endpointOf converts a port address into a protocol message endpoint.
+func endpointOf(addr address) edgeEnd {
+	e := edgeEnd{Node: addr.proc, Port: addr.portName()}
+
+	if addr.index > -1 {
+		i := addr.index
+		e.Port = addr.port
+		e.Index = &i
+	}
+
+	return e
+}
+
+// portName returns the port name including the array index if any.
+func (e edgeEnd) portName() string {
+	if e.Index == nil {
+		return e.Port
+	}
+
+	return e.Port + "[" + strconv.Itoa(*e.Index) + "]"
+}
+
+// Apply performs the changes of a patch on the graph. Processes are created with
+// the factory which has been used to create the other processes of the graph.
+// Apply stops at the first change which fails and returns its error.
+//
+// Processes added to a running graph start as soon as their ports are connected
+// or when started explicitly with Start.
+func (n *Graph) Apply(p Patch) error {
+	for _, msg := range p {
+		if err := n.applyMessage(msg); err != nil {
+			return fmt.Errorf("apply %s: %w", msg.Command, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMessage performs a single change.
+func (n *Graph) applyMessage(msg Message) error {
+	switch m := msg.Payload.(type) {
+	case addNode:
+		return n.applyAddNode(m)
+	case removeNode:
+		return n.Remove(m.ID)
+	case addEdge:
+		buffer, _ := metadataInt(m.Metadata, "buffer")
+
+		var opts []ConnectOption
+		if conf, err := metadataQueue(m.Metadata, "durable"); err != nil {
+			return err
+		} else if conf != nil {
+			opts = append(opts, Durable(*conf))
+		}
+
+		return n.ConnectBuf(m.Src.Node, m.Src.portName(), m.Tgt.Node, m.Tgt.portName(), buffer, opts...)
+	case removeEdge:
+		return n.Disconnect(m.Src.Node, m.Src.portName(), m.Tgt.Node, m.Tgt.portName())
+	case addInitial:
+		data, err := n.patchIIPData(m.Tgt, m.Src.Data)
+		if err != nil {
+			return err
+		}
+
+		return n.AddIIP(m.Tgt.Node, m.Tgt.portName(), data)
+	case removeInitial:
+		if raw, ok := m.Src.Data.(json.RawMessage); m.Src.Data == nil || ok && (len(raw) == 0 || string(raw) == "null") {
+			return n.RemoveIIP(m.Tgt.Node, m.Tgt.portName())
+		}
+
+		return n.applyRemoveInitial(m)
+	case addPort:
+		if msg.Command == "addoutport" {
+			n.MapOutPort(m.Public, m.Node, m.Port)
+		} else {
+			n.MapInPort(m.Public, m.Node, m.Port)
+		}
+
+		return nil
+	case removePort:
+		if msg.Command == "removeoutport" {
+			return n.UnmapOutPort(m.Public)
+		}
+
+		return n.UnmapInPort(m.Public)
+	default:
+		return fmt.Errorf("unsupported payload %T", msg.Payload)
+	}
+}
+
+// patchIIPData converts raw IIP data of a decoded patch to the type of the target port.
+func (n *Graph) patchIIPData(tgt edgeEnd, data interface{}) (interface{}, error) {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		return data, nil
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.iipData(tgt.Node, tgt.portName(), raw, nil)
+}
+
+// applyRemoveInitial removes the IIP carrying the data of the message, so other
+// IIPs sent to the same port are kept.
+func (n *Graph) applyRemoveInitial(m removeInitial) error {
+	data, err := n.patchIIPData(m.Tgt, m.Src.Data)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	addr := parseAddress(m.Tgt.Node, m.Tgt.portName())
+	if !n.removeIIP(addr, func(d interface{}) bool { return reflect.DeepEqual(d, data) }) {
+		return fmt.Errorf("could not find IIP %v for '%s'", data, addr)
+	}
+
+	return nil
+}
+
+// applyAddNode creates a process using the graph factory.
+func (n *Graph) applyAddNode(m addNode) error {
+	n.lock.Lock()
+	f := n.factory
+	n.lock.Unlock()
+
+	if f == nil || m.Component == "" {
+		return fmt.Errorf("process '%s' cannot be created without a factory and a component name", m.ID)
+	}
+
+	if size, _ := metadataInt(m.Metadata, "poolSize"); size > 1 {
+		return n.AddNewPool(m.ID, m.Component, size, f)
+	}
+
+	return n.AddNew(m.ID, m.Component, f)
+}
+
+// metadataInt returns an integer metadata value which may have been decoded from JSON as a float.
+func metadataInt(metadata map[string]interface{}, key string) (int, bool) {
+	switch v := metadata[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// metadataQueue reads a queue configuration from edge metadata, which is either
+// a QueueConfig or its decoded JSON. It returns nil if the key is not set.
+func metadataQueue(metadata map[string]interface{}, key string) (*QueueConfig, error) {
+	v, ok := metadata[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("metadata '%s': %w", key, err)
+	}
+
+	conf := new(QueueConfig)
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("metadata '%s': %w", key, err)
+	}
+
+	return conf, nil
+}
+
+// UnmarshalJSON decodes graph protocol messages into their payload types.
+// IIP data is kept raw and converted to the port type when the patch is applied.
+func (p *Patch) UnmarshalJSON(data []byte) error {
+	var list []struct {
+		Protocol string
+		Command  string
+		Payload  json.RawMessage
+	}
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	res := make(Patch, 0, len(list))
+
+	for _, item := range list {
+		var (
+			payload interface{}
+			err     error
+		)
+
+		switch item.Command {
+		case "addnode":
+			var m addNode
+			err = json.Unmarshal(item.Payload, &m)
+			payload = m
+		case "removenode":
+			var m removeNode
+			err = json.Unmarshal(item.Payload, &m)
+			payload = m
+		case "addedge":
+			var m addEdge
+			err = json.Unmarshal(item.Payload, &m)
+			payload = m
+		case "removeedge":
+			var m removeEdge
+			err = json.Unmarshal(item.Payload, &m)
+			payload = m
+		case "addinitial":
+			var m addInitial
+
+			raw := new(json.RawMessage)
+			m.Src.Data = raw
+			err = json.Unmarshal(item.Payload, &m)
+			m.Src.Data = *raw
+			payload = m
+		case "removeinitial":
+			var m removeInitial
+
+			raw := new(json.RawMessage)
+			m.Src.Data = raw
+			err = json.Unmarshal(item.Payload, &m)
+			m.Src.Data = *raw
+			payload = m
+		case "addinport", "addoutport":
+			var m addPort
+			err = json.Unmarshal(item.Payload, &m)
+			payload = m
+		case "removeinport", "removeoutport":
+			var m removePort
+			err = json.Unmarshal(item.Payload, &m)
+			payload = m
+		default:
+			err = fmt.Errorf("unsupported command '%s'", item.Command)
+		}
+
+		if err != nil {
+			return fmt.Errorf("patch: %w", err)
+		}
+
+		res = append(res, Message{item.Protocol, item.Command, payload})
+	}
+
+	*p = res
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package goflow
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectCommands(t *testing.T, p Patch, commands ...string) {
+	t.Helper()
+
+	actual := make([]string, len(p))
+	for i, msg := range p {
+		actual[i] = msg.Command
+	}
+
+	if strings.Join(actual, ",") != strings.Join(commands, ",") {
+		t.Errorf("%v != %v", actual, commands)
+	}
+}
+
+func TestDiffAndApply(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	a, err := ParseFBP([]byte("INPORT=e.IN:IN\nOUTPORT=d.OUT:OUT\ne(echo) OUT -> IN d(doubler)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := ParseFBP([]byte("INPORT=e.IN:IN\nOUTPORT=q.OUT:OUT\ne(echo) OUT -> IN d(doubler) OUT -> IN q(doubler)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	p := Diff(a, b)
+	expectCommands(t, p, "removeoutport", "addnode", "addedge", "addoutport")
+
+	if err := a.Apply(p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectCommands(t, Diff(a, b))
+
+	res := runIntGraph(a, []int{1, 2, 3})
+	expectInts(t, res, []int{4, 8, 12})
+
+	// Undo
+	c, err := ParseFBP([]byte("INPORT=e.IN:IN\nOUTPORT=d.OUT:OUT\ne(echo) OUT -> IN d(doubler)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	undo := Diff(b, c)
+	expectCommands(t, undo, "removeedge", "removeoutport", "removenode", "addoutport")
+
+	if err := b.Apply(undo); err != nil {
+		t.Error(err)
+		return
+	}
+
+	res = runIntGraph(b, []int{1, 2, 3})
+	expectInts(t, res, []int{2, 4, 6})
+}
+
+func TestDiffReplacedProcess(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	a, err := ParseFBP([]byte("OUTPORT=r.WORDS:OUT\n'hi' -> WORD r(repeater)\n'2' -> TIMES r\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := ParseFBP([]byte("OUTPORT=r.WORDS:OUT\n'hi' -> WORD r(repeater:poolSize=2)\n'3' -> TIMES r\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	p := Diff(a, b)
+	expectCommands(t, p, "removeinitial", "removeinitial", "removeoutport", "removenode",
+		"addnode", "addoutport", "addinitial", "addinitial")
+
+	if err := a.Apply(p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if size := a.PoolSize("r"); size != 2 {
+		t.Errorf("%d != 2", size)
+	}
+
+	expectCommands(t, Diff(a, b))
+}
+
+func TestPatchJSON(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	err := f.Register("irouter", func() (interface{}, error) {
+		return new(irouter), nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	a := NewGraph()
+
+	b, err := ParseFBP([]byte("'2' -> IN e(echo) OUT -> IN[0] x(irouter)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	js, err := json.Marshal(Diff(a, b))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, s := range []string{
+		`{"protocol":"graph","command":"addnode","payload":{"id":"e","component":"echo","graph":""}}`,
+		`"src":{"node":"e","port":"Out"},"tgt":{"node":"x","port":"In","index":0}`,
+		`{"src":{"data":2},"tgt":{"node":"e","port":"In"},"graph":""}`,
+	} {
+		if !strings.Contains(string(js), s) {
+			t.Errorf("%s does not contain %s", js, s)
+		}
+	}
+
+	var p Patch
+	if err := json.Unmarshal(js, &p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Graphs without processes need to know the factory
+	a.factory = f
+
+	if err := a.Apply(p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectCommands(t, Diff(a, b))
+}
+
+func TestDiffRemoveInitialData(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	a, err := ParseFBP([]byte("'1' -> IN e(echo)\n'2' -> IN e\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := ParseFBP([]byte("'1' -> IN e(echo)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	js, err := json.Marshal(Diff(a, b))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var p Patch
+	if err := json.Unmarshal(js, &p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectCommands(t, p, "removeinitial")
+
+	// The second IIP of the port is removed, not the first one
+	if err := a.Apply(p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(a.iips) != 1 || a.iips[0].data != 1 {
+		t.Errorf("Unexpected IIPs %v", a.iips)
+	}
+
+	expectCommands(t, Diff(a, b))
+}
+
+func TestDiffDuplicateIIPs(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	a, err := ParseFBP([]byte("'1' -> IN e(echo)\n'1' -> IN e\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := ParseFBP([]byte("'1' -> IN e(echo)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectCommands(t, Diff(b, a), "addinitial")
+
+	p := Diff(a, b)
+	expectCommands(t, p, "removeinitial")
+
+	if err := a.Apply(p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(a.iips) != 1 {
+		t.Errorf("Unexpected IIPs %v", a.iips)
+	}
+
+	expectCommands(t, Diff(a, b))
+}
+
+func TestDiffConcurrent(t *testing.T) {
+	a, err := newDoubleEcho()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b := NewGraph()
+	done := make(chan struct{})
+
+	for _, g := range [][2]*Graph{{a, b}, {b, a}} {
+		g := g
+
+		go func() {
+			for i := 0; i < 1000; i++ {
+				Diff(g[0], g[1])
+			}
+
+			done <- struct{}{}
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("Diff is deadlocked")
+			return
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	n, err := newDoubleEcho()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Disconnect("e1", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(n.connections) != 0 {
+		t.Errorf("%d connections left", len(n.connections))
+	}
+
+	if e := n.procs["e1"].(*echo); e.Out != nil {
+		t.Error("Sender port is still attached")
+	}
+
+	if e := n.procs["e2"].(*echo); e.In != nil {
+		t.Error("Receiver port is still attached")
+	}
+
+	if err := n.Disconnect("e1", "Out", "e2", "In"); err == nil {
+		t.Error("Expected an error")
+	}
+}
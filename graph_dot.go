@@ -0,0 +1,75 @@
+package goflow
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDot renders the graph topology in Graphviz DOT format. Processes are
+// labeled with their components, connections with their ports. IIPs and
+// exported ports are drawn as separate nodes.
+func (n *Graph) WriteDot(w io.Writer) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	ew := &errWriter{w: w}
+
+	ew.printf("digraph {\n\trankdir=LR;\n\tnode [shape=box];\n")
+
+	for _, name := range sortedProcNames(n.procs) {
+		label := name
+		if c := n.components[name]; c != "" {
+			label += "\n(" + c + ")"
+		}
+
+		if size := len(n.pools[name]) + 1; size > 1 {
+			label += "\nx" + strconv.Itoa(size)
+		}
+
+		ew.printf("\t%s [label=%s];\n", strconv.Quote(name), strconv.Quote(label))
+	}
+
+	for _, c := range n.connections {
+		ew.printf("\t%s -> %s [label=%s];\n", strconv.Quote(c.src.proc), strconv.Quote(c.tgt.proc),
+			strconv.Quote(c.src.portName()+" -> "+c.tgt.portName()))
+	}
+
+	for i, ip := range n.iips {
+		id := strconv.Quote("iip" + strconv.Itoa(i))
+		ew.printf("\t%s [shape=plaintext, label=%s];\n", id, strconv.Quote(fmt.Sprintf("'%v'", ip.data)))
+		ew.printf("\t%s -> %s [label=%s, style=dashed];\n", id, strconv.Quote(ip.addr.proc), strconv.Quote(ip.addr.portName()))
+	}
+
+	for _, name := range sortedPortNames(n.inPorts) {
+		p := n.inPorts[name]
+		id := strconv.Quote("in:" + name)
+		ew.printf("\t%s [shape=circle, label=%s];\n", id, strconv.Quote(name))
+		ew.printf("\t%s -> %s [label=%s];\n", id, strconv.Quote(p.addr.proc), strconv.Quote(p.addr.portName()))
+	}
+
+	for _, name := range sortedPortNames(n.outPorts) {
+		p := n.outPorts[name]
+		id := strconv.Quote("out:" + name)
+		ew.printf("\t%s [shape=circle, label=%s];\n", id, strconv.Quote(name))
+		ew.printf("\t%s -> %s [label=%s];\n", strconv.Quote(p.addr.proc), id, strconv.Quote(p.addr.portName()))
+	}
+
+	ew.printf("}\n")
+
+	return ew.err
+}
+
+// errWriter remembers the first write error, so a sequence of writes can be checked once.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,41 @@
+package goflow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDot(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := ParseFBP([]byte("OUTPORT=e.OUT:OUT\n'2' -> IN d(doubler) OUT -> IN e(echo:poolSize=2)\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := n.WriteDot(&buf); err != nil {
+		t.Error(err)
+		return
+	}
+
+	dot := buf.String()
+
+	for _, s := range []string{
+		"digraph {",
+		`"e" [label="e\n(echo)\nx2"];`,
+		`"d" -> "e" [label="Out -> In"];`,
+		`[shape=plaintext, label="'2'"];`,
+		`"e" -> "out:Out" [label="Out"];`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("%s does not contain %s", dot, s)
+		}
+	}
+}
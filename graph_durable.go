@@ -0,0 +1,606 @@
+package goflow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize is the size of durable queue log segments if it is not configured.
+const DefaultSegmentSize = 64 << 20
+
+// DefaultCommitEvery is the number of packets taken from a durable queue before
+// its offset is saved if it is not configured.
+const DefaultCommitEvery = 100
+
+// QueueConfig sets up a durable connection which keeps packets in a local
+// append-only log instead of memory.
+type QueueConfig struct {
+	Dir         string `json:"dir"`                   // Directory of the log, one per connection
+	SegmentSize int64  `json:"segmentSize,omitempty"` // Size of a log file before a new one is started, DefaultSegmentSize if 0
+	Sync        bool   `json:"sync,omitempty"`        // Tells to flush every packet to the disk
+	CommitEvery int    `json:"commitEvery,omitempty"` // Packets taken before the offset is saved, DefaultCommitEvery if 0
+}
+
+// ConnectOption changes a connection made with ConnectBuf.
+type ConnectOption func(o *connectOptions)
+
+type connectOptions struct {
+	queue *QueueConfig
+}
+
+// Durable makes a connection durable. Packets sent over it are appended to a log
+// in the configured directory and removed after the receiver takes them, so the
+// packets which have not been received survive a crash and are delivered when the
+// graph is started again. The receiver keeps seeing a normal channel and the size
+// of the queue is only limited by the disk.
+//
+// Packets are stored as JSON and decoded into the type of the sender port, so they
+// should be types which survive encoding. The log is opened when the graph starts
+// and closed when the connection finishes. Packets taken by the receiver are removed
+// from the log in batches of CommitEvery, so up to that many packets are delivered
+// again if the graph crashes, as well as a packet the receiver has not processed yet.
+// A durable connection has its own sender port, it cannot be shared with other
+// connections. Checkpoints and generated code do not support durable connections.
+func Durable(conf QueueConfig) ConnectOption {
+	return func(o *connectOptions) {
+		o.queue = &conf
+	}
+}
+
+// durableEdge passes packets of a connection through a disk queue.
+type durableEdge struct {
+	conf   QueueConfig
+	from   reflect.Value // Channel of the sender
+	to     reflect.Value // Channel of the receiver
+	origin string        // Sender port
+	target address       // Receiver port
+	stop   chan struct{} // Closed when the connection is removed
+}
+
+// connectDurable connects ports through a disk queue. Neither process may be running.
+// It must be called with the graph locked.
+func (n *Graph) connectDurable(sendPort, recvPort reflect.Value, sendAddr, recvAddr address, bufferSize int, conf QueueConfig) error {
+	sendType, recvType := packetType(sendPort), packetType(recvPort)
+	if sendType == nil || recvType == nil {
+		return fmt.Errorf("connect '%s -> %s': not a channel", sendAddr, recvAddr)
+	}
+
+	if sendType != recvType && !needsAdapter(sendType, recvType) {
+		return fmt.Errorf("connect '%s -> %s': channel of %s cannot be attached to a port of %s", sendAddr, recvAddr, sendType, recvType)
+	}
+
+	if n.hasConnection(func(c connection) bool { return c.src == sendAddr }) {
+		return fmt.Errorf("connect '%s': port is already connected and cannot be shared by a durable connection", sendAddr)
+	}
+
+	from, err := attachPort(sendPort, sendAddr, reflect.SendDir, reflect.Value{}, bufferSize)
+	if err != nil {
+		return fmt.Errorf("connect '%s': %w", sendAddr, err)
+	}
+
+	to, err := attachPort(recvPort, recvAddr, reflect.RecvDir, n.findExistingChan(recvAddr, reflect.RecvDir), bufferSize)
+	if err != nil {
+		return fmt.Errorf("connect '%s': %w", recvAddr, err)
+	}
+
+	if conf.Dir == "" {
+		return fmt.Errorf("connect '%s -> %s': %w", sendAddr, recvAddr, errNoQueueDir)
+	}
+
+	n.incChanListenersCount(from)
+	// The queue is one more sender to the receiver
+	n.incChanListenersCount(to)
+
+	edge := &durableEdge{
+		conf:   conf,
+		from:   from,
+		to:     to,
+		origin: sendAddr.proc + "." + endpointOf(sendAddr).portName(),
+		target: recvAddr,
+		stop:   make(chan struct{}),
+	}
+
+	n.connections = append(n.connections, connection{
+		src:     sendAddr,
+		tgt:     recvAddr,
+		channel: to,
+		buffer:  bufferSize,
+		durable: edge,
+	})
+
+	if n.running {
+		go edge.run(n, n.deadLetters)
+		n.startIfReady(sendAddr.proc)
+		n.startIfReady(recvAddr.proc)
+	}
+
+	return nil
+}
+
+// startDurableEdges launches the queues of durable connections.
+// It must be called with the graph locked.
+func (n *Graph) startDurableEdges() {
+	for _, c := range n.connections {
+		if c.durable != nil {
+			go c.durable.run(n, n.deadLetters)
+		}
+	}
+}
+
+// run opens the queue, writes packets of the sender to it and sends them from
+// the queue to the receiver until the sender is closed and the queue is empty or
+// the connection is removed. Packets which cannot be stored or decoded are sent
+// to the dead letters of the receiver, as are all packets if the queue cannot be opened.
+func (e *durableEdge) run(n *Graph, letters *deadLetterQueue) {
+	fail := func(packet interface{}, err error) {
+		letters.add(DeadLetter{
+			Time:    time.Now(),
+			Process: e.target.proc,
+			Port:    endpointOf(e.target).portName(),
+			Packet:  packet,
+			Err:     err,
+		})
+	}
+
+	queue, err := openDiskQueue(e.conf)
+	if err != nil {
+		fail(nil, err)
+
+		for {
+			v, ok := e.recv()
+			if !ok {
+				break
+			}
+
+			fail(v.Interface(), err)
+		}
+
+		e.finish(n)
+
+		return
+	}
+
+	written := make(chan struct{})
+
+	go func() {
+		for {
+			v, ok := e.recv()
+			if !ok {
+				break
+			}
+
+			data, err := json.Marshal(v.Interface())
+			if err == nil {
+				err = queue.push(data)
+			}
+
+			if err != nil {
+				fail(v.Interface(), fmt.Errorf("durable queue: %w", err))
+			}
+		}
+
+		queue.closeWriter()
+		close(written)
+	}()
+
+	sendType, recvType := e.from.Type().Elem(), e.to.Type().Elem()
+
+	for {
+		data, ok, err := queue.pop()
+		if err != nil {
+			fail(nil, fmt.Errorf("durable queue: %w", err))
+			break
+		}
+
+		if !ok {
+			break
+		}
+
+		v, err := replayPacket(json.RawMessage(data), sendType)
+		if err == nil && sendType != recvType {
+			v, err = adaptPacket(v, recvType, e.origin)
+		}
+
+		if err != nil {
+			fail(string(data), fmt.Errorf("durable queue: %w", err))
+		} else if !e.send(v) {
+			break
+		}
+
+		if err := queue.commit(); err != nil {
+			fail(nil, fmt.Errorf("durable queue: %w", err))
+		}
+	}
+
+	<-written
+
+	if err := queue.close(); err != nil {
+		fail(nil, fmt.Errorf("durable queue: %w", err))
+	}
+
+	e.finish(n)
+}
+
+// recv receives a packet from the sender. It returns false when the sender is
+// closed or the connection is removed.
+func (e *durableEdge) recv() (reflect.Value, bool) {
+	chosen, v, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: e.from},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(e.stop)},
+	})
+
+	return v, chosen == 0 && ok
+}
+
+// send sends a packet to the receiver. It returns false if the connection is removed.
+func (e *durableEdge) send(v reflect.Value) bool {
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: e.to, Send: v},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(e.stop)},
+	})
+
+	return chosen == 0
+}
+
+// finish closes the receiver unless the connection has been removed, which has
+// already released it, or other senders still use it.
+func (e *durableEdge) finish(n *Graph) {
+	select {
+	case <-e.stop:
+		return
+	default:
+	}
+
+	if n.decChanListenersCount(e.to) {
+		e.to.Close()
+	}
+}
+
+const (
+	segmentExt = ".log"
+	offsetFile = "offset"
+)
+
+// diskQueue is an append-only log of JSON lines split into segment files named
+// by the offset of their first record. The offset of the first record which has
+// not been taken yet is kept in a file, segments before it are removed.
+type diskQueue struct {
+	conf     QueueConfig
+	lock     sync.Mutex
+	cond     *sync.Cond
+	segments []int64 // First offsets of segments
+	head     int64   // Offset of the next record to write
+	next     int64   // Offset of the next record to read
+	w        *os.File
+	wsize    int64
+	r        *os.File
+	rbuf     *bufio.Reader
+	rseg     int   // Index of the segment being read
+	taken    int64 // Offset of the next record which has not been taken
+	pending  int   // Records taken since the offset was saved
+	closed   bool
+}
+
+// errNoQueueDir is returned for a queue without a directory.
+var errNoQueueDir = errors.New("durable queue: directory is not set")
+
+// openDiskQueue opens a queue in a directory, creating it if needed.
+func openDiskQueue(conf QueueConfig) (*diskQueue, error) {
+	if conf.Dir == "" {
+		return nil, errNoQueueDir
+	}
+
+	if conf.SegmentSize <= 0 {
+		conf.SegmentSize = DefaultSegmentSize
+	}
+
+	if conf.CommitEvery <= 0 {
+		conf.CommitEvery = DefaultCommitEvery
+	}
+
+	if err := os.MkdirAll(conf.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("durable queue %s: %w", conf.Dir, err)
+	}
+
+	q := &diskQueue{conf: conf}
+	q.cond = sync.NewCond(&q.lock)
+
+	if err := q.load(); err != nil {
+		q.close()
+		return nil, fmt.Errorf("durable queue %s: %w", conf.Dir, err)
+	}
+
+	return q, nil
+}
+
+// load finds the segments and positions the reader at the saved offset.
+func (q *diskQueue) load() error {
+	names, err := filepath.Glob(filepath.Join(q.conf.Dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		start, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), segmentExt), 10, 64)
+		if err == nil {
+			q.segments = append(q.segments, start)
+		}
+	}
+
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if len(q.segments) == 0 {
+		q.segments = []int64{0}
+	}
+
+	last := q.segments[len(q.segments)-1]
+
+	count, size, err := q.repair(last)
+	if err != nil {
+		return err
+	}
+
+	q.head = last + count
+	q.wsize = size
+
+	if q.w, err = os.OpenFile(q.segmentPath(last), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return err
+	}
+
+	offset := q.segments[0]
+
+	if data, err := ioutil.ReadFile(filepath.Join(q.conf.Dir, offsetFile)); err == nil {
+		saved, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid offset: %w", err)
+		}
+
+		if saved > offset {
+			offset = saved
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if offset > q.head {
+		return fmt.Errorf("offset %d is beyond the end of the log %d", offset, q.head)
+	}
+
+	q.rseg = sort.Search(len(q.segments), func(i int) bool { return q.segments[i] > offset }) - 1
+	if err := q.openReader(); err != nil {
+		return err
+	}
+
+	for q.next < offset {
+		if _, err := q.read(); err != nil {
+			return err
+		}
+	}
+
+	q.taken = offset
+
+	return nil
+}
+
+// repair counts the records of a segment and cuts off a partially written last record.
+func (q *diskQueue) repair(start int64) (count, size int64, err error) {
+	f, err := os.OpenFile(q.segmentPath(start), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return count, size, f.Truncate(size)
+			}
+
+			return count, size, nil
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		count++
+		size += int64(len(line))
+	}
+}
+
+func (q *diskQueue) segmentPath(start int64) string {
+	return filepath.Join(q.conf.Dir, fmt.Sprintf("%020d%s", start, segmentExt))
+}
+
+// openReader opens the segment at rseg for reading from its beginning.
+func (q *diskQueue) openReader() error {
+	if q.r != nil {
+		q.r.Close()
+	}
+
+	f, err := os.Open(q.segmentPath(q.segments[q.rseg]))
+	if err != nil {
+		return err
+	}
+
+	q.r = f
+	q.rbuf = bufio.NewReader(f)
+	q.next = q.segments[q.rseg]
+
+	return nil
+}
+
+// read reads the next record, moving to the next segment at the end of one.
+// It must be called with the queue locked and a record available.
+func (q *diskQueue) read() ([]byte, error) {
+	if err := q.advance(); err != nil {
+		return nil, err
+	}
+
+	line, err := q.rbuf.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", q.next, err)
+	}
+
+	q.next++
+
+	return bytes.TrimSuffix(line, []byte("\n")), nil
+}
+
+// advance moves the reader to the segment of the next record. It must be called with the queue locked.
+func (q *diskQueue) advance() error {
+	for q.rseg+1 < len(q.segments) && q.next >= q.segments[q.rseg+1] {
+		q.rseg++
+		if err := q.openReader(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// push appends a record, starting a new segment if the current one is full.
+func (q *diskQueue) push(data []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	n, err := q.w.Write(append(data, '\n'))
+	q.wsize += int64(n)
+
+	if err == nil && q.conf.Sync {
+		err = q.w.Sync()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	q.head++
+	q.cond.Broadcast()
+
+	if q.wsize < q.conf.SegmentSize {
+		return nil
+	}
+
+	if err := q.w.Close(); err != nil {
+		return err
+	}
+
+	if q.w, err = os.OpenFile(q.segmentPath(q.head), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return err
+	}
+
+	q.segments = append(q.segments, q.head)
+	q.wsize = 0
+
+	return nil
+}
+
+// pop waits for the next record. It returns false when the writer is closed
+// and all records have been read.
+func (q *diskQueue) pop() ([]byte, bool, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.next >= q.head && !q.closed {
+		q.cond.Wait()
+	}
+
+	if q.next >= q.head {
+		return nil, false, nil
+	}
+
+	data, err := q.read()
+
+	return data, err == nil, err
+}
+
+// commit marks the records read so far as taken. Their offset is saved every
+// CommitEvery records and when the queue is closed.
+func (q *diskQueue) commit() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.taken = q.next
+	q.pending++
+
+	if q.pending < q.conf.CommitEvery {
+		return nil
+	}
+
+	return q.saveOffset()
+}
+
+// saveOffset saves the offset of the records taken so far and removes the segments
+// before it. It must be called with the queue locked.
+func (q *diskQueue) saveOffset() error {
+	q.pending = 0
+
+	tmp := filepath.Join(q.conf.Dir, offsetFile+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(q.taken, 10)), 0o644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(q.conf.Dir, offsetFile)); err != nil {
+		return err
+	}
+
+	if err := q.advance(); err != nil {
+		return err
+	}
+
+	for q.rseg > 0 && q.segments[1] <= q.taken {
+		if err := os.Remove(q.segmentPath(q.segments[0])); err != nil {
+			return err
+		}
+
+		q.segments = q.segments[1:]
+		q.rseg--
+	}
+
+	return nil
+}
+
+// closeWriter tells the reader that no more records will be written.
+func (q *diskQueue) closeWriter() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// close saves the offset of the records taken and closes the files of the queue.
+func (q *diskQueue) close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var err error
+	if q.pending > 0 {
+		err = q.saveOffset()
+	}
+
+	for _, f := range []*os.File{q.w, q.r} {
+		if f != nil {
+			f.Close()
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,321 @@
+package goflow
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newDurableGraph creates a graph "d" doubler -> "e" echo with a durable connection between them.
+func newDurableGraph(conf QueueConfig) (*Graph, error) {
+	n := NewGraph()
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Add("e", new(echo)); err != nil {
+		return nil, err
+	}
+
+	if err := n.ConnectBuf("d", "Out", "e", "In", 0, Durable(conf)); err != nil {
+		return nil, err
+	}
+
+	n.MapInPort("In", "d", "In")
+	n.MapOutPort("Out", "e", "Out")
+
+	return n, nil
+}
+
+func TestDurableConnection(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+
+	n, err := newDurableGraph(QueueConfig{Dir: dir, SegmentSize: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The queue is only opened when the graph runs
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Queue directory exists before the graph runs: %v", err)
+	}
+
+	out, err := runInts(n, []int{1, 2, 3, 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(out, []int{2, 4, 6, 8}) {
+		t.Errorf("%v != [2 4 6 8]", out)
+	}
+
+	// Consumed segments are removed
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(segments) != 1 {
+		t.Errorf("Unexpected segments %v", segments)
+	}
+
+	offset, err := ioutil.ReadFile(filepath.Join(dir, offsetFile))
+	if err != nil || string(offset) != "4" {
+		t.Errorf("Unexpected offset '%s': %v", offset, err)
+	}
+}
+
+func TestDurableResume(t *testing.T) {
+	dir := t.TempDir()
+
+	// Packets left in the queue by a previous run which crashed
+	q, err := openDiskQueue(QueueConfig{Dir: dir, SegmentSize: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, data := range []string{"10", "20"} {
+		if err := q.push([]byte(data)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	q.close()
+
+	n, err := newDurableGraph(QueueConfig{Dir: dir, SegmentSize: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out, err := runInts(n, []int{1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(out, []int{10, 20, 2}) {
+		t.Errorf("%v != [10 20 2]", out)
+	}
+}
+
+func TestDurableErrors(t *testing.T) {
+	n, err := newDurableGraph(QueueConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Add("e2", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Durable connections have their own sender port
+	if err := n.Connect("d", "Out", "e2", "In"); err == nil {
+		t.Error("Expected an error for sharing a durable sender port")
+	}
+
+	if err := n.ConnectBuf("d", "Out", "e2", "In", 0, Durable(QueueConfig{Dir: t.TempDir()})); err == nil {
+		t.Error("Expected an error for a second durable connection of a port")
+	}
+
+	if err := n.ConnectBuf("e", "Out", "e2", "In", 0, Durable(QueueConfig{})); err == nil {
+		t.Error("Expected an error for a queue without a directory")
+	}
+}
+
+func TestDurableOpenFailure(t *testing.T) {
+	// A file where the queue directory should be
+	dir := filepath.Join(t.TempDir(), "queue")
+	if err := ioutil.WriteFile(dir, nil, 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := newDurableGraph(QueueConfig{Dir: dir})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var letters []DeadLetter
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		letters = append(letters, l)
+	})
+
+	out, err := runInts(n, []int{1, 2})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The failure and the packets go to the dead letters of the receiver
+	if len(out) != 0 || len(letters) != 3 || letters[0].Process != "e" || letters[2].Packet != 4 {
+		t.Errorf("Unexpected output %v and dead letters %v", out, letters)
+	}
+}
+
+func TestDiskQueue(t *testing.T) {
+	conf := QueueConfig{Dir: t.TempDir(), SegmentSize: 10}
+
+	q, err := openDiskQueue(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := q.push([]byte(fmt.Sprintf("%d", i))); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if data, ok, err := q.pop(); err != nil || !ok || string(data) != fmt.Sprint(i) {
+			t.Errorf("Unexpected record '%s' %v: %v", data, ok, err)
+			return
+		}
+	}
+
+	if err := q.commit(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Offsets are saved in batches
+	if _, err := os.Stat(filepath.Join(conf.Dir, offsetFile)); !os.IsNotExist(err) {
+		t.Errorf("Offset is saved on the first commit: %v", err)
+	}
+
+	// A record which was read but not committed is read again
+	if _, _, err := q.pop(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	q.close()
+
+	// A partially written record is cut off
+	last := filepath.Join(conf.Dir, fmt.Sprintf("%020d%s", 10, segmentExt))
+	if err := ioutil.WriteFile(last, []byte("{\"a\""), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if q, err = openDiskQueue(conf); err != nil {
+		t.Error(err)
+		return
+	}
+
+	q.closeWriter()
+
+	var res []string
+
+	for {
+		data, ok, err := q.pop()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !ok {
+			break
+		}
+
+		res = append(res, string(data))
+	}
+
+	q.close()
+
+	if strings.Join(res, " ") != "3 4 5 6 7 8 9" {
+		t.Errorf("Unexpected records %v", res)
+	}
+}
+
+var durableGraphJSON = `{
+	"processes": {
+		"d": {
+			"component": "doubler"
+		},
+		"e": {
+			"component": "echo"
+		}
+	},
+	"connections": [
+		{
+			"src": {
+				"process": "d",
+				"port": "Out"
+			},
+			"tgt": {
+				"process": "e",
+				"port": "In"
+			},
+			"metadata": {
+				"durable": {
+					"dir": "DIR"
+				}
+			}
+		}
+	],
+	"inports": {
+		"In": {
+			"process": "d",
+			"port": "In"
+		}
+	},
+	"outports": {
+		"Out": {
+			"process": "e",
+			"port": "Out"
+		}
+	}
+}`
+
+func TestLoadDurableGraph(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	dir := t.TempDir()
+
+	n, err := ParseJSON([]byte(strings.Replace(durableGraphJSON, "DIR", filepath.ToSlash(dir), 1)), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(n.connections) != 1 || n.connections[0].durable == nil || n.connections[0].durable.conf.Dir != filepath.ToSlash(dir) {
+		t.Errorf("Connection is not durable: %v", n.connections)
+		return
+	}
+
+	if err := n.GenerateGo(ioutil.Discard, GenConfig{Package: "main"}); err == nil || !strings.Contains(err.Error(), "durable") {
+		t.Errorf("Expected an error for generating code of a durable connection, got %v", err)
+	}
+
+	out, err := runInts(n, []int{5})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(out, []int{10}) {
+		t.Errorf("%v != [10]", out)
+	}
+}
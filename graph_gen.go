@@ -0,0 +1,765 @@
+package goflow
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenConfig sets up Go code generation for a graph.
+type GenConfig struct {
+	Package string // Name of the package of the generated code
+	PkgPath string // Import path of that package, types defined in it are not qualified
+	Name    string // Name of the generated network type
+	Source  string // Graph definition file name mentioned in the comments
+}
+
+// GenerateGo writes Go source code of a network type equivalent to the graph.
+// The generated code creates processes with a factory, so constructors are
+// still used, but it wires ports by setting struct fields to typed channels,
+// so it is checked by the compiler and does not use reflection at run-time.
+//
+// All processes must be created by a factory from components which are not graphs.
+// Subgraphs can be generated separately and registered as components.
+// Supervision and dead letters are not generated, so supervised processes and
+// Err outports which are neither connected nor exported are rejected.
+func (n *Graph) GenerateGo(w io.Writer, conf GenConfig) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	g := &generator{
+		graph:   n,
+		conf:    conf,
+		imports: map[string]string{"github.com/trustmaster/goflow": "goflow", "fmt": "fmt", "sync": "sync"},
+		chans:   make(map[uintptr]*genChan),
+		ins:     make(map[address]*genChan),
+		outs:    make(map[address]*genChan),
+	}
+
+	if conf.PkgPath == "github.com/trustmaster/goflow" {
+		delete(g.imports, conf.PkgPath)
+	}
+
+	src, err := g.generate()
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("generate: invalid code: %w\n%s", err, src)
+	}
+
+	_, err = w.Write(formatted)
+
+	return err
+}
+
+// genChan is a channel in the generated code.
+type genChan struct {
+	expr    string // Variable or field holding the channel
+	elem    string // Element type
+	buffer  int
+	senders int    // Number of processes and IIPs which send to the channel
+	wg      string // Variable of the wait group closing the channel
+}
+
+// genProc is a process in the generated code.
+type genProc struct {
+	name     string
+	fields   []string // Fields of the network holding the replicas
+	typ      reflect.Type
+	assigns  []string // Port assignments for a replica referred to as %[1]s
+	senderTo []*genChan
+}
+
+type generator struct {
+	graph   *Graph
+	conf    GenConfig
+	imports map[string]string // Package aliases by import path
+	procs   []*genProc
+	chans   map[uintptr]*genChan
+	list    []*genChan // Channels in order of creation
+	ins     map[address]*genChan
+	outs    map[address]*genChan
+	iips    []genIIP
+}
+
+// genIIP is an IIP sent by the generated code.
+type genIIP struct {
+	c   *genChan
+	lit string
+}
+
+func (g *generator) generate() ([]byte, error) {
+	n := g.graph
+
+	for _, name := range sortedProcNames(n.procs) {
+		if err := g.addProc(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.addConnections(); err != nil {
+		return nil, err
+	}
+
+	if err := g.addIIPs(); err != nil {
+		return nil, err
+	}
+
+	portFields, err := g.addExports()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.checkRuntimeFeatures(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range g.procs {
+		if err := g.assignPorts(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(g.procs) == 0 {
+		delete(g.imports, "fmt")
+	}
+
+	var body bytes.Buffer
+
+	g.writeType(&body, portFields)
+	g.writeConstructor(&body)
+	g.writeProcess(&body)
+
+	var src bytes.Buffer
+
+	fmt.Fprintf(&src, "// Code generated by goflow gen from %s. DO NOT EDIT.\n\npackage %s\n\n", g.sourceName(), g.conf.Package)
+	g.writeImports(&src)
+	src.Write(body.Bytes())
+
+	return src.Bytes(), nil
+}
+
+// addProc records a process and the fields for its replicas.
+func (g *generator) addProc(name string) error {
+	n := g.graph
+	proc := n.procs[name]
+
+	if _, isGraph := proc.(*Graph); isGraph {
+		return fmt.Errorf("process '%s' is a subgraph, generate it separately and register it as a component", name)
+	}
+
+	t := reflect.TypeOf(proc)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || t.Elem().Name() == "" {
+		return fmt.Errorf("process '%s' is not a pointer to a named struct", name)
+	}
+
+	if n.components[name] == "" {
+		return fmt.Errorf("process '%s' has not been created by a factory", name)
+	}
+
+	p := &genProc{name: name, typ: t}
+	base := "p" + goIdentifier(name)
+
+	size := len(n.pools[name]) + 1
+	for i := 0; i < size; i++ {
+		field := base
+		if size > 1 {
+			field += strconv.Itoa(i)
+		}
+
+		p.fields = append(p.fields, field)
+	}
+
+	g.procs = append(g.procs, p)
+
+	return nil
+}
+
+// checkRuntimeFeatures rejects processes relying on the graph runtime for
+// supervision or dead letters. It must be called after ports are wired.
+func (g *generator) checkRuntimeFeatures() error {
+	n := g.graph
+
+	for _, p := range g.procs {
+		if _, supervised := n.supervisors[p.name]; supervised {
+			return fmt.Errorf("process '%s' is supervised, which is not supported", p.name)
+		}
+
+		addr := parseAddress(p.name, ErrPort)
+		if _, ok := errPortOf(n.procs[p.name]); ok && g.outs[addr] == nil {
+			return fmt.Errorf("port '%s' routes to dead letters, which is not supported: connect or export it", addr)
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) proc(name string) *genProc {
+	for _, p := range g.procs {
+		if p.name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// newChan adds a channel variable.
+func (g *generator) newChan(expr string, elem reflect.Type, buffer int) (*genChan, error) {
+	typ, err := g.typeName(elem)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &genChan{expr: expr, elem: typ, buffer: buffer}
+	g.list = append(g.list, c)
+
+	return c, nil
+}
+
+// addConnections creates channels for connections. Connections sharing a channel
+// in the graph, like fan-in and fan-out, share it in the generated code too.
+func (g *generator) addConnections() error {
+	for _, conn := range g.graph.connections {
+		if conn.adapter != nil {
+			return fmt.Errorf("connection '%s -> %s' converts IP envelopes, which is not supported", conn.src, conn.tgt)
+		}
+
+		if conn.durable != nil {
+			return fmt.Errorf("connection '%s -> %s' is durable, which is not supported", conn.src, conn.tgt)
+		}
+
+		ptr := conn.channel.Pointer()
+
+		c, exists := g.chans[ptr]
+		if !exists {
+			var err error
+			if c, err = g.newChan("c"+strconv.Itoa(len(g.list)), conn.channel.Type().Elem(), conn.buffer); err != nil {
+				return err
+			}
+
+			g.chans[ptr] = c
+		}
+
+		if _, sends := g.outs[conn.src]; !sends {
+			g.outs[conn.src] = c
+			g.addSender(conn.src, c)
+		}
+
+		g.ins[conn.tgt] = c
+	}
+
+	return nil
+}
+
+// addSender counts the replicas of a process sending to a channel. Array and map
+// ports are closed by components themselves, so only plain ports are counted.
+func (g *generator) addSender(addr address, c *genChan) {
+	if addr.index > -1 || addr.key != "" {
+		return
+	}
+
+	p := g.proc(addr.proc)
+	c.senders += len(p.fields)
+	p.senderTo = append(p.senderTo, c)
+}
+
+// addIIPs creates goroutines sending IIPs.
+func (g *generator) addIIPs() error {
+	for _, ip := range g.graph.iips {
+		c, exists := g.ins[ip.addr]
+		if !exists {
+			port, err := g.graph.getProcPort(ip.addr.proc, ip.addr.port, reflect.RecvDir)
+			if err != nil {
+				return err
+			}
+
+			t := portChanType(port.Type())
+			if t == nil {
+				return fmt.Errorf("IIP target '%s' is not a channel", ip.addr)
+			}
+
+			if c, err = g.newChan("c"+strconv.Itoa(len(g.list)), t.Elem(), g.graph.conf.BufferSize); err != nil {
+				return err
+			}
+
+			g.ins[ip.addr] = c
+		}
+
+		// Parameters are resolved with their current values
+		value, err := g.graph.iipValue(ip)
+		if err != nil {
+			return fmt.Errorf("IIP for '%s': %w", ip.addr, err)
+		}
+
+		lit, err := g.literal(reflect.ValueOf(value))
+		if err != nil {
+			return fmt.Errorf("IIP for '%s': %w", ip.addr, err)
+		}
+
+		c.senders++
+		g.iips = append(g.iips, genIIP{c: c, lit: lit})
+	}
+
+	return nil
+}
+
+// addExports maps exported ports to fields of the network type. Processes read
+// inports set by the user and the network closes its outports when done.
+func (g *generator) addExports() ([]string, error) {
+	var fields []string
+
+	for _, dir := range []reflect.ChanDir{reflect.RecvDir, reflect.SendDir} {
+		ports, decl := g.graph.inPorts, "<-chan"
+		if dir == reflect.SendDir {
+			ports, decl = g.graph.outPorts, "chan<-"
+		}
+
+		for _, name := range sortedPortNames(ports) {
+			addr := ports[name].addr
+
+			port, err := g.graph.getProcPort(addr.proc, addr.port, dir)
+			if err != nil {
+				return nil, err
+			}
+
+			t := portChanType(port.Type())
+			if t == nil {
+				return nil, fmt.Errorf("exported port '%s' is not a channel", name)
+			}
+
+			c, err := g.newChan("g."+name, t.Elem(), 0)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, fmt.Sprintf("%s %s %s", name, decl, c.elem))
+
+			if g.ins[addr] != nil || g.outs[addr] != nil {
+				return nil, fmt.Errorf("port '%s' is both exported and connected", addr)
+			}
+
+			if dir == reflect.RecvDir {
+				g.ins[addr] = c
+			} else {
+				g.outs[addr] = c
+				g.addSender(addr, c)
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// assignPorts generates statements attaching channels to the ports of a process.
+func (g *generator) assignPorts(p *genProc) error {
+	arrays := make(map[string]int)
+	maps := make(map[string]bool)
+
+	var addrs []address
+
+	for _, m := range []map[address]*genChan{g.ins, g.outs} {
+		for addr := range m {
+			if addr.proc == p.name {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].port < addrs[j].port || addrs[i].port == addrs[j].port && addrs[i].portName() < addrs[j].portName()
+	})
+
+	var assigns []string
+
+	for _, addr := range addrs {
+		field, ok := p.typ.Elem().FieldByName(addr.port)
+		if !ok || field.PkgPath != "" {
+			return fmt.Errorf("process '%s' does not have a valid port '%s'", p.name, addr.port)
+		}
+
+		c := g.ins[addr]
+		if c == nil {
+			c = g.outs[addr]
+		}
+
+		switch {
+		case addr.index > -1:
+			if field.Type.Kind() != reflect.Slice {
+				return fmt.Errorf("port '%s' is not an array port", addr)
+			}
+
+			if addr.index+1 > arrays[addr.port] {
+				arrays[addr.port] = addr.index + 1
+			}
+
+			assigns = append(assigns, fmt.Sprintf("%%[1]s.%s[%d] = %s", addr.port, addr.index, c.expr))
+		case addr.key != "":
+			if field.Type.Kind() != reflect.Map {
+				return fmt.Errorf("port '%s' is not a map port", addr)
+			}
+
+			maps[addr.port] = true
+			assigns = append(assigns, fmt.Sprintf("%%[1]s.%s[%q] = %s", addr.port, addr.key, c.expr))
+		default:
+			assigns = append(assigns, fmt.Sprintf("%%[1]s.%s = %s", addr.port, c.expr))
+		}
+	}
+
+	var makes []string
+
+	for _, port := range sortedKeys(arrays, maps) {
+		field, _ := p.typ.Elem().FieldByName(port)
+
+		typ, err := g.typeName(field.Type)
+		if err != nil {
+			return err
+		}
+
+		if size, isArray := arrays[port]; isArray {
+			makes = append(makes, fmt.Sprintf("%%[1]s.%s = make(%s, %d)", port, typ, size))
+		} else {
+			makes = append(makes, fmt.Sprintf("%%[1]s.%s = make(%s)", port, typ))
+		}
+	}
+
+	p.assigns = append(makes, assigns...)
+
+	return nil
+}
+
+func sortedKeys(arrays map[string]int, maps map[string]bool) []string {
+	var res []string
+	for k := range arrays {
+		res = append(res, k)
+	}
+
+	for k := range maps {
+		res = append(res, k)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+func (g *generator) writeImports(w *bytes.Buffer) {
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	w.WriteString("import (\n")
+
+	for _, p := range paths {
+		if path.Base(p) == g.imports[p] {
+			fmt.Fprintf(w, "%q\n", p)
+		} else {
+			fmt.Fprintf(w, "%s %q\n", g.imports[p], p)
+		}
+	}
+
+	w.WriteString(")\n\n")
+}
+
+func (g *generator) writeType(w *bytes.Buffer, portFields []string) {
+	fmt.Fprintf(w, "// %s is a network generated from %s.\n", g.conf.Name, g.sourceName())
+	fmt.Fprintf(w, "type %s struct {\n", g.conf.Name)
+
+	for _, f := range portFields {
+		fmt.Fprintln(w, f)
+	}
+
+	if len(portFields) > 0 {
+		w.WriteString("\n")
+	}
+
+	for _, p := range g.procs {
+		typ, _ := g.typeName(p.typ)
+		fmt.Fprintf(w, "%s %s\n", strings.Join(p.fields, ", "), typ)
+	}
+
+	w.WriteString("}\n\n")
+}
+
+func (g *generator) writeConstructor(w *bytes.Buffer) {
+	fmt.Fprintf(w, "// New%s creates the processes of the network with components registered in a factory.\n", g.conf.Name)
+	fmt.Fprintf(w, "func New%[1]s(f *%[2]sFactory) (*%[1]s, error) {\n", g.conf.Name, g.qualifier("github.com/trustmaster/goflow"))
+	fmt.Fprintf(w, "g := new(%s)\n\n", g.conf.Name)
+
+	if len(g.procs) > 0 {
+		w.WriteString("var (\nc interface{}\nerr error\nok bool\n)\n\n")
+	}
+
+	for _, p := range g.procs {
+		typ, _ := g.typeName(p.typ)
+
+		for _, field := range p.fields {
+			fmt.Fprintf(w, "if c, err = f.Create(%q); err != nil {\nreturn nil, err\n}\n\n", g.graph.components[p.name])
+			fmt.Fprintf(w, "if g.%s, ok = c.(%s); !ok {\n", field, typ)
+			fmt.Fprintf(w, "return nil, fmt.Errorf(\"process '%s': unexpected component type %%T\", c)\n}\n\n", p.name)
+		}
+	}
+
+	w.WriteString("return g, nil\n}\n\n")
+}
+
+func (g *generator) writeProcess(w *bytes.Buffer) {
+	w.WriteString("// Process runs the network until all processes have finished.\n")
+	fmt.Fprintf(w, "func (g *%s) Process() {\n", g.conf.Name)
+	w.WriteString("var wg sync.WaitGroup\n\n")
+
+	closers := 0
+
+	for _, c := range g.list {
+		if !strings.HasPrefix(c.expr, "g.") {
+			fmt.Fprintf(w, "%s := make(chan %s, %d)\n", c.expr, c.elem, c.buffer)
+		}
+
+		if c.senders > 0 {
+			c.wg = "s" + strconv.Itoa(closers)
+			closers++
+		}
+	}
+
+	w.WriteString("\n")
+
+	// Channels are closed when all their senders have finished
+	for _, c := range g.list {
+		if c.wg == "" {
+			continue
+		}
+
+		fmt.Fprintf(w, "var %s sync.WaitGroup\n%s.Add(%d)\n\n", c.wg, c.wg, c.senders)
+		fmt.Fprintf(w, "go func() {\n%s.Wait()\nclose(%s)\n}()\n\n", c.wg, c.expr)
+	}
+
+	for _, p := range g.procs {
+		for _, field := range p.fields {
+			for _, a := range p.assigns {
+				fmt.Fprintf(w, a+"\n", "g."+field)
+			}
+
+			w.WriteString("\n")
+		}
+	}
+
+	for _, ip := range g.iips {
+		fmt.Fprintf(w, "go func() {\n%s <- %s\n%s.Done()\n}()\n\n", ip.c.expr, ip.lit, ip.c.wg)
+	}
+
+	for _, p := range g.procs {
+		for _, field := range p.fields {
+			fmt.Fprintf(w, "wg.Add(1)\n\ngo func() {\ng.%s.Process()\n\n", field)
+
+			for _, c := range p.senderTo {
+				fmt.Fprintf(w, "%s.Done()\n", c.wg)
+			}
+
+			w.WriteString("wg.Done()\n}()\n\n")
+		}
+	}
+
+	w.WriteString("wg.Wait()\n}\n")
+}
+
+func (g *generator) sourceName() string {
+	if g.conf.Source == "" {
+		return "a graph"
+	}
+
+	return g.conf.Source
+}
+
+// qualifier returns a package alias with a dot or nothing for the generated package itself.
+func (g *generator) qualifier(pkgPath string) string {
+	if pkgPath == g.conf.PkgPath {
+		return ""
+	}
+
+	if alias, ok := g.imports[pkgPath]; ok {
+		return alias + "."
+	}
+
+	alias := goIdentifier(path.Base(pkgPath))
+	taken := true
+
+	for i := 1; taken; i++ {
+		taken = false
+
+		for _, a := range g.imports {
+			if a == alias {
+				taken = true
+				alias = goIdentifier(path.Base(pkgPath)) + strconv.Itoa(i)
+
+				break
+			}
+		}
+	}
+
+	g.imports[pkgPath] = alias
+
+	return alias + "."
+}
+
+// typeName returns Go syntax of a type.
+func (g *generator) typeName(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+
+		return g.qualifier(t.PkgPath()) + t.Name(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+		elem, err := g.typeName(t.Elem())
+		if err != nil {
+			return "", err
+		}
+
+		switch t.Kind() {
+		case reflect.Ptr:
+			return "*" + elem, nil
+		case reflect.Slice:
+			return "[]" + elem, nil
+		case reflect.Array:
+			return "[" + strconv.Itoa(t.Len()) + "]" + elem, nil
+		}
+
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem, nil
+		case reflect.SendDir:
+			return "chan<- " + elem, nil
+		default:
+			return "chan " + elem, nil
+		}
+	case reflect.Map:
+		key, err := g.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+
+		elem, err := g.typeName(t.Elem())
+		if err != nil {
+			return "", err
+		}
+
+		return "map[" + key + "]" + elem, nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+
+	return "", fmt.Errorf("type %s is not supported", t)
+}
+
+// literal returns Go syntax of an IIP value.
+func (g *generator) literal(v reflect.Value) (string, error) {
+	if !v.IsValid() {
+		return "nil", nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		lit := fmt.Sprintf("%#v", v.Interface())
+		if v.Type().Name() != "" && v.Type().PkgPath() != "" {
+			typ, err := g.typeName(v.Type())
+			if err != nil {
+				return "", err
+			}
+
+			return typ + "(" + lit + ")", nil
+		}
+
+		return lit, nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return "nil", nil
+		}
+
+		if v.Kind() == reflect.Interface {
+			return g.literal(v.Elem())
+		}
+	case reflect.Slice:
+		typ, err := g.typeName(v.Type())
+		if err != nil {
+			return "", err
+		}
+
+		items := make([]string, v.Len())
+		for i := range items {
+			if items[i], err = g.literal(v.Index(i)); err != nil {
+				return "", err
+			}
+		}
+
+		return typ + "{" + strings.Join(items, ", ") + "}", nil
+	case reflect.Map:
+		typ, err := g.typeName(v.Type())
+		if err != nil {
+			return "", err
+		}
+
+		items := make([]string, 0, v.Len())
+
+		for _, k := range v.MapKeys() {
+			key, err := g.literal(k)
+			if err != nil {
+				return "", err
+			}
+
+			val, err := g.literal(v.MapIndex(k))
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, key+": "+val)
+		}
+
+		sort.Strings(items)
+
+		return typ + "{" + strings.Join(items, ", ") + "}", nil
+	}
+
+	return "", fmt.Errorf("value of type %s cannot be generated", v.Type())
+}
+
+// goIdentifier converts a name into a valid Go identifier.
+func goIdentifier(name string) string {
+	var b strings.Builder
+
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			b.WriteRune(r)
+		case unicode.IsDigit(r):
+			if i == 0 {
+				b.WriteRune('_')
+			}
+
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,206 @@
+// Code generated by goflow gen from graph_gen_test.go. DO NOT EDIT.
+
+package goflow
+
+import (
+	"fmt"
+	"sync"
+)
+
+// GenExample is a network generated from graph_gen_test.go.
+type GenExample struct {
+	In  <-chan int
+	Sum chan<- int
+
+	pa       *adder
+	pd0, pd1 *doubler
+	pe       *echo
+	pm       *router
+	pr       *irouter
+}
+
+// NewGenExample creates the processes of the network with components registered in a factory.
+func NewGenExample(f *Factory) (*GenExample, error) {
+	g := new(GenExample)
+
+	var (
+		c   interface{}
+		err error
+		ok  bool
+	)
+
+	if c, err = f.Create("adder"); err != nil {
+		return nil, err
+	}
+
+	if g.pa, ok = c.(*adder); !ok {
+		return nil, fmt.Errorf("process 'a': unexpected component type %T", c)
+	}
+
+	if c, err = f.Create("doubler"); err != nil {
+		return nil, err
+	}
+
+	if g.pd0, ok = c.(*doubler); !ok {
+		return nil, fmt.Errorf("process 'd': unexpected component type %T", c)
+	}
+
+	if c, err = f.Create("doubler"); err != nil {
+		return nil, err
+	}
+
+	if g.pd1, ok = c.(*doubler); !ok {
+		return nil, fmt.Errorf("process 'd': unexpected component type %T", c)
+	}
+
+	if c, err = f.Create("echo"); err != nil {
+		return nil, err
+	}
+
+	if g.pe, ok = c.(*echo); !ok {
+		return nil, fmt.Errorf("process 'e': unexpected component type %T", c)
+	}
+
+	if c, err = f.Create("router"); err != nil {
+		return nil, err
+	}
+
+	if g.pm, ok = c.(*router); !ok {
+		return nil, fmt.Errorf("process 'm': unexpected component type %T", c)
+	}
+
+	if c, err = f.Create("irouter"); err != nil {
+		return nil, err
+	}
+
+	if g.pr, ok = c.(*irouter); !ok {
+		return nil, fmt.Errorf("process 'r': unexpected component type %T", c)
+	}
+
+	return g, nil
+}
+
+// Process runs the network until all processes have finished.
+func (g *GenExample) Process() {
+	var wg sync.WaitGroup
+
+	c0 := make(chan int, 1)
+	c1 := make(chan int, 0)
+	c2 := make(chan int, 0)
+	c3 := make(chan int, 0)
+	c4 := make(chan int, 0)
+
+	var s0 sync.WaitGroup
+	s0.Add(1)
+
+	go func() {
+		s0.Wait()
+		close(c0)
+	}()
+
+	var s1 sync.WaitGroup
+	s1.Add(2)
+
+	go func() {
+		s1.Wait()
+		close(c3)
+	}()
+
+	var s2 sync.WaitGroup
+	s2.Add(1)
+
+	go func() {
+		s2.Wait()
+		close(c4)
+	}()
+
+	var s3 sync.WaitGroup
+	s3.Add(1)
+
+	go func() {
+		s3.Wait()
+		close(g.Sum)
+	}()
+
+	g.pa.Op1 = c3
+	g.pa.Op2 = c4
+	g.pa.Sum = g.Sum
+
+	g.pd0.In = c2
+	g.pd0.Out = c3
+
+	g.pd1.In = c2
+	g.pd1.Out = c3
+
+	g.pe.In = g.In
+	g.pe.Out = c0
+
+	g.pm.In = make(map[string]<-chan int)
+	g.pm.Out = make(map[string]chan<- int)
+	g.pm.In["x"] = c1
+	g.pm.Out["x"] = c2
+
+	g.pr.In = make([]<-chan int, 1)
+	g.pr.Out = make([]chan<- int, 1)
+	g.pr.In[0] = c0
+	g.pr.Out[0] = c1
+
+	go func() {
+		c4 <- 10
+		s2.Done()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		g.pa.Process()
+
+		s3.Done()
+		wg.Done()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		g.pd0.Process()
+
+		s1.Done()
+		wg.Done()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		g.pd1.Process()
+
+		s1.Done()
+		wg.Done()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		g.pe.Process()
+
+		s0.Done()
+		wg.Done()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		g.pm.Process()
+
+		wg.Done()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		g.pr.Process()
+
+		wg.Done()
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,193 @@
+package goflow
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update generated test files")
+
+// newGenExample creates a graph using array, map and pooled processes for code generation.
+func newGenExample(f *Factory) (*Graph, error) {
+	for name, c := range map[string]Constructor{
+		"irouter": func() (interface{}, error) { return new(irouter), nil },
+		"router":  func() (interface{}, error) { return new(router), nil },
+	} {
+		if err := f.Register(name, c); err != nil {
+			return nil, err
+		}
+	}
+
+	n := NewGraph()
+
+	for _, err := range []error{
+		n.AddNew("e", "echo", f),
+		n.AddNew("r", "irouter", f),
+		n.AddNew("m", "router", f),
+		n.AddNewPool("d", "doubler", 2, f),
+		n.AddNew("a", "adder", f),
+		n.ConnectBuf("e", "Out", "r", "In[0]", 1),
+		n.Connect("r", "Out[0]", "m", "In[x]"),
+		n.Connect("m", "Out[x]", "d", "In"),
+		n.Connect("d", "Out", "a", "Op1"),
+		n.AddIIP("a", "Op2", 10),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n.MapInPort("In", "e", "In")
+	n.MapOutPort("Sum", "a", "Sum")
+
+	return n, nil
+}
+
+func TestGenerateGo(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := newGenExample(f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	err = n.GenerateGo(&buf, GenConfig{
+		Package: "goflow",
+		PkgPath: "github.com/trustmaster/goflow",
+		Name:    "GenExample",
+		Source:  "graph_gen_test.go",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	const golden = "graph_gen_example_test.go"
+
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Error(err)
+		}
+
+		return
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if buf.String() != string(expected) {
+		t.Errorf("Generated code differs from %s, run the test with -update:\n%s", golden, buf.String())
+	}
+}
+
+func TestGeneratedNetwork(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := newGenExample(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	g, err := NewGenExample(f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+	g.In = in
+	g.Sum = out
+
+	wait := Run(g)
+
+	in <- 1
+	close(in)
+
+	res := []int{}
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	expectInts(t, res, []int{12})
+}
+
+func TestGenerateSubgraphFails(t *testing.T) {
+	sub, err := newDoubleEcho()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n := NewGraph()
+	if err := n.Add("sub", sub); err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n.GenerateGo(ioutil.Discard, GenConfig{Package: "main", Name: "Net"})
+	if err == nil || !strings.Contains(err.Error(), "subgraph") {
+		t.Errorf("Expected a subgraph error, got %v", err)
+	}
+}
+
+func TestGenerateRuntimeFeaturesFail(t *testing.T) {
+	f := NewFactory()
+	if err := f.Register("rejecter", func() (interface{}, error) {
+		return new(rejecter), nil
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n := NewGraph()
+	if err := n.AddNew("r", "rejecter", f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "r", "In")
+	n.MapOutPort("Out", "r", "Out")
+
+	err := n.GenerateGo(ioutil.Discard, GenConfig{Package: "main", Name: "Net"})
+	if err == nil || !strings.Contains(err.Error(), "dead letters") {
+		t.Errorf("Expected a dead letters error, got %v", err)
+	}
+
+	// An exported Err port is handled by the user
+	n.MapOutPort("Err", "r", "Err")
+
+	if err := n.GenerateGo(ioutil.Discard, GenConfig{Package: "main", Name: "Net"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Supervise("r", Supervision{Restart: RestartOnFailure}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n.GenerateGo(ioutil.Discard, GenConfig{Package: "main", Name: "Net"})
+	if err == nil || !strings.Contains(err.Error(), "supervised") {
+		t.Errorf("Expected a supervision error, got %v", err)
+	}
+}
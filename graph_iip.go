@@ -13,75 +13,144 @@ type iip struct {
 }
 
 // AddIIP adds an Initial Information packet to the network.
+// If the network is running, the packet is sent immediately.
 func (n *Graph) AddIIP(processName, portName string, data interface{}) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	addr := parseAddress(processName, portName)
 
-	if _, exists := n.procs[processName]; exists {
-		n.iips = append(n.iips, iip{data: data, addr: addr})
-		return nil
+	if _, exists := n.procs[processName]; !exists {
+		return fmt.Errorf("AddIIP: could not find '%s'", addr)
+	}
+
+	ip := iip{data: data, addr: addr}
+
+	if n.running {
+		if err := n.sendIIP(ip, false); err != nil {
+			return fmt.Errorf("AddIIP: %w", err)
+		}
+
+		n.startIfReady(processName)
 	}
 
-	return fmt.Errorf("AddIIP: could not find '%s'", addr)
+	n.iips = append(n.iips, ip)
+
+	return nil
 }
 
 // RemoveIIP detaches an IIP from specific process and port.
 func (n *Graph) RemoveIIP(processName, portName string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	addr := parseAddress(processName, portName)
+	if !n.removeIIP(addr, func(interface{}) bool { return true }) {
+		return fmt.Errorf("RemoveIIP: could not find IIP for '%s'", addr)
+	}
+
+	return nil
+}
+
+// removeIIP detaches the first IIP of an address whose data matches and tells if
+// there was one. It must be called with the graph locked.
+func (n *Graph) removeIIP(addr address, match func(data interface{}) bool) bool {
 	for i := range n.iips {
-		if n.iips[i].addr == addr {
+		if n.iips[i].addr == addr && match(n.iips[i].data) {
 			// Remove item from the slice
 			n.iips[len(n.iips)-1], n.iips[i], n.iips = iip{}, n.iips[len(n.iips)-1], n.iips[:len(n.iips)-1]
-			return nil
+			return true
 		}
 	}
 
-	return fmt.Errorf("RemoveIIP: could not find IIP for '%s'", addr)
+	return false
 }
 
-// sendIIPs sends Initial Information Packets upon network start.
-func (n *Graph) sendIIPs() error {
+// sendIIPs sends Initial Information Packets upon network start. Processes
+// restored from the last checkpoint, which may be nil, get their ports connected
+// but not the packets, which their states already reflect.
+func (n *Graph) sendIIPs(last *Checkpoint) error {
 	// Send initial IPs
 	for i := range n.iips {
-		ip := n.iips[i]
+		if err := n.sendIIP(n.iips[i], last.restores(n.iips[i].addr.proc)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendIIP sends a single Initial Information Packet. A skipped packet is not
+// sent, but the port is closed all the same once it has no other senders.
+func (n *Graph) sendIIP(ip iip, skip bool) error {
+	// Get the receiver port channel
+	channel, found := n.channelByInPortAddr(ip.addr)
+	external := found // Channels of graph inports are closed by their owners
 
-		// Get the receiver port channel
-		channel, found := n.channelByInPortAddr(ip.addr)
+	if !found {
+		channel, found = n.channelByConnectionAddr(ip.addr)
+	}
+
+	live := n.isStarted(ip.addr.proc)
 
-		if !found {
-			channel, found = n.channelByConnectionAddr(ip.addr)
+	if !found {
+		// Try to find a proc and attach a new channel to it
+		recvPort, err := n.getProcPort(ip.addr.proc, ip.addr.port, reflect.RecvDir)
+		if err != nil {
+			return err
 		}
 
-		if !found {
-			// Try to find a proc and attach a new channel to it
-			recvPort, err := n.getProcPort(ip.addr.proc, ip.addr.port, reflect.RecvDir)
-			if err != nil {
-				return err
+		if live {
+			// A running process sees a receive-only channel, the graph needs the original one
+			channel = n.bidiChan(currentChan(recvPort, ip.addr))
+			if isNilChan(channel) {
+				return fmt.Errorf("process '%s' is running and its port is not connected", ip.addr)
 			}
-
+		} else {
 			channel, err = attachPort(recvPort, ip.addr, reflect.RecvDir, reflect.ValueOf(nil), n.conf.BufferSize)
 			if err != nil {
 				return err
 			}
-
-			found = true
 		}
 
-		if !found {
-			return fmt.Errorf("IIP target not found: '%s'", ip.addr)
-		}
+		found = true
+	}
+
+	if !found {
+		return fmt.Errorf("IIP target not found: '%s'", ip.addr)
+	}
 
-		// Increase reference count for the channel
+	value, err := n.iipValue(ip)
+	if err != nil {
+		return fmt.Errorf("IIP for '%s': %w", ip.addr, err)
+	}
+
+	// Plain values sent to envelope ports are wrapped and vice versa
+	data, err := adaptPacket(reflect.ValueOf(value), channel.Type().Elem(), IIPOrigin)
+	if err != nil {
+		return fmt.Errorf("IIP for '%s': %w", ip.addr, err)
+	}
+
+	// Increase reference count for the channel. The channel of a running process
+	// is closed once its last sender is gone and cannot take more packets.
+	if live && !external {
+		if !n.incChanListenersCountIfOpen(channel) {
+			return fmt.Errorf("port '%s' of a running process is closed", ip.addr)
+		}
+	} else {
 		n.incChanListenersCount(channel)
+	}
 
-		// Send data to the port
-		go func(channel, data reflect.Value) {
+	// Send data to the port
+	go func(channel, data reflect.Value) {
+		if !skip {
 			channel.Send(data)
+		}
 
-			if n.decChanListenersCount(channel) {
-				channel.Close()
-			}
-		}(channel, reflect.ValueOf(ip.data))
-	}
+		if n.decChanListenersCount(channel) {
+			channel.Close()
+		}
+	}(channel, data)
 
 	return nil
 }
@@ -0,0 +1,296 @@
+package goflow
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IIPOrigin is the origin of IPs created from Initial Information Packets.
+const IIPOrigin = "IIP"
+
+// IP is an information packet envelope which carries a value along with its
+// metadata: headers like trace IDs or correlation keys, the time it was created
+// and the port it was created at. Components opt into envelopes by using ports
+// of IP or *IP type, e.g.
+//
+//	In  <-chan *IP
+//	Out chan<- *IP
+//
+// When such a port is connected to a port of plain values, the graph wraps the
+// values into envelopes or unwraps them. Envelopes created by the graph, including
+// the ones for IIPs, are stamped with the time and the sender port, so every record
+// can be traced back to where it entered the pipeline. Components which compute
+// new packets from envelopes use Derive to keep the lineage.
+type IP struct {
+	Value   interface{}       `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Created time.Time         `json:"created"`
+	Origin  string            `json:"origin,omitempty"`  // Sender port like "proc.Out" or IIPOrigin
+	Lineage []string          `json:"lineage,omitempty"` // Origins of the packets this one was derived from, oldest first
+}
+
+// NewIP wraps a value into an envelope created now.
+func NewIP(value interface{}) *IP {
+	return &IP{Value: value, Created: time.Now()}
+}
+
+// Header returns a header value or an empty string if it is not set.
+func (ip *IP) Header(key string) string {
+	return ip.Headers[key]
+}
+
+// SetHeader sets a header and returns the envelope to allow chaining.
+func (ip *IP) SetHeader(key, value string) *IP {
+	if ip.Headers == nil {
+		ip.Headers = make(map[string]string)
+	}
+
+	ip.Headers[key] = value
+
+	return ip
+}
+
+// Derive creates an envelope for a value computed from this one. It inherits
+// the headers and extends the lineage with the origin of this envelope. The
+// graph sets its origin when it is sent.
+func (ip *IP) Derive(value interface{}) *IP {
+	res := &IP{Value: value, Created: time.Now()}
+
+	if len(ip.Headers) > 0 {
+		res.Headers = make(map[string]string, len(ip.Headers))
+		for k, v := range ip.Headers {
+			res.Headers[k] = v
+		}
+	}
+
+	if ip.Origin != "" {
+		res.Lineage = append(append([]string{}, ip.Lineage...), ip.Origin)
+	} else if len(ip.Lineage) > 0 {
+		res.Lineage = append([]string{}, ip.Lineage...)
+	}
+
+	return res
+}
+
+// String formats the value followed by the origin and headers, e.g. "5 [origin=a.Out trace=1]".
+func (ip IP) String() string {
+	meta := make([]string, 0, len(ip.Headers)+1)
+	if ip.Origin != "" {
+		meta = append(meta, "origin="+ip.Origin)
+	}
+
+	keys := make([]string, 0, len(ip.Headers))
+	for k := range ip.Headers {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		meta = append(meta, k+"="+ip.Headers[k])
+	}
+
+	if len(meta) == 0 {
+		return fmt.Sprint(ip.Value)
+	}
+
+	return fmt.Sprintf("%v [%s]", ip.Value, strings.Join(meta, " "))
+}
+
+var (
+	ipType    = reflect.TypeOf(IP{})
+	ipPtrType = reflect.TypeOf(&IP{})
+)
+
+// isIPType tells if packets of a type are envelopes.
+func isIPType(t reflect.Type) bool {
+	return t == ipType || t == ipPtrType
+}
+
+// asIP returns the envelope a packet is, if it is one.
+func asIP(v interface{}) (*IP, bool) {
+	switch ip := v.(type) {
+	case *IP:
+		if ip == nil {
+			return new(IP), true
+		}
+
+		return ip, true
+	case IP:
+		return &ip, true
+	default:
+		return nil, false
+	}
+}
+
+// needsAdapter tells if a connection between ports of different packet types
+// converts packets between envelopes and plain values.
+func needsAdapter(from, to reflect.Type) bool {
+	return from != to && (isIPType(from) || isIPType(to))
+}
+
+// adaptPacket converts a packet to type t. Plain values are wrapped into envelopes
+// created at origin, envelopes are unwrapped into their values. Envelopes without
+// a creation time or origin are stamped.
+func adaptPacket(v reflect.Value, t reflect.Type, origin string) (reflect.Value, error) {
+	if v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return reflect.Zero(t), nil
+	}
+
+	if v.Type() == t && !isIPType(t) {
+		return v, nil
+	}
+
+	ip, isIP := asIP(v.Interface())
+
+	if isIPType(t) {
+		if !isIP {
+			ip = &IP{Value: v.Interface()}
+		}
+
+		if ip.Created.IsZero() || ip.Origin == "" {
+			stamped := *ip
+			ip = &stamped
+
+			if ip.Created.IsZero() {
+				ip.Created = time.Now()
+			}
+
+			if ip.Origin == "" {
+				ip.Origin = origin
+			}
+		}
+
+		if t == ipType {
+			return reflect.ValueOf(*ip), nil
+		}
+
+		return reflect.ValueOf(ip), nil
+	}
+
+	if isIP {
+		return adaptPacket(reflect.ValueOf(&ip.Value).Elem(), t, origin)
+	}
+
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	return v, fmt.Errorf("cannot convert %s to %s", v.Type(), t)
+}
+
+// packetType returns the type of packets of a port or nil if it is not a channel port.
+func packetType(port reflect.Value) reflect.Type {
+	t := portChanType(port.Type())
+	if t == nil {
+		return nil
+	}
+
+	return t.Elem()
+}
+
+// connectAdapted connects ports which differ in using envelopes with a relay
+// between the sender and the receiver channels. Neither process may be running.
+// It must be called with the graph locked.
+func (n *Graph) connectAdapted(sendPort, recvPort reflect.Value, sendAddr, recvAddr address, bufferSize int) error {
+	from := n.findExistingChan(sendAddr, reflect.SendDir)
+	isNewFrom := isNilChan(from)
+
+	from, err := attachPort(sendPort, sendAddr, reflect.SendDir, from, bufferSize)
+	if err != nil {
+		return fmt.Errorf("connect '%s': %w", sendAddr, err)
+	}
+
+	to, err := attachPort(recvPort, recvAddr, reflect.RecvDir, n.findExistingChan(recvAddr, reflect.RecvDir), bufferSize)
+	if err != nil {
+		return fmt.Errorf("connect '%s': %w", recvAddr, err)
+	}
+
+	if isNewFrom {
+		n.incChanListenersCount(from)
+	}
+
+	// The relay is one more sender to the receiver
+	n.incChanListenersCount(to)
+
+	adapter := &ipAdapter{
+		from:   from,
+		to:     to,
+		origin: sendAddr.proc + "." + endpointOf(sendAddr).portName(),
+		target: recvAddr,
+	}
+
+	n.connections = append(n.connections, connection{
+		src:     sendAddr,
+		tgt:     recvAddr,
+		channel: to,
+		buffer:  bufferSize,
+		adapter: adapter,
+	})
+
+	if n.running {
+		go adapter.run(n, n.deadLetters)
+		n.startIfReady(sendAddr.proc)
+		n.startIfReady(recvAddr.proc)
+	}
+
+	return nil
+}
+
+// ipAdapter relays packets between a sender and a receiver which differ in
+// using envelopes. It counts as one of the senders of the receiving channel.
+type ipAdapter struct {
+	from   reflect.Value // Channel of the sender
+	to     reflect.Value // Channel of the receiver
+	origin string        // Sender port
+	target address       // Receiver port
+}
+
+// run relays packets until the sender channel is closed. Packets which cannot be
+// converted are sent to the dead letters of the receiver.
+func (a *ipAdapter) run(n *Graph, letters *deadLetterQueue) {
+	t := a.to.Type().Elem()
+
+	for {
+		v, ok := a.from.Recv()
+		if !ok {
+			break
+		}
+
+		p, err := adaptPacket(v, t, a.origin)
+		if err != nil {
+			letters.add(DeadLetter{
+				Time:    time.Now(),
+				Process: a.target.proc,
+				Port:    endpointOf(a.target).portName(),
+				Packet:  v.Interface(),
+				Err:     err,
+			})
+
+			continue
+		}
+
+		a.to.Send(p)
+	}
+
+	if n.decChanListenersCount(a.to) {
+		a.to.Close()
+	}
+}
+
+// startAdapters launches the relays of connections which need them.
+// It must be called with the graph locked.
+func (n *Graph) startAdapters() {
+	for _, c := range n.connections {
+		if c.adapter != nil {
+			go c.adapter.run(n, n.deadLetters)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package goflow
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTaggerGraph creates a graph "d" doubler -> "t" tagger with the tagger outport exported.
+func newTaggerGraph() (*Graph, error) {
+	n := NewGraph()
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Add("t", new(tagger)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Connect("d", "Out", "t", "In"); err != nil {
+		return nil, err
+	}
+
+	if err := n.AddIIP("t", "Tag", "x"); err != nil {
+		return nil, err
+	}
+
+	n.MapInPort("In", "d", "In")
+	n.MapOutPort("Out", "t", "Out")
+
+	return n, nil
+}
+
+// runIPs runs a graph which has an Out port of envelopes until it finishes.
+func runIPs(n *Graph) ([]*IP, error) {
+	out := make(chan *IP)
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		return nil, err
+	}
+
+	wait := Run(n)
+
+	var res []*IP
+	for ip := range out {
+		res = append(res, ip)
+	}
+
+	<-wait
+
+	return res, nil
+}
+
+func TestIPWrap(t *testing.T) {
+	n, err := newTaggerGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int, 1)
+	in <- 1
+	close(in)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	ips, err := runIPs(n)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(ips) != 1 {
+		t.Errorf("Unexpected output %v", ips)
+		return
+	}
+
+	ip := ips[0]
+
+	if ip.Value != 2 || ip.Header("tag") != "x" || ip.Created.IsZero() {
+		t.Errorf("Unexpected IP %#v", ip)
+	}
+
+	if !reflect.DeepEqual(ip.Lineage, []string{"d.Out"}) {
+		t.Errorf("Unexpected lineage %v", ip.Lineage)
+	}
+}
+
+func TestIPUnwrap(t *testing.T) {
+	n, err := newTaggerGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Add("e", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("t", "Out", "e", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapOutPort("Out", "e", "Out")
+
+	out, err := runInts(n, []int{1, 2, 3})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(out, []int{2, 4, 6}) {
+		t.Errorf("%v != [2 4 6]", out)
+	}
+}
+
+func TestIPFanIn(t *testing.T) {
+	n := NewGraph()
+
+	for _, name := range []string{"d1", "d2"} {
+		if err := n.Add(name, new(doubler)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := n.Add("t", new(tagger)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i, name := range []string{"d1", "d2"} {
+		if err := n.Connect(name, "Out", "t", "In"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if err := n.AddIIP(name, "In", i+1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := n.AddIIP("t", "Tag", "x"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapOutPort("Out", "t", "Out")
+
+	ips, err := runIPs(n)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var origins []string
+	for _, ip := range ips {
+		origins = append(origins, ip.Lineage...)
+	}
+
+	sort.Strings(origins)
+
+	if !reflect.DeepEqual(origins, []string{"d1.Out", "d2.Out"}) {
+		t.Errorf("Unexpected origins %v", origins)
+	}
+}
+
+func TestIPIIP(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.Add("t", new(tagger)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapOutPort("Out", "t", "Out")
+
+	for port, data := range map[string]interface{}{"Tag": "x", "In": 5} {
+		if err := n.AddIIP("t", port, data); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	ips, err := runIPs(n)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(ips) != 1 || ips[0].Value != 5 || !reflect.DeepEqual(ips[0].Lineage, []string{IIPOrigin}) {
+		t.Errorf("Unexpected output %v", ips)
+	}
+}
+
+func TestIPDeadLetter(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.Add("t", new(tagger)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Add("e", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("t", "Out", "e", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for port, data := range map[string]interface{}{"Tag": "x", "In": "five"} {
+		if err := n.AddIIP("t", port, data); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	n.MapOutPort("Out", "e", "Out")
+
+	letters := make(chan DeadLetter, 1)
+
+	if err := n.SetOutPort(DeadLetterPort, letters); err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan int)
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	for i := range out {
+		t.Errorf("Unexpected output %d", i)
+	}
+
+	<-wait
+
+	l, ok := <-letters
+	if !ok || l.Process != "e" || l.Port != "In" || !strings.Contains(l.Err.Error(), "cannot convert string to int") {
+		t.Errorf("Unexpected dead letter %v", l)
+	}
+}
+
+func TestIPRecord(t *testing.T) {
+	n, err := newTaggerGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int, 1)
+	in <- 1
+	close(in)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan *IP, 1)
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	r := NewRecorder(&buf, JSONCodec)
+	if err := n.Record(r, "t.In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	<-Run(n)
+
+	if err := r.Wait(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	events := decodeEvents(t, buf.Bytes())
+	if len(events) != 3 || events[1].Kind != RecordData {
+		t.Errorf("Unexpected events %v", events)
+		return
+	}
+
+	// Recorded envelopes are replayed with their metadata
+	v, err := replayPacket(events[1].Packet, ipPtrType)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if ip := v.Interface().(*IP); ip.Value != 2.0 || ip.Origin != "d.Out" {
+		t.Errorf("Unexpected IP %v", ip)
+	}
+}
+
+func TestSimulateIP(t *testing.T) {
+	n, err := newTaggerGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int, 1)
+	in <- 1
+	close(in)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan *IP, 1)
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	trace, err := n.Simulate(SimConfig{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if ip := <-out; ip.Value != 2 || !reflect.DeepEqual(ip.Lineage, []string{"d.Out"}) {
+		t.Errorf("Unexpected output %v", ip)
+	}
+
+	found := false
+
+	for _, step := range trace {
+		if step.From == "d.Out" && step.To == "t.In" && step.Packet == 2 {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Conversion is not traced: %v", trace)
+	}
+}
+
+var ipGraphJSON = `{
+	"processes": {
+		"t": {
+			"component": "tagger"
+		}
+	},
+	"connections": [
+		{
+			"data": "x",
+			"tgt": {
+				"process": "t",
+				"port": "Tag"
+			}
+		},
+		{
+			"data": 5,
+			"tgt": {
+				"process": "t",
+				"port": "In"
+			},
+			"metadata": {
+				"headers": {
+					"trace": "abc"
+				}
+			}
+		}
+	],
+	"outports": {
+		"Out": {
+			"process": "t",
+			"port": "Out"
+		}
+	}
+}`
+
+func TestLoadIPGraph(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := ParseJSON([]byte(ipGraphJSON), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ips, err := runIPs(n)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(ips) != 1 || ips[0].Value != 5.0 || ips[0].Header("trace") != "abc" || ips[0].Header("tag") != "x" {
+		t.Errorf("Unexpected output %v", ips)
+	}
+
+	// Headers need an IP port
+	if _, err := ParseJSON([]byte(strings.Replace(ipGraphJSON, `"data": "x",`, `"data": "x", "metadata": {"headers": {"a": "b"}},`, 1)), f); err == nil {
+		t.Error("Expected an error for headers of a plain port")
+	}
+}
+
+func TestAdaptPacket(t *testing.T) {
+	ip, err := adaptPacket(reflect.ValueOf(1), ipType, "a.Out")
+	if err != nil || ip.Interface().(IP).Origin != "a.Out" {
+		t.Errorf("Unexpected envelope %v: %v", ip, err)
+	}
+
+	// Envelopes are not wrapped twice and keep their origin
+	src := &IP{Value: 1, Origin: "b.Out"}
+
+	ptr, err := adaptPacket(reflect.ValueOf(src), ipType, "a.Out")
+	if err != nil || ptr.Interface().(IP).Origin != "b.Out" || !src.Created.IsZero() {
+		t.Errorf("Unexpected envelope %v: %v", ptr, err)
+	}
+
+	v, err := adaptPacket(reflect.ValueOf(src), reflect.TypeOf(0), "")
+	if err != nil || v.Interface() != 1 {
+		t.Errorf("Unexpected value %v: %v", v, err)
+	}
+
+	if s := NewIP(1).SetHeader("b", "2").SetHeader("a", "1").String(); s != "1 [a=1 b=2]" {
+		t.Errorf("Unexpected format '%s'", s)
+	}
+}
@@ -0,0 +1,192 @@
+package goflow
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Start launches a process which has been added to a running network but
+// has been waiting for its ports to be connected. It is useful for processes
+// having ports which are left unconnected but not tagged as optional.
+func (n *Graph) Start(processName string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if !n.pending[processName] {
+		return fmt.Errorf("start: process '%s' is not pending", processName)
+	}
+
+	n.startProc(processName)
+
+	return nil
+}
+
+// startPending starts a process added at run-time if it is still waiting for its ports.
+func (n *Graph) startPending(processName string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.pending[processName] {
+		n.startProc(processName)
+	}
+}
+
+// isStarted tells if a process is running right now, so its ports must not be touched.
+// It must be called with the graph locked.
+func (n *Graph) isStarted(processName string) bool {
+	if !n.running {
+		return false
+	}
+
+	_, started := n.started[processName]
+
+	return started
+}
+
+// startIfReady starts a pending process once all its plain ports which are not
+// optional have channels attached. It must be called with the graph locked.
+func (n *Graph) startIfReady(processName string) {
+	if !n.pending[processName] || !isReady(n.procs[processName]) {
+		return
+	}
+
+	n.startProc(processName)
+}
+
+// isReady tells if all plain channel ports of a process which are not optional are attached.
+func isReady(proc interface{}) bool {
+	val := reflect.ValueOf(proc)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return true
+	}
+
+	if _, isGraph := proc.(*Graph); isGraph {
+		return true
+	}
+
+	val = val.Elem()
+
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if field.Kind() == reflect.Chan && field.CanSet() && field.IsNil() && !isOptionalPort(val.Type().Field(i), field) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isOptionalPort tells if a process can run with a plain port left unconnected,
+// like it does when the network starts. Such ports are tagged `required:"false"`
+// and are usually read only when connected, e.g. config ports having defaults.
+// An Err outport is routed to the dead letters if it is not attached.
+func isOptionalPort(field reflect.StructField, val reflect.Value) bool {
+	return field.Tag.Get("required") == "false" || isErrPort(field, val)
+}
+
+// liveChan returns the channel a new connection must use if any of its ends is a running process.
+// The ports of a running process cannot be reassigned, so the channel it already has is reused.
+func (n *Graph) liveChan(sendPort, recvPort reflect.Value, sendAddr, recvAddr address) (reflect.Value, error) {
+	senderLive := n.isStarted(sendAddr.proc)
+	receiverLive := n.isStarted(recvAddr.proc)
+	sendCur := currentChan(sendPort, sendAddr)
+	recvCur := currentChan(recvPort, recvAddr)
+
+	if senderLive && isNilChan(sendCur) {
+		return sendCur, fmt.Errorf("process '%s' is running and its port is not connected", sendAddr)
+	}
+
+	if receiverLive && isNilChan(recvCur) {
+		return recvCur, fmt.Errorf("process '%s' is running and its port is not connected", recvAddr)
+	}
+
+	if senderLive && receiverLive && sendCur.Pointer() != recvCur.Pointer() {
+		return sendCur, fmt.Errorf("running processes '%s' and '%s' cannot be rewired", sendAddr, recvAddr)
+	}
+
+	// Running processes see directional channels, the graph needs the original ones
+	sendCur = n.bidiChan(sendCur)
+	recvCur = n.bidiChan(recvCur)
+
+	var ch reflect.Value
+
+	switch {
+	case senderLive:
+		ch = sendCur
+		if isNilChan(ch) {
+			return ch, fmt.Errorf("channel of running port '%s' is not known to the graph", sendAddr)
+		}
+
+		if !isNilChan(recvCur) && recvCur.Pointer() != ch.Pointer() {
+			return ch, fmt.Errorf("port '%s' is already connected", recvAddr)
+		}
+	default:
+		ch = recvCur
+		if isNilChan(ch) {
+			return ch, fmt.Errorf("channel of running port '%s' is not known to the graph", recvAddr)
+		}
+
+		if !isNilChan(sendCur) && sendCur.Pointer() != ch.Pointer() {
+			return ch, fmt.Errorf("port '%s' is already connected", sendAddr)
+		}
+
+		// A new sender writes to the running receiver
+		n.incChanListenersCount(ch)
+	}
+
+	return ch, nil
+}
+
+// currentChan returns the channel currently attached to a port address.
+func currentChan(port reflect.Value, addr address) reflect.Value {
+	switch {
+	case addr.index > -1:
+		if port.Kind() != reflect.Slice || port.Len() <= addr.index {
+			return reflect.Value{}
+		}
+
+		return port.Index(addr.index)
+	case addr.key != "":
+		if port.Kind() != reflect.Map || port.IsNil() {
+			return reflect.Value{}
+		}
+
+		return port.MapIndex(reflect.ValueOf(addr.key))
+	default:
+		return port
+	}
+}
+
+// bidiChan finds a bidirectional channel the graph has for a directional one.
+// It returns an invalid value if there is no such channel.
+func (n *Graph) bidiChan(ch reflect.Value) reflect.Value {
+	if isNilChan(ch) || ch.Type().ChanDir() == reflect.BothDir {
+		return ch
+	}
+
+	ptr := ch.Pointer()
+
+	for i := range n.connections {
+		if n.connections[i].channel.Pointer() == ptr {
+			return n.connections[i].channel
+		}
+
+		if from := n.connections[i].sendChan(); from.Pointer() == ptr {
+			return from
+		}
+	}
+
+	for _, ports := range []map[string]port{n.inPorts, n.outPorts} {
+		for _, p := range ports {
+			if !isNilChan(p.channel) && p.channel.Pointer() == ptr && p.channel.Type().ChanDir() == reflect.BothDir {
+				return p.channel
+			}
+		}
+	}
+
+	return reflect.Value{}
+}
+
+func isNilChan(ch reflect.Value) bool {
+	return !ch.IsValid() || ch.IsNil()
+}
@@ -0,0 +1,330 @@
+package goflow
+
+import (
+	"testing"
+)
+
+// startDoubleEcho runs a double echo graph and makes sure it is running by
+// sending the first packet through it.
+func startDoubleEcho(t *testing.T) (n *Graph, in chan int, out chan int, wait Wait) {
+	n, err := newDoubleEcho()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in = make(chan int)
+	out = make(chan int)
+
+	n.SetInPort("In", in)
+	n.SetOutPort("Out", out)
+
+	wait = Run(n)
+
+	in <- 1
+	if i := <-out; i != 1 {
+		t.Fatalf("%d != 1", i)
+	}
+
+	return n, in, out, wait
+}
+
+func TestAddWhileRunning(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Fan-in into the running e2
+	if err := n.Connect("d", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The last unconnected port starts the process
+	if err := n.AddIIP("d", "In", 21); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if i := <-out; i != 42 {
+		t.Errorf("%d != 42", i)
+	}
+
+	// The IIP channel is closed, so d drains and stops
+	if err := n.Remove("d"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, c := range n.connections {
+		if c.src.proc == "d" || c.tgt.proc == "d" {
+			t.Errorf("Connection %s -> %s is not removed", c.src, c.tgt)
+		}
+	}
+
+	go func() {
+		in <- 2
+		in <- 3
+		close(in)
+	}()
+
+	res := []int{}
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	expectInts(t, res, []int{2, 3})
+}
+
+func TestRemovePendingProcess(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("d", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Removing d releases its outport, so e2 still finishes when e1 does
+	if err := n.Remove("d"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	close(in)
+
+	for i := range out {
+		t.Errorf("Unexpected %d", i)
+	}
+
+	<-wait
+}
+
+func TestStartPendingProcess(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	if err := n.Start("e1"); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if err := n.Add("a", new(adder)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("a", "Sum", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.AddIIP("a", "Op1", 5); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Op2 is left unconnected, so the process has to be started explicitly
+	if err := n.Start("a"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	close(in)
+
+	res := []int{}
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	expectInts(t, res, []int{})
+}
+
+func TestStartWithOptionalPorts(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	if err := n.Add("s", new(scaler)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("s", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Factor is optional, so the last required port starts the process
+	if err := n.AddIIP("s", "In", 7); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if i := <-out; i != 7 {
+		t.Errorf("%d != 7", i)
+	}
+
+	if err := n.Start("s"); err == nil {
+		t.Errorf("Expected an error for a started process")
+	}
+
+	close(in)
+
+	for i := range out {
+		t.Errorf("Unexpected %d", i)
+	}
+
+	<-wait
+}
+
+func TestDisconnectFailure(t *testing.T) {
+	n, err := newDoubleEcho()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// A port which cannot be found leaves the connection in place
+	e2 := n.procs["e2"]
+	delete(n.procs, "e2")
+
+	if err := n.Disconnect("e1", "Out", "e2", "In"); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	n.procs["e2"] = e2
+
+	if len(n.connections) != 1 {
+		t.Errorf("Connection is lost: %v", n.connections)
+	}
+
+	if err := n.Disconnect("e1", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+	}
+
+	if len(n.connections) != 0 {
+		t.Errorf("Connection is not removed: %v", n.connections)
+	}
+}
+
+func TestConnectRunningProcessErrors(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cases := []struct {
+		scenario string
+		err      error
+	}{
+		{
+			"Rewiring running processes",
+			n.Connect("e2", "Out", "e1", "In"),
+		},
+		{
+			"Adding a process with a taken name",
+			n.Add("e1", new(echo)),
+		},
+		{
+			"Setting a port of a running process",
+			n.SetInPort("In", make(chan int)),
+		},
+	}
+
+	for _, c := range cases {
+		if c.err == nil {
+			t.Errorf("%s: expected an error", c.scenario)
+		}
+	}
+
+	if err := n.Remove("d"); err != nil {
+		t.Error(err)
+	}
+
+	close(in)
+
+	for range out {
+	}
+
+	<-wait
+}
+
+func TestAddIIPWhileRunning(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	// The packet joins the ones sent by the running e1
+	if err := n.AddIIP("e2", "In", 5); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if i := <-out; i != 5 {
+		t.Errorf("%d != 5", i)
+	}
+
+	if err := n.Add("d", new(doubler)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("d", "Out", "e2", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.AddIIP("d", "In", 21); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if i := <-out; i != 42 {
+		t.Errorf("%d != 42", i)
+	}
+
+	// The channel of the first IIP is closed once it is sent
+	if err := n.AddIIP("d", "In", 1); err == nil {
+		t.Error("Expected an error for a closed port of a running process")
+	}
+
+	close(in)
+
+	res := []int{}
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	expectInts(t, res, []int{})
+}
+
+func TestRemoveWithRunningUpstream(t *testing.T) {
+	n, in, out, wait := startDoubleEcho(t)
+
+	// e1 keeps the inbound channel of e2 open, so e2 would never finish
+	if err := n.Remove("e2"); err == nil {
+		t.Error("Expected an error for removing a process with a running sender")
+	}
+
+	in <- 2
+	if i := <-out; i != 2 {
+		t.Errorf("%d != 2", i)
+	}
+
+	close(in)
+
+	for i := range out {
+		t.Errorf("Unexpected %d", i)
+	}
+
+	<-wait
+}
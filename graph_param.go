@@ -0,0 +1,410 @@
+package goflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Types of graph parameters.
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+	ParamJSON   = "json" // Any JSON value
+)
+
+// Param declares a graph parameter. Parameters are referenced as "${name}" in IIPs
+// and in the "params" metadata of subgraph processes of graph files, e.g.
+//
+//	{"data": "${threshold}", "tgt": {"process": "filter", "port": "Limit"}}
+//
+// References are resolved when the graph starts, so the values can be set after
+// the graph is loaded. An IIP which is a single reference gets the typed value,
+// references within a larger string are replaced with the text of the value.
+type Param struct {
+	Name        string      `json:"-"`
+	Type        string      `json:"type,omitempty"` // ParamString if empty
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// errParamNotSet is returned when a parameter has no value nor default.
+var errParamNotSet = errors.New("parameter is not set")
+
+// paramRef matches parameter references.
+var paramRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+// paramTemplate is IIP data or a subgraph parameter override of a graph file
+// which references parameters. It is resolved when the graph starts.
+type paramTemplate struct {
+	data    json.RawMessage
+	headers map[string]string // Headers of an IIP sent to an IP port
+}
+
+// String returns the template text.
+func (t *paramTemplate) String() string {
+	return string(t.data)
+}
+
+// MarshalJSON encodes the template as it is in the graph file.
+func (t *paramTemplate) MarshalJSON() ([]byte, error) {
+	return t.data, nil
+}
+
+// DeclareParam declares a parameter of the graph. Its default is converted to its type.
+// A parameter without a default must be set before the graph starts unless the parent
+// graph has it.
+func (n *Graph) DeclareParam(p Param) error {
+	if p.Type == "" {
+		p.Type = ParamString
+	}
+
+	if !paramRef.MatchString("${" + p.Name + "}") {
+		return fmt.Errorf("DeclareParam: invalid parameter name '%s'", p.Name)
+	}
+
+	switch p.Type {
+	case ParamString, ParamInt, ParamFloat, ParamBool, ParamJSON:
+	default:
+		return fmt.Errorf("DeclareParam: '%s': unknown parameter type '%s'", p.Name, p.Type)
+	}
+
+	if p.Default != nil {
+		v, err := convertParam(p.Type, p.Default)
+		if err != nil {
+			return fmt.Errorf("DeclareParam: default of '%s': %w", p.Name, err)
+		}
+
+		p.Default = v
+	}
+
+	n.paramLock.Lock()
+	defer n.paramLock.Unlock()
+
+	n.params[p.Name] = p
+
+	return nil
+}
+
+// Params returns the declared parameters sorted by name.
+func (n *Graph) Params() []Param {
+	n.paramLock.Lock()
+	defer n.paramLock.Unlock()
+
+	res := make([]Param, 0, len(n.params))
+	for _, p := range n.params {
+		res = append(res, p)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res
+}
+
+// SetParam sets the value of a declared parameter. Strings are parsed into the type
+// of the parameter, so values from the environment or command line can be set as
+// they are. Subgraphs inherit the value unless they set their own.
+func (n *Graph) SetParam(name string, value interface{}) error {
+	n.paramLock.Lock()
+	defer n.paramLock.Unlock()
+
+	p, ok := n.params[name]
+	if !ok {
+		return fmt.Errorf("SetParam: parameter '%s' is not declared", name)
+	}
+
+	v, err := convertParam(p.Type, value)
+	if err != nil {
+		return fmt.Errorf("SetParam: '%s': %w", name, err)
+	}
+
+	n.paramValues[name] = v
+
+	return nil
+}
+
+// SetParamsFromEnv sets declared parameters from environment variables named by
+// the prefix and the parameter name in upper case with characters other than
+// letters and digits replaced with underscores, e.g. APP_MAX_SIZE for "max-size"
+// with prefix "APP_".
+func (n *Graph) SetParamsFromEnv(prefix string) error {
+	for _, p := range n.Params() {
+		key := prefix + strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToUpper(r)
+			}
+
+			return '_'
+		}, p.Name)
+
+		if value, ok := os.LookupEnv(key); ok {
+			if err := n.SetParam(p.Name, value); err != nil {
+				return fmt.Errorf("SetParamsFromEnv: %s: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Param returns the value of a parameter: the one set for this graph, otherwise
+// the one of the parent graph, otherwise the default.
+func (n *Graph) Param(name string) (interface{}, error) {
+	n.paramLock.Lock()
+	p, declared := n.params[name]
+	v, set := n.paramValues[name]
+	parent := n.paramParent
+	n.paramLock.Unlock()
+
+	var err error
+
+	switch {
+	case set:
+		if t, ok := v.(*paramTemplate); ok {
+			// Overrides of a parent graph file refer to its parameters
+			if parent == nil {
+				return nil, fmt.Errorf("parameter '%s': override without a parent graph", name)
+			}
+
+			if v, err = parent.paramValue(t.data); err != nil {
+				return nil, fmt.Errorf("parameter '%s': %w", name, err)
+			}
+		}
+	case parent != nil:
+		v, err = parent.Param(name)
+		if errors.Is(err, errParamNotSet) && declared && p.Default != nil {
+			return p.Default, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	case declared && p.Default != nil:
+		return p.Default, nil
+	default:
+		return nil, fmt.Errorf("parameter '%s': %w", name, errParamNotSet)
+	}
+
+	if !declared {
+		return v, nil
+	}
+
+	if v, err = convertParam(p.Type, v); err != nil {
+		return nil, fmt.Errorf("parameter '%s': %w", name, err)
+	}
+
+	return v, nil
+}
+
+// paramValue expands a template and decodes it as a JSON value.
+func (n *Graph) paramValue(data json.RawMessage) (interface{}, error) {
+	expanded, err := n.expandParams(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(expanded, &v); err != nil {
+		// Raw text like in FBP
+		return string(expanded), nil
+	}
+
+	return v, nil
+}
+
+// expandParams replaces parameter references in JSON data. Data which is a single
+// reference, quoted or not, is replaced with the JSON value of the parameter.
+func (n *Graph) expandParams(data json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	if m := paramRef.FindSubmatch(trimmed); m != nil {
+		whole := len(m[0]) == len(trimmed)
+		quoted := len(m[0])+2 == len(trimmed) && trimmed[0] == '"' && trimmed[len(trimmed)-1] == '"'
+
+		if whole || quoted {
+			v, err := n.Param(string(m[1]))
+			if err != nil {
+				return nil, err
+			}
+
+			return json.Marshal(v)
+		}
+	}
+
+	var err error
+
+	res := paramRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+		v, e := n.Param(string(ref[2 : len(ref)-1]))
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+
+			return ref
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			text, _ := json.Marshal(v)
+			s = string(text)
+		}
+
+		// Escaped for a JSON string
+		quoted, _ := json.Marshal(s)
+
+		return quoted[1 : len(quoted)-1]
+	})
+
+	return res, err
+}
+
+// convertParam converts a value to a parameter type. Strings are parsed.
+func convertParam(t string, v interface{}) (interface{}, error) {
+	s, isString := v.(string)
+
+	switch t {
+	case ParamString:
+		if isString {
+			return s, nil
+		}
+
+		return fmt.Sprint(v), nil
+	case ParamInt:
+		switch i := v.(type) {
+		case int:
+			return i, nil
+		case int64:
+			return int(i), nil
+		case float64:
+			if i == float64(int(i)) {
+				return int(i), nil
+			}
+		case string:
+			return strconv.Atoi(strings.TrimSpace(i))
+		}
+	case ParamFloat:
+		switch f := v.(type) {
+		case float64:
+			return f, nil
+		case int:
+			return float64(f), nil
+		case int64:
+			return float64(f), nil
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(f), 64)
+		}
+	case ParamBool:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(b))
+		}
+	case ParamJSON:
+		if !isString {
+			return v, nil
+		}
+
+		var res interface{}
+		if err := json.Unmarshal([]byte(s), &res); err != nil {
+			// Plain text is a JSON string
+			return s, nil
+		}
+
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unknown parameter type '%s'", t)
+	}
+
+	return nil, fmt.Errorf("cannot convert %v to %s", v, t)
+}
+
+// setParamParent makes a subgraph inherit the parameters of this graph.
+func (n *Graph) setParamParent(sub *Graph) {
+	sub.paramLock.Lock()
+	defer sub.paramLock.Unlock()
+
+	sub.paramParent = n
+}
+
+// overrideParams sets parameters of a subgraph process and its pool replicas from
+// graph file metadata. Values may refer to parameters of this graph.
+// It must be called with the graph locked.
+func (n *Graph) overrideParams(procName string, values map[string]json.RawMessage) error {
+	for _, proc := range append([]interface{}{n.procs[procName]}, n.pools[procName]...) {
+		sub, ok := proc.(*Graph)
+		if !ok {
+			return fmt.Errorf("process '%s' is not a graph and has no parameters", procName)
+		}
+
+		sub.paramLock.Lock()
+		for name, data := range values {
+			sub.paramValues[name] = &paramTemplate{data: data}
+		}
+		sub.paramLock.Unlock()
+	}
+
+	return nil
+}
+
+// iipData decodes IIP data of a graph file for a process port. Data which refers
+// to parameters is kept as a template until the graph starts.
+func (n *Graph) iipData(procName, portName string, data json.RawMessage, headers map[string]string) (interface{}, error) {
+	if paramRef.Match(data) {
+		return &paramTemplate{data: data, headers: headers}, nil
+	}
+
+	v, err := n.decodeIIP(procName, portName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyHeaders(parseAddress(procName, portName), v, headers)
+}
+
+// applyHeaders sets headers of an IIP which must be an envelope if there are any.
+func applyHeaders(addr address, v interface{}, headers map[string]string) (interface{}, error) {
+	if len(headers) == 0 {
+		return v, nil
+	}
+
+	ip, ok := v.(*IP)
+	if !ok {
+		return nil, fmt.Errorf("IIP for '%s' has headers but the port does not accept IPs", addr)
+	}
+
+	for k, h := range headers {
+		ip.SetHeader(k, h)
+	}
+
+	return ip, nil
+}
+
+// iipValue returns the data of an IIP with parameters resolved.
+func (n *Graph) iipValue(ip iip) (interface{}, error) {
+	t, ok := ip.data.(*paramTemplate)
+	if !ok {
+		return ip.data, nil
+	}
+
+	data, err := n.expandParams(t.data)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := n.decodeIIP(ip.addr.proc, ip.addr.portName(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyHeaders(ip.addr, v, t.headers)
+}
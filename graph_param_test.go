@@ -0,0 +1,223 @@
+package goflow
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var paramGraphJSON = `{
+	"params": {
+		"word": {
+			"type": "string",
+			"default": "hi"
+		},
+		"times": {
+			"type": "int"
+		}
+	},
+	"processes": {
+		"r": {
+			"component": "repeater"
+		}
+	},
+	"connections": [
+		{
+			"data": "${word}!",
+			"tgt": {
+				"process": "r",
+				"port": "Word"
+			}
+		},
+		{
+			"data": "${times}",
+			"tgt": {
+				"process": "r",
+				"port": "Times"
+			}
+		}
+	],
+	"outports": {
+		"Words": {
+			"process": "r",
+			"port": "Words"
+		}
+	}
+}`
+
+// runWords runs a graph with a Words outport until it finishes.
+func runWords(n *Graph) []string {
+	out := make(chan string)
+	if err := n.SetOutPort("Words", out); err != nil {
+		return []string{err.Error()}
+	}
+
+	wait := Run(n)
+
+	var res []string
+	for w := range out {
+		res = append(res, w)
+	}
+
+	<-wait
+
+	return res
+}
+
+func TestParamIIP(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := ParseJSON([]byte(paramGraphJSON), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Times has no default
+	if err := n.Validate(); err == nil || !strings.Contains(err.Error(), "parameter 'times'") {
+		t.Errorf("Expected an error for a parameter which is not set, got %v", err)
+	}
+
+	// Values from the command line or environment are parsed
+	if err := n.SetParam("times", "2"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Validate(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if words := runWords(n); !reflect.DeepEqual(words, []string{"hi!", "hi!"}) {
+		t.Errorf("%v != [hi! hi!]", words)
+	}
+
+	if err := n.SetParam("nope", 1); err == nil {
+		t.Error("Expected an error for a parameter which is not declared")
+	}
+
+	if err := n.SetParam("times", "many"); err == nil {
+		t.Error("Expected an error for a value of a wrong type")
+	}
+}
+
+func TestParamEnv(t *testing.T) {
+	n := NewGraph()
+
+	for _, p := range []Param{{Name: "max-size", Type: ParamInt, Default: 1}, {Name: "verbose", Type: ParamBool}} {
+		if err := n.DeclareParam(p); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	os.Setenv("TEST_MAX_SIZE", "10")
+	defer os.Unsetenv("TEST_MAX_SIZE")
+
+	if err := n.SetParamsFromEnv("TEST_"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if v, err := n.Param("max-size"); err != nil || v != 10 {
+		t.Errorf("Unexpected value %v: %v", v, err)
+	}
+
+	if _, err := n.Param("verbose"); err == nil {
+		t.Error("Expected an error for a parameter which is not set")
+	}
+}
+
+var paramSubgraphJSON = `{
+	"params": {
+		"word": {
+			"default": "hello"
+		},
+		"count": {
+			"type": "int",
+			"default": 1
+		}
+	},
+	"processes": {
+		"s": {
+			"component": "words",
+			"metadata": {
+				"params": {
+					"times": "${count}"
+				}
+			}
+		}
+	},
+	"outports": {
+		"Words": {
+			"process": "s",
+			"port": "Words"
+		}
+	}
+}`
+
+func TestParamSubgraph(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The subgraph declares the parameters with its own defaults
+	sub := strings.Replace(paramGraphJSON, `"type": "int"`, `"type": "int", "default": 5`, 1)
+	if err := f.registerGraph("words", "words.json", []byte(sub)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := ParseJSON([]byte(paramSubgraphJSON), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetParam("count", 3); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Word is inherited, times is overridden in metadata
+	if words := runWords(n); !reflect.DeepEqual(words, []string{"hello!", "hello!", "hello!"}) {
+		t.Errorf("%v != [hello! hello! hello!]", words)
+	}
+
+	if _, err := ParseJSON([]byte(strings.Replace(paramSubgraphJSON, `"component": "words"`, `"component": "echo"`, 1)), f); err == nil {
+		t.Error("Expected an error for parameters of a process which is not a graph")
+	}
+}
+
+func TestConvertParam(t *testing.T) {
+	cases := []struct {
+		typ  string
+		in   interface{}
+		out  interface{}
+		fail bool
+	}{
+		{ParamString, 5, "5", false},
+		{ParamInt, 5.0, 5, false},
+		{ParamInt, 5.5, nil, true},
+		{ParamFloat, "0.5", 0.5, false},
+		{ParamBool, "true", true, false},
+		{ParamJSON, `{"a": 1}`, map[string]interface{}{"a": 1.0}, false},
+		{ParamJSON, "text", "text", false},
+		{"date", "", nil, true},
+	}
+
+	for _, c := range cases {
+		v, err := convertParam(c.typ, c.in)
+		if (err != nil) != c.fail || (!c.fail && !reflect.DeepEqual(v, c.out)) {
+			t.Errorf("convertParam(%s, %v) = %v, %v", c.typ, c.in, v, err)
+		}
+	}
+}
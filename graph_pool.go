@@ -0,0 +1,123 @@
+package goflow
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddPool adds a process which runs size replicas of a component in parallel.
+// Every replica is a fresh instance created by the constructor. The replicas
+// share the same inbound and outbound channels, so a packet sent to the process
+// is handled by exactly one of them. It is meant for stateless components.
+func (n *Graph) AddPool(name string, size int, constructor Constructor) error {
+	if size < 1 {
+		return fmt.Errorf("could not add pool '%s': invalid size %d", name, size)
+	}
+
+	replicas := make([]interface{}, size)
+
+	for i := range replicas {
+		c, err := constructor()
+		if err != nil {
+			return fmt.Errorf("could not add pool '%s': %w", name, err)
+		}
+
+		replicas[i] = c
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	// The pool must not replace the replicas of an existing one unless it is added
+	if err := n.checkAdd(name, replicas[0]); err != nil {
+		return err
+	}
+
+	if size > 1 {
+		n.pools[name] = replicas[1:]
+	} else {
+		delete(n.pools, name)
+	}
+
+	for _, r := range replicas {
+		if sub, ok := r.(*Graph); ok {
+			n.setParamParent(sub)
+		}
+	}
+
+	// The first replica is used as a prototype for connections
+	return n.add(name, replicas[0])
+}
+
+// AddNewPool creates a pool of process replicas using component factory and adds it to the network.
+func (n *Graph) AddNewPool(processName, componentName string, size int, f *Factory) error {
+	ref, err := f.resolveRef(componentName)
+	if err != nil {
+		return err
+	}
+
+	err = n.AddPool(processName, size, func() (interface{}, error) {
+		return f.Create(ref)
+	})
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	n.components[processName] = ref
+	n.factory = f
+	n.lock.Unlock()
+
+	return nil
+}
+
+// PoolSize returns the number of replicas running for a process.
+func (n *Graph) PoolSize(processName string) int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, exists := n.procs[processName]; !exists {
+		return 0
+	}
+
+	return len(n.pools[processName]) + 1
+}
+
+// preparePool copies ports of the prototype process to the rest of the pool replicas.
+// Outbound channels get an extra listener per replica, so they are closed only
+// after the last replica has finished.
+func (n *Graph) preparePool(name string) []Component {
+	replicas := n.pools[name]
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	ports := savePorts(n.procs[name])
+	res := make([]Component, 0, len(replicas))
+
+	// Channels attached from outside, e.g. by a parent graph or SetOutPort,
+	// are not counted yet, so the prototype is counted first
+	proto := reflect.ValueOf(n.procs[name]).Elem()
+	for i := 0; i < proto.NumField(); i++ {
+		if isOutChan(proto.Field(i)) && !proto.Field(i).IsNil() && n.chanListenersCountOf(proto.Field(i)) == 0 {
+			n.incChanListenersCount(proto.Field(i))
+		}
+	}
+
+	for _, r := range replicas {
+		restorePorts(r, ports)
+
+		val := reflect.ValueOf(r).Elem()
+		for i := 0; i < val.NumField(); i++ {
+			if isOutChan(val.Field(i)) && !val.Field(i).IsNil() {
+				n.incChanListenersCount(val.Field(i))
+			}
+		}
+
+		if c, ok := r.(Component); ok {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
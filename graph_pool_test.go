@@ -0,0 +1,89 @@
+package goflow
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestAddPool(t *testing.T) {
+	n := NewGraph()
+
+	created := 0
+	err := n.AddPool("d", 4, func() (interface{}, error) {
+		created++
+		return new(doubler), nil
+	})
+
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if created != 4 {
+		t.Errorf("%d != 4", created)
+	}
+
+	if size := n.PoolSize("d"); size != 4 {
+		t.Errorf("%d != 4", size)
+	}
+
+	if err := n.Add("e", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("d", "Out", "e", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "d", "In")
+	n.MapOutPort("Out", "e", "Out")
+
+	res := runIntGraph(n, []int{1, 2, 3, 4, 5, 6, 7})
+
+	sort.Ints(res)
+	expectInts(t, res, []int{2, 4, 6, 8, 10, 12, 14})
+}
+
+func TestAddInvalidPool(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.AddPool("d", 0, func() (interface{}, error) {
+		return new(doubler), nil
+	}); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	f := NewFactory()
+
+	if err := n.AddNewPool("d", "notfound", 2, f); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if size := n.PoolSize("d"); size != 0 {
+		t.Errorf("%d != 0", size)
+	}
+}
+
+func TestAddPoolKeepsExisting(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.AddPool("d", 3, func() (interface{}, error) {
+		return new(doubler), nil
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// A failed pool under the same name does not affect the existing one
+	if err := n.AddPool("d", 2, func() (interface{}, error) {
+		return new(int), nil
+	}); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if size := n.PoolSize("d"); size != 3 {
+		t.Errorf("%d != 3", size)
+	}
+}
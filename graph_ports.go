@@ -3,6 +3,8 @@ package goflow
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 // port within the network.
@@ -13,9 +15,14 @@ type port struct {
 }
 
 // MapInPort adds an inport to the net and maps it to a contained proc's port.
+// Port names defined in UPPER or lower case are converted to Title case like
+// component port names.
 func (n *Graph) MapInPort(name, procName, procPort string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	addr := parseAddress(procName, procPort)
-	n.inPorts[name] = port{addr: addr}
+	n.inPorts[capitalizePortName(name)] = port{addr: addr}
 }
 
 // // AnnotateInPort sets optional run-time annotation for the port utilized by
@@ -29,19 +36,29 @@ func (n *Graph) MapInPort(name, procName, procPort string) {
 // 	return true
 // }
 
-// // UnmapInPort removes an existing inport mapping
-// func (n *Graph) UnmapInPort(name string) bool {
-// 	if _, exists := n.inPorts[name]; !exists {
-// 		return false
-// 	}
-// 	delete(n.inPorts, name)
-// 	return true
-// }
+// UnmapInPort removes an existing inport mapping.
+func (n *Graph) UnmapInPort(name string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	name = capitalizePortName(name)
+
+	if _, exists := n.inPorts[name]; !exists {
+		return fmt.Errorf("UnmapInPort: in port '%s' not defined", name)
+	}
+
+	delete(n.inPorts, name)
+
+	return nil
+}
 
 // MapOutPort adds an outport to the net and maps it to a contained proc's port.
 func (n *Graph) MapOutPort(name, procName, procPort string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	addr := parseAddress(procName, procPort)
-	n.outPorts[name] = port{addr: addr}
+	n.outPorts[capitalizePortName(name)] = port{addr: addr}
 }
 
 // // AnnotateOutPort sets optional run-time annotation for the port utilized by
@@ -55,14 +72,21 @@ func (n *Graph) MapOutPort(name, procName, procPort string) {
 // 	return true
 // }
 
-// // UnmapOutPort removes an existing outport mapping
-// func (n *Graph) UnmapOutPort(name string) bool {
-// 	if _, exists := n.outPorts[name]; !exists {
-// 		return false
-// 	}
-// 	delete(n.outPorts, name)
-// 	return true
-// }
+// UnmapOutPort removes an existing outport mapping.
+func (n *Graph) UnmapOutPort(name string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	name = capitalizePortName(name)
+
+	if _, exists := n.outPorts[name]; !exists {
+		return fmt.Errorf("UnmapOutPort: out port '%s' not defined", name)
+	}
+
+	delete(n.outPorts, name)
+
+	return nil
+}
 
 // SetInPort assigns a channel to a network's inport to talk to the outer world.
 func (n *Graph) SetInPort(name string, channel interface{}) error {
@@ -76,6 +100,9 @@ func (n *Graph) SetOutPort(name string, channel interface{}) error {
 }
 
 func (n *Graph) setGraphPort(name string, channel interface{}, dir reflect.ChanDir) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	var (
 		ports    map[string]port
 		dirDescr string
@@ -89,7 +116,13 @@ func (n *Graph) setGraphPort(name string, channel interface{}, dir reflect.ChanD
 		dirDescr = "in"
 	}
 
+	name = capitalizePortName(name)
+
 	p, ok := ports[name]
+	if !ok && dir == reflect.SendDir && name == DeadLetterPort {
+		return n.setDeadLetterChan(channel)
+	}
+
 	if !ok {
 		return fmt.Errorf("setGraphPort: %s port '%s' not defined", dirDescr, name)
 	}
@@ -100,6 +133,10 @@ func (n *Graph) setGraphPort(name string, channel interface{}, dir reflect.ChanD
 		return fmt.Errorf("setGraphPort: cannot set %s port '%s': %w", dirDescr, name, err)
 	}
 
+	if n.isStarted(p.addr.proc) {
+		return fmt.Errorf("setGraphPort: cannot set %s port '%s': process '%s' is running", dirDescr, name, p.addr.proc)
+	}
+
 	if _, err = attachPort(port, p.addr, dir, reflect.ValueOf(channel), n.conf.BufferSize); err != nil {
 		return fmt.Errorf("setGraphPort: cannot attach %s port '%s': %w", dirDescr, name, err)
 	}
@@ -108,6 +145,10 @@ func (n *Graph) setGraphPort(name string, channel interface{}, dir reflect.ChanD
 	p.channel = reflect.ValueOf(channel)
 	ports[name] = p
 
+	if n.running {
+		n.startIfReady(p.addr.proc)
+	}
+
 	return nil
 }
 
@@ -156,3 +197,176 @@ func (n *Graph) setGraphPort(name string, channel interface{}, dir reflect.ChanD
 // 	delete(n.outPorts, name)
 // 	return true
 // }
+
+// inPort returns a graph inport by name.
+func (n *Graph) inPort(name string) (port, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	p, ok := n.inPorts[capitalizePortName(name)]
+
+	return p, ok
+}
+
+// outPort returns a graph outport by name.
+func (n *Graph) outPort(name string) (port, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	p, ok := n.outPorts[capitalizePortName(name)]
+
+	return p, ok
+}
+
+// InPortNames returns sorted names of the graph inports.
+func (n *Graph) InPortNames() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return sortedPortNames(n.inPorts)
+}
+
+// OutPortNames returns sorted names of the graph outports.
+func (n *Graph) OutPortNames() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return sortedPortNames(n.outPorts)
+}
+
+func sortedPortNames(ports map[string]port) []string {
+	res := make([]string, 0, len(ports))
+	for name := range ports {
+		res = append(res, name)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// InPortType returns the type of packets accepted by a graph inport.
+func (n *Graph) InPortType(name string) (reflect.Type, error) {
+	return n.graphPortType(name, reflect.RecvDir)
+}
+
+// OutPortType returns the type of packets sent by a graph outport.
+func (n *Graph) OutPortType(name string) (reflect.Type, error) {
+	return n.graphPortType(name, reflect.SendDir)
+}
+
+func (n *Graph) graphPortType(name string, dir reflect.ChanDir) (reflect.Type, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	ports, dirDescr := n.inPorts, "in"
+	if dir == reflect.SendDir {
+		ports, dirDescr = n.outPorts, "out"
+	}
+
+	p, ok := ports[capitalizePortName(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s port '%s' not defined", dirDescr, name)
+	}
+
+	procPort, err := n.getProcPort(p.addr.proc, p.addr.port, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := portChanType(procPort.Type())
+	if t == nil {
+		return nil, fmt.Errorf("%s port '%s' is not a channel", dirDescr, name)
+	}
+
+	return t.Elem(), nil
+}
+
+// Validate checks that every plain port of the processes is either connected,
+// exported or receives an IIP, so no process waits forever for a channel which
+// is never attached. Array and map ports are optional by nature and are not checked,
+// neither are ports tagged `required:"false"`, which components read only when
+// they are connected, and Err outports which are routed to the dead letters of the graph.
+// Parameters referenced by IIPs must be set. Subgraphs are validated recursively.
+func (n *Graph) Validate() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	var problems []string
+
+	for _, name := range sortedProcNames(n.procs) {
+		if sub, ok := n.procs[name].(*Graph); ok {
+			if err := sub.Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("subgraph '%s': %s", name, err))
+			}
+
+			continue
+		}
+
+		val := reflect.ValueOf(n.procs[name])
+		if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+			continue
+		}
+
+		val = val.Elem()
+
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Field(i)
+			if field.Kind() != reflect.Chan || !field.CanSet() || !field.IsNil() || isOptionalPort(val.Type().Field(i), field) {
+				continue
+			}
+
+			addr := address{proc: name, port: val.Type().Field(i).Name, index: -1}
+			if !n.isExported(addr) && !n.hasIIP(addr) {
+				problems = append(problems, fmt.Sprintf("port '%s' is not connected", addr))
+			}
+		}
+	}
+
+	for _, ip := range n.iips {
+		if _, err := n.iipValue(ip); err != nil {
+			problems = append(problems, fmt.Sprintf("IIP for '%s': %s", ip.addr, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("validate: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// isExported tells if a process port is mapped to a graph port.
+func (n *Graph) isExported(addr address) bool {
+	for _, ports := range []map[string]port{n.inPorts, n.outPorts} {
+		for _, p := range ports {
+			if p.addr == addr {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hasIIP tells if a process port receives an IIP.
+func (n *Graph) hasIIP(addr address) bool {
+	for _, ip := range n.iips {
+		if ip.addr == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedProcNames(procs map[string]interface{}) []string {
+	res := make([]string, 0, len(procs))
+	for name := range procs {
+		res = append(res, name)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
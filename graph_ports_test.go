@@ -1,6 +1,10 @@
 package goflow
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestOutportNotFound(t *testing.T) {
 	sub, err := newDoubleEcho()
@@ -63,3 +67,82 @@ func TestSetMissingProcPorts(t *testing.T) {
 		return
 	}
 }
+
+func TestValidate(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	f.Register("scaler", func() (interface{}, error) {
+		return new(scaler), nil
+	})
+
+	n, err := ParseFBP([]byte("INPORT=e.IN:IN\n'hi' -> WORD r(repeater)\nd(doubler) OUT -> IN e(echo)\n'3' -> IN s(scaler) OUT -> IN e\n"), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n.Validate()
+	if err == nil {
+		t.Error("Expected an error")
+		return
+	}
+
+	for _, port := range []string{"r.Times", "r.Words", "d.In", "e.Out"} {
+		if !strings.Contains(err.Error(), port) {
+			t.Errorf("'%s' is not reported in: %s", port, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "e.In") || strings.Contains(err.Error(), "r.Word'") {
+		t.Errorf("Connected ports are reported: %s", err)
+	}
+
+	if strings.Contains(err.Error(), "s.Factor") {
+		t.Errorf("Optional ports are reported: %s", err)
+	}
+
+	n.MapOutPort("Out", "e", "Out")
+	n.MapOutPort("Words", "r", "Words")
+
+	if err := n.AddIIP("r", "Times", 2); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.AddIIP("d", "In", 1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGraphPortTypes(t *testing.T) {
+	n, err := newDoubleEcho()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if names := n.InPortNames(); len(names) != 1 || names[0] != "In" {
+		t.Errorf("Unexpected inports %v", names)
+	}
+
+	if typ, err := n.InPortType("In"); err != nil || typ.Kind() != reflect.Int {
+		t.Errorf("Unexpected inport type %v: %v", typ, err)
+	}
+
+	if typ, err := n.OutPortType("Out"); err != nil || typ.Kind() != reflect.Int {
+		t.Errorf("Unexpected outport type %v: %v", typ, err)
+	}
+
+	if _, err := n.OutPortType("Nope"); err == nil {
+		t.Error("Expected an error")
+	}
+}
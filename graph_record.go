@@ -0,0 +1,302 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of recorded events.
+const (
+	RecordOpen  = "open"  // Recording of a port has started
+	RecordData  = "data"  // A packet has been sent to a port
+	RecordClose = "close" // A port has been closed
+)
+
+// RecordEvent is a packet or a port event captured by a Recorder.
+type RecordEvent struct {
+	Time   time.Time   `json:"time"`
+	Port   string      `json:"port"` // Receiving process port like "proc.In[1]" or graph port name
+	Edge   string      `json:"edge"` // Connection description like "a.Out -> b.In"
+	Kind   string      `json:"kind"` // RecordOpen, RecordData or RecordClose
+	Packet interface{} `json:"packet,omitempty"`
+}
+
+// RecordEncoder writes recorded events.
+type RecordEncoder interface {
+	Encode(e RecordEvent) error
+}
+
+// RecordDecoder reads recorded events. It returns io.EOF when there are no more events.
+type RecordDecoder interface {
+	Decode(e *RecordEvent) error
+}
+
+// RecordCodec defines the format of recordings.
+type RecordCodec interface {
+	NewEncoder(w io.Writer) RecordEncoder
+	NewDecoder(r io.Reader) RecordDecoder
+}
+
+// JSONCodec stores recordings as JSON lines. Decoded packets are json.RawMessage
+// which are converted to the port type when replayed.
+var JSONCodec RecordCodec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) RecordEncoder {
+	return jsonEncoder{json.NewEncoder(w)}
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) RecordDecoder {
+	return jsonDecoder{json.NewDecoder(r)}
+}
+
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e jsonEncoder) Encode(event RecordEvent) error {
+	return e.enc.Encode(event)
+}
+
+type jsonDecoder struct {
+	dec *json.Decoder
+}
+
+func (d jsonDecoder) Decode(event *RecordEvent) error {
+	var raw struct {
+		RecordEvent
+		Packet json.RawMessage `json:"packet"`
+	}
+
+	if err := d.dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	*event = raw.RecordEvent
+	event.Packet = nil
+
+	if len(raw.Packet) > 0 {
+		event.Packet = raw.Packet
+	}
+
+	return nil
+}
+
+// Recorder writes packets passing through graph ports. IP envelopes are recorded
+// with their headers, origin and lineage.
+type Recorder struct {
+	enc  RecordEncoder
+	lock sync.Locker
+	err  error
+	taps *sync.WaitGroup
+	now  func() time.Time
+}
+
+// NewRecorder creates a recorder writing events to w in a format of the codec.
+func NewRecorder(w io.Writer, codec RecordCodec) *Recorder {
+	return &Recorder{
+		enc:  codec.NewEncoder(w),
+		lock: new(sync.Mutex),
+		taps: new(sync.WaitGroup),
+		now:  time.Now,
+	}
+}
+
+// Wait waits until all recorded ports are closed and their events are written.
+// It returns the first error which has occurred while writing events.
+func (r *Recorder) Wait() error {
+	r.taps.Wait()
+
+	return r.Err()
+}
+
+// Err returns the first error which has occurred while writing events.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) write(port, edge, kind string, packet interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	r.err = r.enc.Encode(RecordEvent{
+		Time:   r.now(),
+		Port:   port,
+		Edge:   edge,
+		Kind:   kind,
+		Packet: packet,
+	})
+}
+
+// tap relays packets from one channel to another recording them.
+// The destination is closed when the source is closed.
+func (r *Recorder) tap(port, edge string, from, to reflect.Value) {
+	r.write(port, edge, RecordOpen, nil)
+	r.taps.Add(1)
+
+	go func() {
+		defer r.taps.Done()
+
+		for {
+			v, ok := from.Recv()
+			if !ok {
+				r.write(port, edge, RecordClose, nil)
+				to.Close()
+
+				return
+			}
+
+			r.write(port, edge, RecordData, v.Interface())
+			to.Send(v)
+		}
+	}()
+}
+
+// Record attaches a recorder to ports of the graph. Ports are the receiving
+// process ports of connections, e.g. "proc.In" or "proc.In[1]", and names of
+// graph inports and outports. If no ports are given, all connections and graph
+// ports are recorded. Ports must be connected and set before recording, and the
+// graph must not be running.
+func (n *Graph) Record(r *Recorder, ports ...string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.running {
+		return fmt.Errorf("record: graph is running")
+	}
+
+	all := len(ports) == 0
+	if all {
+		ports = n.recordablePorts()
+	}
+
+	for _, name := range ports {
+		if err := n.recordPort(r, name, !all); err != nil {
+			return fmt.Errorf("record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// recordablePorts lists the receiving ports of connections and the graph ports
+// which have been set. It must be called with the graph locked.
+func (n *Graph) recordablePorts() []string {
+	seen := make(map[string]bool)
+
+	var res []string
+
+	for _, c := range n.connections {
+		name := c.tgt.proc + "." + endpointOf(c.tgt).portName()
+		if !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+
+	sort.Strings(res)
+
+	for _, ports := range []map[string]port{n.inPorts, n.outPorts} {
+		for _, name := range sortedPortNames(ports) {
+			if !isNilChan(ports[name].channel) {
+				res = append(res, name)
+			}
+		}
+	}
+
+	return res
+}
+
+// recordPort inserts a recording relay in front of a port. It must be called with the graph locked.
+func (n *Graph) recordPort(r *Recorder, name string, strict bool) error {
+	if p, ok := n.inPorts[capitalizePortName(name)]; ok && !strings.Contains(name, ".") {
+		return n.recordGraphPort(r, name, p, reflect.RecvDir)
+	}
+
+	if p, ok := n.outPorts[capitalizePortName(name)]; ok && !strings.Contains(name, ".") {
+		return n.recordGraphPort(r, name, p, reflect.SendDir)
+	}
+
+	dot := strings.Index(name, ".")
+	if dot < 0 {
+		return fmt.Errorf("port '%s' not found", name)
+	}
+
+	addr := parseAddress(name[:dot], name[dot+1:])
+
+	var senders []string
+
+	var ch reflect.Value
+
+	for _, c := range n.connections {
+		if c.tgt == addr {
+			senders = append(senders, c.src.proc+"."+endpointOf(c.src).portName())
+			ch = c.channel
+		}
+	}
+
+	if len(senders) == 0 {
+		if strict {
+			return fmt.Errorf("port '%s' is not connected", name)
+		}
+
+		return nil
+	}
+
+	port, err := n.getProcPort(addr.proc, addr.port, reflect.RecvDir)
+	if err != nil {
+		return err
+	}
+
+	relay := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, ch.Type().Elem()), 0)
+	if _, err := attachPort(port, addr, reflect.RecvDir, relay, 0); err != nil {
+		return err
+	}
+
+	recv := addr.proc + "." + endpointOf(addr).portName()
+	r.tap(recv, strings.Join(senders, ", ")+" -> "+recv, ch, relay)
+
+	return nil
+}
+
+// recordGraphPort inserts a recording relay between a graph port and its process.
+func (n *Graph) recordGraphPort(r *Recorder, name string, p port, dir reflect.ChanDir) error {
+	if isNilChan(p.channel) {
+		return fmt.Errorf("port '%s' is not set", name)
+	}
+
+	port, err := n.getProcPort(p.addr.proc, p.addr.port, dir)
+	if err != nil {
+		return err
+	}
+
+	relay := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, p.channel.Type().Elem()), 0)
+	if _, err := attachPort(port, p.addr, dir, relay, 0); err != nil {
+		return err
+	}
+
+	name = capitalizePortName(name)
+	proc := p.addr.proc + "." + endpointOf(p.addr).portName()
+
+	if dir == reflect.SendDir {
+		r.tap(name, proc+" -> "+name, relay, p.channel)
+	} else {
+		r.tap(name, name+" -> "+proc, p.channel, relay)
+	}
+
+	return nil
+}
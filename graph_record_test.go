@@ -0,0 +1,158 @@
+package goflow
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"testing"
+)
+
+// recordFanOutFanIn runs a fan-out/fan-in graph recording the given ports.
+func recordFanOutFanIn(w io.Writer, codec RecordCodec, input []int, ports ...string) ([]int, error) {
+	n, err := newFanOutFanIn()
+	if err != nil {
+		return nil, err
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		return nil, err
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		return nil, err
+	}
+
+	r := NewRecorder(w, codec)
+	if err := n.Record(r, ports...); err != nil {
+		return nil, err
+	}
+
+	wait := Run(n)
+
+	go func() {
+		for _, i := range input {
+			in <- i
+		}
+
+		close(in)
+	}()
+
+	var res []int
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	return res, r.Wait()
+}
+
+func decodeEvents(t *testing.T, data []byte) []RecordEvent {
+	dec := JSONCodec.NewDecoder(bytes.NewReader(data))
+
+	var res []RecordEvent
+
+	for {
+		var e RecordEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			return res
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		res = append(res, e)
+	}
+}
+
+func TestRecordAll(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, err := recordFanOutFanIn(&buf, JSONCodec, []int{1, 2, 3})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sort.Ints(out)
+
+	if len(out) != 3 || out[0] != 2 || out[2] != 6 {
+		t.Errorf("Unexpected output %v", out)
+	}
+
+	counts := make(map[string]int)
+	edges := make(map[string]string)
+
+	for _, e := range decodeEvents(t, buf.Bytes()) {
+		counts[e.Port+" "+e.Kind]++
+		edges[e.Port] = e.Edge
+
+		if e.Time.IsZero() {
+			t.Errorf("Event without time: %+v", e)
+		}
+	}
+
+	for key, expected := range map[string]int{
+		"In open":    1,
+		"In data":    3,
+		"In close":   1,
+		"e2.In data": 3,
+		"Out data":   3,
+		"Out close":  1,
+	} {
+		if counts[key] != expected {
+			t.Errorf("%s: %d != %d", key, counts[key], expected)
+		}
+	}
+
+	if counts["d1.In data"]+counts["d2.In data"]+counts["d3.In data"] != 3 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+
+	for port, expected := range map[string]string{
+		"In":    "In -> e1.In",
+		"Out":   "e2.Out -> Out",
+		"d2.In": "e1.Out -> d2.In",
+		"e2.In": "d1.Out, d2.Out, d3.Out -> e2.In",
+	} {
+		if edges[port] != expected {
+			t.Errorf("%s: %s != %s", port, edges[port], expected)
+		}
+	}
+}
+
+func TestRecordPorts(t *testing.T) {
+	var buf bytes.Buffer
+
+	if _, err := recordFanOutFanIn(&buf, JSONCodec, []int{1, 2}, "e2.In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	events := decodeEvents(t, buf.Bytes())
+	if len(events) != 4 || events[0].Kind != RecordOpen || events[3].Kind != RecordClose {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	for _, e := range events {
+		if e.Port != "e2.In" {
+			t.Errorf("Unexpected port %s", e.Port)
+		}
+	}
+
+	n, err := newFanOutFanIn()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Record(NewRecorder(&buf, JSONCodec), "e1.Out"); err == nil {
+		t.Error("Expected an error for a port which is not a receiver")
+	}
+
+	if err := n.Record(NewRecorder(&buf, JSONCodec), "In"); err == nil {
+		t.Error("Expected an error for a graph port which is not set")
+	}
+}
@@ -0,0 +1,219 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replayer feeds recorded packets into ports to reproduce a recorded run.
+type Replayer struct {
+	events []RecordEvent
+	speed  float64
+	lock   sync.Locker
+	err    error
+	done   chan struct{}
+}
+
+// NewReplayer reads a recording in a format of the codec.
+func NewReplayer(r io.Reader, codec RecordCodec) (*Replayer, error) {
+	dec := codec.NewDecoder(r)
+	p := &Replayer{
+		lock: new(sync.Mutex),
+		done: make(chan struct{}),
+	}
+
+	for {
+		var e RecordEvent
+
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+
+		p.events = append(p.events, e)
+	}
+
+	return p, nil
+}
+
+// SetSpeed makes the replayer reproduce the delays between events: 1 is the
+// recorded speed, 2 is twice as fast. By default events are replayed without delays.
+func (p *Replayer) SetSpeed(speed float64) {
+	p.speed = speed
+}
+
+// Done returns a channel which is closed when all events have been replayed.
+func (p *Replayer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns the first error which has occurred while replaying, e.g. a packet
+// which cannot be converted to the port type.
+func (p *Replayer) Err() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.err
+}
+
+// ReplayGraph sets the graph inports which have been recorded and feeds them with
+// the recorded packets in the recorded order once the graph runs.
+// It must be called before the graph is run.
+func (p *Replayer) ReplayGraph(n *Graph) error {
+	chans := make(map[string]reflect.Value)
+
+	for _, e := range p.events {
+		if strings.Contains(e.Port, ".") || chans[e.Port].IsValid() {
+			continue
+		}
+
+		t, err := n.InPortType(e.Port)
+		if err != nil {
+			continue // Recorded graph outport
+		}
+
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t), 0)
+		if err := n.SetInPort(e.Port, ch.Interface()); err != nil {
+			return fmt.Errorf("replay: %w", err)
+		}
+
+		chans[e.Port] = ch
+	}
+
+	go p.replay(chans)
+
+	return nil
+}
+
+// ReplayProcess attaches channels to the ports of a component which have been
+// recorded for a process with a given name, e.g. events of "proc.In" are replayed
+// to the In port of the component. The component should be run afterwards.
+func (p *Replayer) ReplayProcess(processName string, c interface{}) error {
+	val := reflect.ValueOf(c)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("replay: process '%s' is not a pointer to a struct", processName)
+	}
+
+	chans := make(map[string]reflect.Value)
+	prefix := processName + "."
+
+	for _, e := range p.events {
+		if !strings.HasPrefix(e.Port, prefix) || chans[e.Port].IsValid() {
+			continue
+		}
+
+		addr := parseAddress(processName, e.Port[len(prefix):])
+
+		port := val.Elem().FieldByName(addr.port)
+		if !port.IsValid() {
+			return fmt.Errorf("replay: process '%s' does not have port '%s'", processName, addr.port)
+		}
+
+		t := portChanType(port.Type())
+		if t == nil {
+			return fmt.Errorf("replay: port '%s' is not a channel", addr)
+		}
+
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t.Elem()), 0)
+		if _, err := attachPort(port, addr, reflect.RecvDir, ch, 0); err != nil {
+			return fmt.Errorf("replay: port '%s': %w", addr, err)
+		}
+
+		chans[e.Port] = ch
+	}
+
+	go p.replay(chans)
+
+	return nil
+}
+
+// replay sends the events to the channels by port name. Channels which
+// have not been closed by an event are closed at the end.
+func (p *Replayer) replay(chans map[string]reflect.Value) {
+	defer close(p.done)
+
+	closed := make(map[string]bool)
+
+	var last time.Time
+
+	for _, e := range p.events {
+		ch, ok := chans[e.Port]
+		if !ok || closed[e.Port] {
+			continue
+		}
+
+		if p.speed > 0 && !last.IsZero() && e.Time.After(last) {
+			time.Sleep(time.Duration(float64(e.Time.Sub(last)) / p.speed))
+		}
+
+		last = e.Time
+
+		switch e.Kind {
+		case RecordData:
+			v, err := replayPacket(e.Packet, ch.Type().Elem())
+			if err != nil {
+				p.fail(fmt.Errorf("replay: port '%s': %w", e.Port, err))
+				continue
+			}
+
+			ch.Send(v)
+		case RecordClose:
+			ch.Close()
+
+			closed[e.Port] = true
+		}
+	}
+
+	for name, ch := range chans {
+		if !closed[name] {
+			ch.Close()
+		}
+	}
+}
+
+func (p *Replayer) fail(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// replayPacket converts a recorded packet to the port type.
+func replayPacket(packet interface{}, t reflect.Type) (reflect.Value, error) {
+	if raw, ok := packet.(json.RawMessage); ok {
+		v := reflect.New(t)
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return v, err
+		}
+
+		return v.Elem(), nil
+	}
+
+	if packet == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(packet)
+
+	switch {
+	case v.Type().AssignableTo(t):
+		return v, nil
+	case needsAdapter(v.Type(), t):
+		return adaptPacket(v, t, "")
+	case v.Type().ConvertibleTo(t):
+		return v.Convert(t), nil
+	default:
+		return v, fmt.Errorf("cannot convert %s to %s", v.Type(), t)
+	}
+}
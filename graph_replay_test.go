@@ -0,0 +1,150 @@
+package goflow
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// memCodec keeps events in memory without converting packets.
+type memCodec struct {
+	events *[]RecordEvent
+}
+
+func (c memCodec) NewEncoder(w io.Writer) RecordEncoder {
+	return c
+}
+
+func (c memCodec) NewDecoder(r io.Reader) RecordDecoder {
+	return &memDecoder{events: *c.events}
+}
+
+func (c memCodec) Encode(e RecordEvent) error {
+	*c.events = append(*c.events, e)
+	return nil
+}
+
+type memDecoder struct {
+	events []RecordEvent
+}
+
+func (d *memDecoder) Decode(e *RecordEvent) error {
+	if len(d.events) == 0 {
+		return io.EOF
+	}
+
+	*e = d.events[0]
+	d.events = d.events[1:]
+
+	return nil
+}
+
+func TestReplayGraph(t *testing.T) {
+	var buf bytes.Buffer
+
+	input := []int{5, 6, 7}
+
+	recorded, err := recordFanOutFanIn(&buf, JSONCodec, input, "In")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	p, err := NewReplayer(&buf, JSONCodec)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := newFanOutFanIn()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := p.ReplayGraph(n); err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan int)
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	var replayed []int
+	for i := range out {
+		replayed = append(replayed, i)
+	}
+
+	<-wait
+	<-p.Done()
+
+	if err := p.Err(); err != nil {
+		t.Error(err)
+	}
+
+	sort.Ints(recorded)
+	sort.Ints(replayed)
+
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("%v != %v", replayed, recorded)
+	}
+}
+
+func TestReplayProcess(t *testing.T) {
+	var events []RecordEvent
+
+	codec := memCodec{&events}
+
+	if _, err := recordFanOutFanIn(nil, codec, []int{1, 2, 3, 4}, "e2.In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	p, err := NewReplayer(nil, codec)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	p.SetSpeed(100)
+
+	c := new(echo)
+	if err := p.ReplayProcess("e2", c); err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan int)
+	c.Out = out
+
+	wait := Run(c)
+
+	// The process gets the packets in the recorded order
+	var expected []int
+
+	for _, e := range events {
+		if e.Kind == RecordData {
+			expected = append(expected, e.Packet.(int))
+		}
+	}
+
+	for _, v := range expected {
+		if actual := <-out; actual != v {
+			t.Errorf("%d != %d", actual, v)
+		}
+	}
+
+	<-wait
+	<-p.Done()
+
+	if err := p.ReplayProcess("e2", new(adder)); err == nil {
+		t.Error("Expected an error for a missing port")
+	}
+}
@@ -0,0 +1,881 @@
+package goflow
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDeadlock is the time a simulation waits for progress before reporting a deadlock.
+const DefaultDeadlock = time.Second
+
+// SimConfig configures a simulated run of a graph.
+type SimConfig struct {
+	Seed     int64         // Seed of the scheduler choices
+	Deadlock time.Duration // Time to wait for progress before giving up, DefaultDeadlock if 0
+}
+
+// SimStep is a single decision of the simulation scheduler: delivery of a packet
+// over a connection or closing of an inport.
+type SimStep struct {
+	From   string      // Sender port, IIP or graph inport
+	To     string      // Receiver port or graph outport
+	Packet interface{} // Delivered packet, nil if the port has been closed
+	Close  bool        // Tells that the receiver port has been closed
+}
+
+func (s SimStep) String() string {
+	if s.Close {
+		return fmt.Sprintf("close %s", s.To)
+	}
+
+	return fmt.Sprintf("%s -> %s: %v", s.From, s.To, s.Packet)
+}
+
+// Simulate runs the graph under a deterministic scheduler instead of the Go runtime.
+// Every connection is routed through the scheduler, which collects the packets sent
+// by processes and delivers them one at a time in an order chosen by a random
+// generator with a given seed. After each step, the scheduler waits until every
+// running process, including the goroutines it has started, is blocked on a channel
+// or a lock, so the same seed reproduces the same interleaving no matter how long
+// processes take to handle a packet. Simulate returns the steps made, which can be
+// compared between runs.
+//
+// Graph inports and outports must be set before the simulation. For reproducible
+// results, inports should be fed from buffered channels filled before the start.
+// Processes waiting in a select are considered blocked even if it has a timer
+// case, so their timing is not reproduced. Blocked goroutines are found in stack
+// dumps of the Go runtime, Simulate fails if their format is not recognized.
+// Subgraphs and process pools are not supported. A simulated graph cannot be run again.
+func (n *Graph) Simulate(conf SimConfig) ([]SimStep, error) {
+	if conf.Deadlock <= 0 {
+		conf.Deadlock = DefaultDeadlock
+	}
+
+	s := &simulation{
+		conf:   conf,
+		rng:    rand.New(rand.NewSource(conf.Seed)),
+		notify: make(chan struct{}, 1),
+	}
+
+	if err := checkGoroutineDumps(); err != nil {
+		return nil, fmt.Errorf("simulate: %w", err)
+	}
+
+	n.lock.Lock()
+
+	if n.running {
+		n.lock.Unlock()
+		return nil, fmt.Errorf("simulate: graph is running")
+	}
+
+	s.letters = newDeadLetterQueue(n.deliverDeadLetter(), n.deadLetterClose)
+	err := s.wire(n)
+	n.lock.Unlock()
+
+	if err != nil {
+		s.letters.close()
+		return nil, fmt.Errorf("simulate: %w", err)
+	}
+
+	s.start(n)
+
+	if err := s.schedule(); err != nil {
+		return s.trace, fmt.Errorf("simulate: %w", err)
+	}
+
+	s.letters.close()
+
+	return s.trace, s.err
+}
+
+// simSource is a queue of packets sent to one or more receivers.
+type simSource struct {
+	name    string
+	proc    string          // Process which owns the channel, empty for IIPs and graph inports
+	plain   bool            // Tells that the channel is a plain outport closed after the process
+	ch      reflect.Value   // Channel read by the scheduler, invalid for IIPs
+	queue   []reflect.Value // Packets waiting to be delivered
+	closed  bool
+	targets []*simTarget
+}
+
+// simTarget is a receiving channel written by the scheduler.
+type simTarget struct {
+	name     string
+	addr     address // Receiver port, used for packets which cannot be converted to its type
+	ch       reflect.Value
+	sources  []*simSource
+	closed   bool
+	external bool   // Graph outport read by the user
+	letters  string // Process whose dead letters the target receives instead of a channel
+}
+
+// simulation holds the state of a simulated run.
+type simulation struct {
+	conf       SimConfig
+	rng        *rand.Rand
+	lock       sync.Mutex
+	notify     chan struct{}    // Signals activity of processes
+	sources    []*simSource     // Sorted by name
+	targets    []*simTarget     // Sorted by name
+	running    map[string]int64 // Goroutines of the processes which have not finished
+	collectors map[int64]bool   // Goroutines collecting packets which are not closed yet
+	err        error
+	letters    *deadLetterQueue // Receives packets of unconnected Err outports
+	trace      []SimStep
+}
+
+// simAction is a step which can be made.
+type simAction struct {
+	src *simSource
+	tgt *simTarget
+}
+
+// wire attaches scheduler channels to all connected ports. It must be called with the graph locked.
+func (s *simulation) wire(n *Graph) error {
+	sources := make(map[string]*simSource)
+	targets := make(map[string]*simTarget)
+
+	source := func(addr address) (*simSource, error) {
+		name := addr.proc + "." + endpointOf(addr).portName()
+		if src, ok := sources[name]; ok {
+			return src, nil
+		}
+
+		ch, err := n.simChan(addr, reflect.SendDir)
+		if err != nil {
+			return nil, err
+		}
+
+		src := &simSource{name: name, proc: addr.proc, plain: addr.index < 0 && addr.key == "", ch: ch}
+		sources[name] = src
+
+		return src, nil
+	}
+
+	target := func(addr address) (*simTarget, error) {
+		name := addr.proc + "." + endpointOf(addr).portName()
+		if tgt, ok := targets[name]; ok {
+			return tgt, nil
+		}
+
+		ch, err := n.simChan(addr, reflect.RecvDir)
+		if err != nil {
+			return nil, err
+		}
+
+		tgt := &simTarget{name: name, addr: addr, ch: ch}
+		targets[name] = tgt
+
+		return tgt, nil
+	}
+
+	link := func(src *simSource, tgt *simTarget) {
+		src.targets = append(src.targets, tgt)
+		tgt.sources = append(tgt.sources, src)
+	}
+
+	for name, proc := range n.procs {
+		if _, isGraph := proc.(*Graph); isGraph {
+			return fmt.Errorf("subgraph '%s' cannot be simulated", name)
+		}
+
+		if len(n.pools[name]) > 0 {
+			return fmt.Errorf("pool '%s' cannot be simulated", name)
+		}
+	}
+
+	for _, c := range n.connections {
+		src, err := source(c.src)
+		if err != nil {
+			return err
+		}
+
+		tgt, err := target(c.tgt)
+		if err != nil {
+			return err
+		}
+
+		link(src, tgt)
+	}
+
+	for _, name := range sortedPortNames(n.inPorts) {
+		p := n.inPorts[name]
+		if isNilChan(p.channel) {
+			return fmt.Errorf("inport '%s' is not set", name)
+		}
+
+		tgt, err := target(p.addr)
+		if err != nil {
+			return err
+		}
+
+		src := &simSource{name: "in " + name, ch: p.channel}
+		sources[src.name] = src
+		link(src, tgt)
+	}
+
+	for _, name := range sortedPortNames(n.outPorts) {
+		p := n.outPorts[name]
+		if isNilChan(p.channel) {
+			return fmt.Errorf("outport '%s' is not set", name)
+		}
+
+		src, err := source(p.addr)
+		if err != nil {
+			return err
+		}
+
+		tgt := &simTarget{name: "out " + name, ch: p.channel, external: true}
+		targets[tgt.name] = tgt
+		link(src, tgt)
+	}
+
+	for i, ip := range n.iips {
+		tgt, err := target(ip.addr)
+		if err != nil {
+			return err
+		}
+
+		value, err := n.iipValue(ip)
+		if err != nil {
+			return fmt.Errorf("IIP for '%s': %w", ip.addr, err)
+		}
+
+		data, err := adaptPacket(reflect.ValueOf(value), tgt.ch.Type().Elem(), IIPOrigin)
+		if err != nil {
+			return fmt.Errorf("IIP for '%s': %w", ip.addr, err)
+		}
+
+		src := &simSource{
+			name:   fmt.Sprintf("IIP %d", i),
+			queue:  []reflect.Value{data},
+			closed: true,
+		}
+		sources[src.name] = src
+		link(src, tgt)
+	}
+
+	// Unconnected Err outports are delivered to the dead letters
+	for name := range n.procs {
+		port, ok := errPortOf(n.procs[name])
+		if !ok || !port.IsNil() {
+			continue
+		}
+
+		src, err := source(parseAddress(name, ErrPort))
+		if err != nil {
+			return err
+		}
+
+		tgt := &simTarget{name: "dead letters " + name, letters: name}
+		targets[tgt.name] = tgt
+		link(src, tgt)
+	}
+
+	for _, src := range sources {
+		sort.Slice(src.targets, func(i, j int) bool { return src.targets[i].name < src.targets[j].name })
+		s.sources = append(s.sources, src)
+	}
+
+	for _, tgt := range targets {
+		s.targets = append(s.targets, tgt)
+	}
+
+	sort.Slice(s.sources, func(i, j int) bool { return s.sources[i].name < s.sources[j].name })
+	sort.Slice(s.targets, func(i, j int) bool { return s.targets[i].name < s.targets[j].name })
+
+	return nil
+}
+
+// simChan attaches a new unbuffered channel to a process port replacing
+// the one it has. It must be called with the graph locked.
+func (n *Graph) simChan(addr address, dir reflect.ChanDir) (reflect.Value, error) {
+	port, err := n.getProcPort(addr.proc, addr.port, dir)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	t := portChanType(port.Type())
+	if t == nil {
+		return reflect.Value{}, fmt.Errorf("port '%s' is not a channel", addr)
+	}
+
+	ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t.Elem()), 0)
+
+	return attachPort(port, addr, dir, ch, 0)
+}
+
+// start launches the processes and the goroutines collecting their packets.
+// It returns when all of them have registered their goroutines.
+func (s *simulation) start(n *Graph) {
+	n.lock.Lock()
+	procs := make(map[string]Component, len(n.procs))
+
+	for name, proc := range n.procs {
+		if c, ok := proc.(Component); ok {
+			procs[name] = c
+		}
+	}
+	n.lock.Unlock()
+
+	s.running = make(map[string]int64, len(procs))
+	s.collectors = make(map[int64]bool)
+	ready := new(sync.WaitGroup)
+
+	for _, src := range s.sources {
+		if src.ch.IsValid() {
+			ready.Add(1)
+
+			go s.collect(src, ready)
+		}
+	}
+
+	for name, c := range procs {
+		ready.Add(1)
+
+		go s.run(name, c, ready)
+	}
+
+	ready.Wait()
+}
+
+// run runs a process and closes its plain outports once it finishes.
+func (s *simulation) run(name string, c Component, ready *sync.WaitGroup) {
+	// The format of goroutine dumps has been checked before the start
+	id, _ := goroutineID()
+
+	s.lock.Lock()
+	s.running[name] = id
+	s.lock.Unlock()
+	ready.Done()
+
+	_, err := runGuarded(c)
+
+	for _, src := range s.sources {
+		if src.proc == name && src.plain {
+			src.ch.Close()
+		}
+	}
+
+	s.lock.Lock()
+	delete(s.running, name)
+
+	if err != nil && s.err == nil {
+		s.err = fmt.Errorf("process '%s' failed: %w", name, err)
+	}
+
+	s.event()
+	s.lock.Unlock()
+}
+
+// collect queues the packets sent to a channel.
+func (s *simulation) collect(src *simSource, ready *sync.WaitGroup) {
+	id, _ := goroutineID()
+
+	s.lock.Lock()
+	s.collectors[id] = true
+	s.lock.Unlock()
+	ready.Done()
+
+	for {
+		v, ok := src.ch.Recv()
+
+		s.lock.Lock()
+
+		if ok {
+			src.queue = append(src.queue, v)
+		} else {
+			src.closed = true
+			delete(s.collectors, id)
+		}
+
+		s.event()
+		s.lock.Unlock()
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// event records activity. It must be called with the simulation locked.
+func (s *simulation) event() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// settle waits until the running processes are blocked and the collectors wait
+// for packets. It returns false if that does not happen within the Deadlock time.
+func (s *simulation) settle() (bool, error) {
+	deadline := time.Now().Add(s.conf.Deadlock)
+
+	for i := 0; ; i++ {
+		settled, err := s.settled()
+		if settled || err != nil {
+			return settled, err
+		}
+
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+
+		// Busy processes are usually done within a few yields, others may take long
+		if i < 100 {
+			runtime.Gosched()
+		} else {
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+}
+
+// settled tells if every goroutine of the running processes and the goroutines
+// started by them are blocked, and if all collectors wait for packets.
+func (s *simulation) settled() (bool, error) {
+	s.lock.Lock()
+	procs := make(map[int64]bool, len(s.running))
+
+	for _, id := range s.running {
+		procs[id] = true
+	}
+
+	collectors := make(map[int64]bool, len(s.collectors))
+	for id := range s.collectors {
+		collectors[id] = true
+	}
+	s.lock.Unlock()
+
+	states, err := goroutineStates()
+	if err != nil {
+		return false, err
+	}
+
+	for id, g := range states {
+		if collectors[id] && !strings.HasPrefix(g.state, "chan receive") {
+			return false, nil
+		}
+	}
+
+	return states.blocked(procs), nil
+}
+
+// goroutineInfo is the state of a goroutine in a stack dump.
+type goroutineInfo struct {
+	state  string // Wait reason, e.g. "chan receive" or "running"
+	parent int64  // Goroutine which has created this one, 0 if unknown
+}
+
+// goroutineSet maps goroutine IDs to their state.
+type goroutineSet map[int64]goroutineInfo
+
+// ownedBy tells if a goroutine is one of the roots or has been started by them.
+func (gs goroutineSet) ownedBy(id int64, roots map[int64]bool) bool {
+	for depth := 0; depth < len(gs)+1; depth++ {
+		if roots[id] {
+			return true
+		}
+
+		g, ok := gs[id]
+		if !ok || g.parent == 0 {
+			return false
+		}
+
+		id = g.parent
+	}
+
+	return false
+}
+
+// blocked tells if the roots and all goroutines started by them are blocked.
+func (gs goroutineSet) blocked(roots map[int64]bool) bool {
+	for id, g := range gs {
+		if gs.ownedBy(id, roots) && !isBlockedState(g.state) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// goroutineStates parses a stack dump of all goroutines. Headers look like
+// "goroutine 7 [chan receive, 2 minutes]:" and goroutines tell their parent
+// in lines like "created by main.run in goroutine 1", which Go has written
+// since 1.21. Dumps in another format are an error rather than a guess.
+func goroutineStates() (goroutineSet, error) {
+	buf := make([]byte, 64<<10)
+
+	for {
+		size := runtime.Stack(buf, true)
+		if size < len(buf) {
+			buf = buf[:size]
+			break
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+
+	res := make(goroutineSet)
+
+	for _, dump := range bytes.Split(buf, []byte("\n\n")) {
+		lines := strings.Split(string(dump), "\n")
+
+		id, state, err := parseGoroutineHeader(lines[0])
+		if err != nil {
+			return nil, err
+		}
+
+		g := goroutineInfo{state: state}
+
+		for _, line := range lines[1:] {
+			if !strings.HasPrefix(line, "created by ") {
+				continue
+			}
+
+			pos := strings.LastIndex(line, " in goroutine ")
+			if pos < 0 {
+				return nil, fmt.Errorf("unrecognized goroutine creation line %q", line)
+			}
+
+			if g.parent, err = strconv.ParseInt(line[pos+len(" in goroutine "):], 10, 64); err != nil {
+				return nil, fmt.Errorf("unrecognized goroutine creation line %q", line)
+			}
+		}
+
+		res[id] = g
+	}
+
+	return res, nil
+}
+
+// parseGoroutineHeader parses the ID and the wait reason from the first line
+// of a goroutine dump like "goroutine 7 [chan receive, 2 minutes]:".
+func parseGoroutineHeader(line string) (int64, string, error) {
+	header := strings.TrimPrefix(line, "goroutine ")
+
+	pos := strings.Index(header, " [")
+	if header == line || pos < 0 || !strings.HasSuffix(header, "]:") {
+		return 0, "", fmt.Errorf("unrecognized goroutine header %q", line)
+	}
+
+	id, err := strconv.ParseInt(header[:pos], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, "", fmt.Errorf("unrecognized goroutine header %q", line)
+	}
+
+	state := strings.TrimSuffix(header[pos+2:], "]:")
+
+	return id, strings.SplitN(state, ",", 2)[0], nil
+}
+
+// blockedStates are wait reasons of goroutines which cannot go on until another
+// goroutine acts. A goroutine in a select is blocked even if one of the cases is
+// a timer, which is why timing is not reproduced.
+var blockedStates = []string{
+	"chan receive",
+	"chan send",
+	"select",
+	"sync.Mutex.Lock",
+	"sync.RWMutex.Lock",
+	"sync.RWMutex.RLock",
+	"sync.WaitGroup.Wait",
+	"sync.Cond.Wait",
+}
+
+// isBlockedState tells if a goroutine waits for a channel or a lock.
+func isBlockedState(state string) bool {
+	for _, prefix := range blockedStates {
+		if strings.HasPrefix(state, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// goroutineID returns the ID of the calling goroutine.
+func goroutineID() (int64, error) {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	id, _, err := parseGoroutineHeader(strings.SplitN(string(buf), "\n", 2)[0])
+
+	return id, err
+}
+
+// checkGoroutineDumps tells if stack dumps of the running Go version can be
+// parsed, including the parents of goroutines.
+func checkGoroutineDumps() error {
+	parent, err := goroutineID()
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		id     int64
+		states goroutineSet
+		err    error
+	}
+
+	done := make(chan result)
+
+	go func() {
+		var r result
+
+		if r.id, r.err = goroutineID(); r.err == nil {
+			r.states, r.err = goroutineStates()
+		}
+
+		done <- r
+	}()
+
+	r := <-done
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.states[r.id].parent != parent {
+		return fmt.Errorf("goroutine dumps do not tell the parents of goroutines")
+	}
+
+	return nil
+}
+
+// schedule makes steps until all processes have finished and all packets have been delivered.
+func (s *simulation) schedule() error {
+	for {
+		settled, err := s.settle()
+		if err != nil {
+			return err
+		}
+
+		if !settled {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+
+			return s.deadlock()
+		}
+
+		// Activity since here means that blocked steps may be possible now
+		select {
+		case <-s.notify:
+		default:
+		}
+
+		s.lock.Lock()
+		actions := s.actions()
+		finished := len(s.running) == 0
+		s.lock.Unlock()
+
+		if len(actions) == 0 && finished {
+			return nil
+		}
+
+		if s.step(actions) {
+			continue
+		}
+
+		select {
+		case <-s.notify:
+		case <-time.After(s.conf.Deadlock):
+			s.lock.Lock()
+			defer s.lock.Unlock()
+
+			return s.deadlock()
+		}
+	}
+}
+
+// actions lists the possible steps in a stable order. It must be called with the simulation locked.
+func (s *simulation) actions() []simAction {
+	var res []simAction
+
+	for _, src := range s.sources {
+		if len(src.queue) == 0 {
+			continue
+		}
+
+		for _, tgt := range src.targets {
+			if !tgt.closed {
+				res = append(res, simAction{src, tgt})
+			}
+		}
+	}
+
+	for _, tgt := range s.targets {
+		if !tgt.closed && tgt.drained() {
+			res = append(res, simAction{tgt: tgt})
+		}
+	}
+
+	return res
+}
+
+// drained tells if all sources of a target are closed and have no packets left.
+func (t *simTarget) drained() bool {
+	for _, src := range t.sources {
+		if !src.closed || len(src.queue) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// step picks actions at random until one of them succeeds. A delivery to a process
+// fails if the process is not waiting for the packet, as all processes are blocked.
+// Graph outports are given the Deadlock time to take the packet.
+func (s *simulation) step(actions []simAction) bool {
+	for len(actions) > 0 {
+		i := s.rng.Intn(len(actions))
+		a := actions[i]
+
+		if a.src == nil {
+			if a.tgt.ch.IsValid() {
+				a.tgt.ch.Close()
+			}
+
+			s.lock.Lock()
+			a.tgt.closed = true
+			s.trace = append(s.trace, SimStep{To: a.tgt.name, Close: true})
+			s.lock.Unlock()
+
+			return true
+		}
+
+		s.lock.Lock()
+		packet := a.src.queue[0]
+		s.lock.Unlock()
+
+		if a.tgt.letters != "" {
+			s.letters.add(newDeadLetter(a.tgt.letters, ErrPort, packet.Interface()))
+		} else {
+			send, ok := s.convert(a, packet)
+			if !ok {
+				return true
+			}
+
+			if !s.deliver(a.tgt, send) {
+				actions = append(actions[:i], actions[i+1:]...)
+				continue
+			}
+		}
+
+		s.lock.Lock()
+		a.src.queue = a.src.queue[1:]
+		s.trace = append(s.trace, SimStep{From: a.src.name, To: a.tgt.name, Packet: packet.Interface()})
+		s.event()
+		s.lock.Unlock()
+
+		return true
+	}
+
+	return false
+}
+
+// deliver sends a packet to a target if it takes it.
+func (s *simulation) deliver(tgt *simTarget, packet reflect.Value) bool {
+	cases := []reflect.SelectCase{{Dir: reflect.SelectSend, Chan: tgt.ch, Send: packet}}
+
+	if tgt.external {
+		timer := time.NewTimer(s.conf.Deadlock)
+		defer timer.Stop()
+
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+	} else {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+
+	return chosen == 0
+}
+
+// convert adapts a packet to the type of the receiver, wrapping or unwrapping
+// envelopes like the graph does. A packet which cannot be converted is taken
+// from the queue and sent to the dead letters.
+func (s *simulation) convert(a simAction, packet reflect.Value) (reflect.Value, bool) {
+	t := a.tgt.ch.Type().Elem()
+	if packet.Type() == t {
+		return packet, true
+	}
+
+	res, err := adaptPacket(packet, t, a.src.name)
+	if err == nil {
+		return res, true
+	}
+
+	s.letters.add(DeadLetter{
+		Time:    time.Now(),
+		Process: a.tgt.addr.proc,
+		Port:    endpointOf(a.tgt.addr).portName(),
+		Packet:  packet.Interface(),
+		Err:     err,
+	})
+
+	s.lock.Lock()
+	a.src.queue = a.src.queue[1:]
+	s.trace = append(s.trace, SimStep{From: a.src.name, To: "dead letters " + a.tgt.addr.proc, Packet: packet.Interface()})
+	s.event()
+	s.lock.Unlock()
+
+	return res, false
+}
+
+// deadlock describes the state of a simulation which cannot make progress.
+// It must be called with the simulation locked.
+func (s *simulation) deadlock() error {
+	var running, pending []string
+
+	for name := range s.running {
+		running = append(running, name)
+	}
+
+	sort.Strings(running)
+
+	for _, src := range s.sources {
+		if len(src.queue) > 0 {
+			pending = append(pending, fmt.Sprintf("%s (%d)", src.name, len(src.queue)))
+		}
+	}
+
+	return fmt.Errorf("deadlock after %d steps: running processes [%s], pending packets [%s]",
+		len(s.trace), strings.Join(running, ", "), strings.Join(pending, ", "))
+}
+
+// SeedError reports a seed for which a simulation has failed.
+type SeedError struct {
+	Seed int64
+	Err  error
+}
+
+func (e *SeedError) Error() string {
+	return fmt.Sprintf("seed %d: %s", e.Seed, e.Err)
+}
+
+func (e *SeedError) Unwrap() error {
+	return e.Err
+}
+
+// ExploreSeeds calls test with seeds from 1 to count to search for interleavings
+// which break a graph. The test usually builds a graph, simulates it with the seed
+// and checks the results. The first failure is returned as a *SeedError, so it can
+// be reproduced with the same seed.
+func ExploreSeeds(count int, test func(seed int64) error) error {
+	for seed := int64(1); seed <= int64(count); seed++ {
+		if err := test(seed); err != nil {
+			return &SeedError{Seed: seed, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,384 @@
+package goflow
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// simulateFanOutFanIn simulates a fan-out/fan-in graph and returns its output.
+func simulateFanOutFanIn(seed int64, input []int) ([]int, []SimStep, error) {
+	n, err := newFanOutFanIn()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	in := make(chan int, len(input))
+	out := make(chan int, len(input))
+
+	for _, i := range input {
+		in <- i
+	}
+
+	close(in)
+
+	if err := n.SetInPort("In", in); err != nil {
+		return nil, nil, err
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		return nil, nil, err
+	}
+
+	trace, err := n.Simulate(SimConfig{Seed: seed})
+	if err != nil {
+		return nil, trace, err
+	}
+
+	var res []int
+	for i := range out {
+		res = append(res, i)
+	}
+
+	return res, trace, nil
+}
+
+func TestSimulateIsReproducible(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6}
+
+	out1, trace1, err := simulateFanOutFanIn(42, input)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out2, trace2, err := simulateFanOutFanIn(42, input)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(out1, out2) || !reflect.DeepEqual(trace1, trace2) {
+		t.Errorf("Runs with the same seed differ: %v != %v", out1, out2)
+	}
+
+	sorted := append([]int{}, out1...)
+	sort.Ints(sorted)
+
+	if !reflect.DeepEqual(sorted, []int{2, 4, 6, 8, 10, 12}) {
+		t.Errorf("Unexpected output %v", out1)
+	}
+
+	if last := trace1[len(trace1)-1]; !last.Close || last.To != "out Out" {
+		t.Errorf("Unexpected last step %s", last)
+	}
+}
+
+func TestSimulateSlowProcess(t *testing.T) {
+	simulate := func() ([]int, []SimStep, error) {
+		n := NewGraph()
+
+		for name, c := range map[string]interface{}{
+			"e1": new(echo),
+			"d1": &slowDoubler{delay: 10 * time.Millisecond},
+			"d2": new(doubler),
+			"e2": new(echo),
+		} {
+			if err := n.Add(name, c); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		for _, c := range [][4]string{{"e1", "Out", "d1", "In"}, {"e1", "Out", "d2", "In"}, {"d1", "Out", "e2", "In"}, {"d2", "Out", "e2", "In"}} {
+			if err := n.Connect(c[0], c[1], c[2], c[3]); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		n.MapInPort("In", "e1", "In")
+		n.MapOutPort("Out", "e2", "Out")
+
+		in := make(chan int, 4)
+		out := make(chan int, 4)
+
+		for i := 1; i <= 4; i++ {
+			in <- i
+		}
+
+		close(in)
+
+		n.SetInPort("In", in)
+		n.SetOutPort("Out", out)
+
+		trace, err := n.Simulate(SimConfig{Seed: 3})
+		if err != nil {
+			return nil, trace, err
+		}
+
+		var res []int
+		for i := range out {
+			res = append(res, i)
+		}
+
+		return res, trace, nil
+	}
+
+	// The helper goroutine of d1 takes longer than a step, it is waited for anyway
+	out1, trace1, err := simulate()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out2, trace2, err := simulate()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(out1, out2) || !reflect.DeepEqual(trace1, trace2) {
+		t.Errorf("Runs with the same seed differ: %v != %v", out1, out2)
+	}
+
+	if len(out1) != 4 {
+		t.Errorf("Unexpected output %v", out1)
+	}
+}
+
+func TestExploreSeeds(t *testing.T) {
+	input := []int{1, 2, 3, 4}
+	orders := make(map[string]bool)
+
+	err := ExploreSeeds(20, func(seed int64) error {
+		out, _, err := simulateFanOutFanIn(seed, input)
+		orders[fmt.Sprint(out)] = true
+
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(orders) < 2 {
+		t.Errorf("Exploration has not found different orders: %v", orders)
+	}
+
+	// The graph does not keep the order of packets, which is found by exploration
+	err = ExploreSeeds(50, func(seed int64) error {
+		out, _, err := simulateFanOutFanIn(seed, input)
+		if err != nil {
+			return err
+		}
+
+		if !sort.IntsAreSorted(out) {
+			return fmt.Errorf("unordered output %v", out)
+		}
+
+		return nil
+	})
+
+	var seedErr *SeedError
+	if !errors.As(err, &seedErr) {
+		t.Errorf("Expected a seed error, got %v", err)
+		return
+	}
+
+	// The failure is reproduced with the seed
+	out, _, err := simulateFanOutFanIn(seedErr.Seed, input)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if sort.IntsAreSorted(out) {
+		t.Errorf("Seed %d does not reproduce the failure: %v", seedErr.Seed, out)
+	}
+}
+
+func TestSimulateIIPAndErrors(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.Add("p", new(panicker)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Add("e", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.Connect("p", "Out", "e", "In"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.AddIIP("p", "In", -1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan int, 1)
+	n.MapOutPort("Out", "e", "Out")
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	trace, err := n.Simulate(SimConfig{})
+	if err == nil || !strings.Contains(err.Error(), "process 'p' failed: panic: negative input") {
+		t.Errorf("Expected a process failure, got %v", err)
+	}
+
+	if len(trace) == 0 || trace[0].String() != "IIP 0 -> p.In: -1" {
+		t.Errorf("Unexpected trace %v", trace)
+	}
+
+	// Pools are not supported
+	n = NewGraph()
+	if err := n.AddPool("d", 2, func() (interface{}, error) { return new(doubler), nil }); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := n.Simulate(SimConfig{}); err == nil {
+		t.Error("Expected an error for a pool")
+	}
+}
+
+func TestSimulateDeadlock(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.Add("e", new(echo)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n.MapInPort("In", "e", "In")
+	n.MapOutPort("Out", "e", "Out")
+
+	// The inport is never closed
+	in := make(chan int, 1)
+	in <- 1
+
+	out := make(chan int, 1)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err := n.Simulate(SimConfig{Deadlock: 50 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "deadlock after 2 steps: running processes [e]") {
+		t.Errorf("Expected a deadlock, got %v", err)
+	}
+}
+
+func TestSimulateDeadLetters(t *testing.T) {
+	n, err := newRejecterGraph()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var letters []DeadLetter
+
+	n.SetDeadLetterFunc(func(l DeadLetter) {
+		letters = append(letters, l)
+	})
+
+	go func() {
+		in <- -1
+		in <- 2
+		close(in)
+	}()
+
+	go func() {
+		for range out {
+		}
+	}()
+
+	trace, err := n.Simulate(SimConfig{Seed: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(letters) != 1 || letters[0].Packet != -1 {
+		t.Errorf("Unexpected dead letters %v", letters)
+	}
+
+	found := false
+
+	for _, step := range trace {
+		if step.From == "r.Err" && step.To == "dead letters r" && !step.Close {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Dead letter is not traced: %v", trace)
+	}
+}
+
+func TestGoroutineDumps(t *testing.T) {
+	if err := checkGoroutineDumps(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for header, expected := range map[string]string{
+		"goroutine 7 [chan receive, 2 minutes]:": "chan receive",
+		"goroutine 12 [running]:":                "running",
+	} {
+		if _, state, err := parseGoroutineHeader(header); err != nil || state != expected {
+			t.Errorf("'%s' != '%s': %v", state, expected, err)
+		}
+	}
+
+	// Unknown formats are errors rather than guesses
+	for _, header := range []string{"goroutine 7 chan receive", "thread 7 [running]:", "goroutine x [running]:"} {
+		if _, _, err := parseGoroutineHeader(header); err == nil {
+			t.Errorf("Expected an error for '%s'", header)
+		}
+	}
+
+	for state, expected := range map[string]bool{
+		"chan receive (nil chan)": true,
+		"select":                  true,
+		"sync.WaitGroup.Wait":     true,
+		"sync.Mutex.Lock":         true,
+		"running":                 false,
+		"sleep":                   false,
+		"syscall":                 false,
+		"IO wait":                 false,
+	} {
+		if isBlockedState(state) != expected {
+			t.Errorf("Blocked state of '%s' is not %v", state, expected)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package goflow
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ErrorReporter is implemented by components which can tell that their process
+// has failed. ProcessError is checked every time Process returns.
+type ErrorReporter interface {
+	ProcessError() error
+}
+
+// RestartPolicy tells which failures of a supervised process lead to a restart.
+type RestartPolicy int
+
+const (
+	// RestartOnPanic restarts a process which has panicked.
+	RestartOnPanic RestartPolicy = 1 << iota
+	// RestartOnError restarts a process which has returned with an error.
+	RestartOnError
+
+	// RestartOnFailure restarts a process on both panics and errors.
+	RestartOnFailure = RestartOnPanic | RestartOnError
+)
+
+// Supervision configures how a graph handles failures of a process.
+type Supervision struct {
+	Restart     RestartPolicy // Kinds of failures that cause a restart
+	MaxRestarts int           // Maximum number of restarts within Window, unlimited if 0
+	Window      time.Duration // Time window for MaxRestarts, the whole lifetime if 0
+	Backoff     time.Duration // Delay before the first restart, doubled after each next one
+	MaxBackoff  time.Duration // Upper limit for the restart delay, unlimited if 0
+	Escalate    bool          // Fail the whole graph if a failure is not handled by a restart
+}
+
+// Supervise sets up supervision for a process. Panics of a supervised process are
+// always recovered. Restarts reuse the port channels the process had on start,
+// so its neighbors are not affected. When a failure is not handled by a restart,
+// the packets still sent to the process are discarded and its outports are closed,
+// so the rest of the graph finishes as its input runs out. With Escalate the graph
+// then reports the failure with ProcessError.
+func (n *Graph) Supervise(processName string, s Supervision) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, exists := n.procs[processName]; !exists {
+		return fmt.Errorf("supervise: process '%s' does not exist", processName)
+	}
+
+	n.supervisors[processName] = s
+
+	return nil
+}
+
+// ProcessError returns the error which has failed the graph, if any.
+func (n *Graph) ProcessError() error {
+	n.errLock.Lock()
+	defer n.errLock.Unlock()
+
+	return n.err
+}
+
+// fail records the first failure escalated to the graph.
+func (n *Graph) fail(err error) {
+	n.errLock.Lock()
+	defer n.errLock.Unlock()
+
+	if n.err == nil {
+		n.err = err
+	}
+}
+
+// runSupervised runs a process until it finishes normally or a failure
+// cannot be handled by a restart.
+func (n *Graph) runSupervised(name string, c Component, s Supervision) {
+	ports := savePorts(c)
+	backoff := s.Backoff
+
+	var restarts []time.Time
+
+	for {
+		panicked, err := runGuarded(c)
+		if err == nil {
+			return
+		}
+
+		policy := RestartOnError
+		if panicked {
+			policy = RestartOnPanic
+		}
+
+		now := time.Now()
+		restarts = s.recentRestarts(restarts, now)
+
+		if s.Restart&policy == 0 || (s.MaxRestarts > 0 && len(restarts) >= s.MaxRestarts) {
+			if s.Escalate {
+				n.fail(fmt.Errorf("process '%s' failed: %w", name, err))
+			}
+
+			// The process is gone, its senders must not block on it. Its outports
+			// are closed when it returns, so the rest of the graph winds down.
+			drainInPorts(ports)
+
+			return
+		}
+
+		restarts = append(restarts, now)
+
+		if backoff > 0 {
+			time.Sleep(backoff)
+
+			backoff *= 2
+			if s.MaxBackoff > 0 && backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+		}
+
+		restorePorts(c, ports)
+	}
+}
+
+// drainInPorts receives packets from saved inports until they are closed.
+func drainInPorts(ports []savedPort) {
+	var chans []reflect.Value
+
+	for _, p := range ports {
+		switch p.value.Kind() {
+		case reflect.Chan:
+			chans = append(chans, p.value)
+		case reflect.Map:
+			for _, k := range p.value.MapKeys() {
+				chans = append(chans, p.value.MapIndex(k))
+			}
+		case reflect.Slice:
+			for i := 0; i < p.value.Len(); i++ {
+				chans = append(chans, p.value.Index(i))
+			}
+		}
+	}
+
+	for _, ch := range chans {
+		if ch.IsNil() || ch.Type().ChanDir() != reflect.RecvDir {
+			continue
+		}
+
+		go func(ch reflect.Value) {
+			for {
+				if _, ok := ch.Recv(); !ok {
+					return
+				}
+			}
+		}(ch)
+	}
+}
+
+// recentRestarts drops the restarts which are outside of the window.
+func (s Supervision) recentRestarts(restarts []time.Time, now time.Time) []time.Time {
+	if s.Window <= 0 {
+		return restarts
+	}
+
+	i := 0
+	for i < len(restarts) && now.Sub(restarts[i]) > s.Window {
+		i++
+	}
+
+	return restarts[i:]
+}
+
+// runGuarded runs a process once, converting a panic into an error.
+func runGuarded(c Component) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			panicked = true
+		}
+	}()
+
+	c.Process()
+
+	if r, ok := c.(ErrorReporter); ok {
+		err = r.ProcessError()
+	}
+
+	return false, err
+}
+
+// savedPort is a copy of a port field value.
+type savedPort struct {
+	field int
+	value reflect.Value
+}
+
+// savePorts copies the values of all channel, map and slice fields of a component,
+// because a process may modify them while it runs, e.g. set closed ports to nil.
+func savePorts(c interface{}) []savedPort {
+	val := reflect.ValueOf(c)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	val = val.Elem()
+	ports := make([]savedPort, 0, val.NumField())
+
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Chan:
+			ports = append(ports, savedPort{i, reflect.ValueOf(field.Interface())})
+		case reflect.Map:
+			if field.Type().Elem().Kind() == reflect.Chan && !field.IsNil() {
+				m := reflect.MakeMap(field.Type())
+				for _, k := range field.MapKeys() {
+					m.SetMapIndex(k, field.MapIndex(k))
+				}
+
+				ports = append(ports, savedPort{i, m})
+			}
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.Chan && !field.IsNil() {
+				s := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+				reflect.Copy(s, field)
+				ports = append(ports, savedPort{i, s})
+			}
+		}
+	}
+
+	return ports
+}
+
+// restorePorts sets the port fields of a component to the saved values.
+func restorePorts(c interface{}, ports []savedPort) {
+	if len(ports) == 0 {
+		return
+	}
+
+	val := reflect.ValueOf(c).Elem()
+
+	for _, p := range ports {
+		field := val.Field(p.field)
+
+		switch field.Kind() {
+		case reflect.Map:
+			m := reflect.MakeMap(field.Type())
+			for _, k := range p.value.MapKeys() {
+				m.SetMapIndex(k, p.value.MapIndex(k))
+			}
+
+			field.Set(m)
+		case reflect.Slice:
+			s := reflect.MakeSlice(field.Type(), p.value.Len(), p.value.Len())
+			reflect.Copy(s, p.value)
+			field.Set(s)
+		default:
+			field.Set(p.value)
+		}
+	}
+}
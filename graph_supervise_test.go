@@ -0,0 +1,197 @@
+package goflow
+
+import (
+	"testing"
+	"time"
+)
+
+func newSupervisedGraph(c Component, s Supervision) (*Graph, error) {
+	n := NewGraph()
+
+	if err := n.Add("e1", new(echo)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Add("proc", c); err != nil {
+		return nil, err
+	}
+
+	if err := n.Add("e2", new(echo)); err != nil {
+		return nil, err
+	}
+
+	if err := n.Connect("e1", "Out", "proc", "In"); err != nil {
+		return nil, err
+	}
+
+	if err := n.Connect("proc", "Out", "e2", "In"); err != nil {
+		return nil, err
+	}
+
+	if err := n.Supervise("proc", s); err != nil {
+		return nil, err
+	}
+
+	n.MapInPort("In", "e1", "In")
+	n.MapOutPort("Out", "e2", "Out")
+
+	return n, nil
+}
+
+func runIntGraph(n *Graph, input []int) []int {
+	in := make(chan int)
+	out := make(chan int)
+
+	n.SetInPort("In", in)
+	n.SetOutPort("Out", out)
+
+	wait := Run(n)
+
+	go func() {
+		for _, i := range input {
+			in <- i
+		}
+
+		close(in)
+	}()
+
+	res := []int{}
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
+
+	return res
+}
+
+func expectInts(t *testing.T, actual, expected []int) {
+	if len(actual) != len(expected) {
+		t.Errorf("%v != %v", actual, expected)
+		return
+	}
+
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("%v != %v", actual, expected)
+			return
+		}
+	}
+}
+
+func TestRestartOnPanic(t *testing.T) {
+	n, err := newSupervisedGraph(new(panicker), Supervision{
+		Restart: RestartOnPanic,
+		Backoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res := runIntGraph(n, []int{1, -1, 2, -3, 4})
+
+	expectInts(t, res, []int{1, 2, 4})
+
+	if err := n.ProcessError(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEscalateAfterMaxRestarts(t *testing.T) {
+	n, err := newSupervisedGraph(new(failer), Supervision{
+		Restart:     RestartOnError,
+		MaxRestarts: 1,
+		Escalate:    true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res := runIntGraph(n, []int{1, -1, 2, -1})
+
+	expectInts(t, res, []int{1, 2})
+
+	err = n.ProcessError()
+	if err == nil {
+		t.Errorf("Expected an error")
+		return
+	}
+
+	if err.Error() != "process 'proc' failed: negative input" {
+		t.Error(err)
+	}
+}
+
+func TestEscalatePanicWithoutRestart(t *testing.T) {
+	n, err := newSupervisedGraph(new(panicker), Supervision{
+		Restart:  RestartOnError,
+		Escalate: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res := runIntGraph(n, []int{1, -1})
+
+	expectInts(t, res, []int{1})
+
+	if err := n.ProcessError(); err == nil || err.Error() != "process 'proc' failed: panic: negative input" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEscalateWithMoreInput(t *testing.T) {
+	n, err := newSupervisedGraph(new(failer), Supervision{
+		Restart:  RestartOnPanic,
+		Escalate: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	done := make(chan []int)
+
+	go func() {
+		done <- runIntGraph(n, []int{1, -1, 2, 3})
+	}()
+
+	select {
+	case res := <-done:
+		expectInts(t, res, []int{1})
+	case <-time.After(5 * time.Second):
+		t.Error("The graph has not finished after an escalated failure")
+		return
+	}
+
+	if err := n.ProcessError(); err == nil || err.Error() != "process 'proc' failed: negative input" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestSuperviseMissingProcess(t *testing.T) {
+	n := NewGraph()
+
+	if err := n.Supervise("noproc", Supervision{}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestRestartWindow(t *testing.T) {
+	s := Supervision{Window: time.Minute}
+	now := time.Now()
+	restarts := []time.Time{now.Add(-2 * time.Minute), now.Add(-30 * time.Second), now}
+
+	if recent := s.recentRestarts(restarts, now); len(recent) != 2 {
+		t.Errorf("%d != 2", len(recent))
+	}
+
+	s.Window = 0
+
+	if recent := s.recentRestarts(restarts, now); len(recent) != 3 {
+		t.Errorf("%d != 3", len(recent))
+	}
+}
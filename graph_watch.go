@@ -0,0 +1,471 @@
+package goflow
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// GraphEvent describes a change of a watched graph definition file.
+type GraphEvent struct {
+	File      string // Graph definition file
+	Patch     Patch  // Changes between the old and the new definition
+	Restarted bool   // Tells if the graph has been restarted instead of modified live
+	Err       error  // Set if the new definition could not be loaded, the old graph keeps running then
+}
+
+// GraphWatcher runs a graph loaded from a JSON or FBP definition file and
+// reloads it when the file changes. Changes which only add processes,
+// connections and IIPs of new processes are applied to the running graph. Other changes restart
+// the graph gracefully: the inports of the old graph are closed, so it drains
+// and finishes, then the new graph starts and receives the rest of the input.
+//
+// The watcher is a component itself. Its exported ports are those of the graph
+// and must be set with SetInPort and SetOutPort before it runs.
+type GraphWatcher struct {
+	filename string
+	factory  *Factory
+	setup    func(n *Graph) error // Applied to every graph built from the definition
+	interval time.Duration
+	events   chan GraphEvent
+	data     []byte                   // Last loaded definition
+	graph    *Graph                   // Graph which is running
+	next     *Graph                   // Graph replacing the running one after a restart
+	lock     *sync.RWMutex            // Protects the graph and the channels below
+	resume   chan struct{}            // Closed when a restarted graph is ready to receive input
+	userIns  map[string]reflect.Value // Inport channels set by the user
+	userOuts map[string]reflect.Value // Outport channels set by the user
+	ins      map[string]*watchIn      // Inports of the running graph, nil if closed
+	closed   map[string]bool          // Inports closed by the user
+}
+
+// NewGraphWatcher loads a graph from a definition file and returns a watcher
+// polling the file for changes with a given interval.
+func NewGraphWatcher(filename string, factory *Factory, interval time.Duration) (*GraphWatcher, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := buildGraph(filename, data, factory)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GraphWatcher{
+		filename: filename,
+		factory:  factory,
+		interval: interval,
+		events:   make(chan GraphEvent, 16),
+		data:     data,
+		graph:    graph,
+		lock:     new(sync.RWMutex),
+		userIns:  make(map[string]reflect.Value),
+		userOuts: make(map[string]reflect.Value),
+		ins:      make(map[string]*watchIn),
+		closed:   make(map[string]bool),
+	}, nil
+}
+
+// buildGraph creates a graph from a definition in JSON or FBP format.
+func buildGraph(filename string, data []byte, factory *Factory) (*Graph, error) {
+	descr, err := parseGraphDescription(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return descr.build(factory)
+}
+
+// SetBuildFunc sets a function which sets up every graph built from the
+// definition, e.g. sets its parameters. It is applied to the current graph at
+// once and to the graphs reloaded later. A reload fails if the function returns
+// an error, the old graph keeps running then.
+func (w *GraphWatcher) SetBuildFunc(fn func(n *Graph) error) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, n := range []*Graph{w.graph, w.next} {
+		if n == nil {
+			continue
+		}
+
+		if err := fn(n); err != nil {
+			return fmt.Errorf("SetBuildFunc: %w", err)
+		}
+	}
+
+	w.setup = fn
+
+	return nil
+}
+
+// Graph returns the graph which is currently running.
+func (w *GraphWatcher) Graph() *Graph {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return w.graph
+}
+
+// Events returns a channel of changes applied to the graph. The channel is
+// buffered and must be drained by the user, otherwise reloading stalls.
+// It is closed when the watcher has finished.
+func (w *GraphWatcher) Events() <-chan GraphEvent {
+	return w.events
+}
+
+// SetInPort assigns a channel to an inport of the graph.
+func (w *GraphWatcher) SetInPort(name string, channel interface{}) error {
+	ch := reflect.ValueOf(channel)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0 {
+		return fmt.Errorf("SetInPort: '%s' is not a readable channel", name)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, exists := w.graph.inPort(name); !exists {
+		return fmt.Errorf("SetInPort: in port '%s' not defined", name)
+	}
+
+	w.userIns[capitalizePortName(name)] = ch
+
+	return nil
+}
+
+// SetOutPort assigns a channel to an outport of the graph.
+func (w *GraphWatcher) SetOutPort(name string, channel interface{}) error {
+	ch := reflect.ValueOf(channel)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.SendDir == 0 {
+		return fmt.Errorf("SetOutPort: '%s' is not a writable channel", name)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, exists := w.graph.outPort(name); !exists {
+		return fmt.Errorf("SetOutPort: out port '%s' not defined", name)
+	}
+
+	w.userOuts[capitalizePortName(name)] = ch
+
+	return nil
+}
+
+// Process runs the graph until it finishes, reloading it on changes.
+// The outports set by the user are closed afterwards.
+func (w *GraphWatcher) Process() {
+	stop := make(chan struct{})
+	polled := make(chan struct{})
+
+	go func() {
+		w.poll(stop)
+		close(polled)
+	}()
+
+	w.lock.Lock()
+	for name, ch := range w.userIns {
+		go w.relayIn(name, ch)
+	}
+
+	graph := w.graph
+	forwarded := w.wire(graph)
+	w.lock.Unlock()
+
+	for {
+		<-Run(graph)
+		forwarded.Wait()
+
+		w.lock.Lock()
+
+		if w.next == nil {
+			w.lock.Unlock()
+			break
+		}
+
+		graph = w.next
+		w.graph = graph
+		w.next = nil
+		forwarded = w.wire(graph)
+		close(w.resume)
+		w.resume = nil
+
+		w.lock.Unlock()
+	}
+
+	close(stop)
+	<-polled
+	close(w.events)
+
+	for _, ch := range w.userOuts {
+		ch.Close()
+	}
+}
+
+// wire connects exported ports of a graph to the user's channels via new channels,
+// so they can be closed on restart without affecting the user. The returned wait
+// group is done when all outports of the graph are closed. It must be called with
+// the watcher locked.
+func (w *GraphWatcher) wire(graph *Graph) *sync.WaitGroup {
+	forwarded := new(sync.WaitGroup)
+
+	for name, user := range w.userIns {
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, user.Type().Elem()), 0)
+		w.ins[name] = &watchIn{ch: ch, stop: make(chan struct{})}
+
+		if w.closed[name] {
+			ch.Close()
+			w.ins[name] = nil
+		}
+
+		if err := graph.SetInPort(name, ch.Interface()); err != nil {
+			// The port has been removed from the new definition, it is closed for it
+			w.ins[name] = nil
+		}
+	}
+
+	for name, user := range w.userOuts {
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, user.Type().Elem()), 0)
+		if err := graph.SetOutPort(name, ch.Interface()); err != nil {
+			continue
+		}
+
+		forwarded.Add(1)
+
+		go func(ch, user reflect.Value) {
+			for {
+				v, ok := ch.Recv()
+				if !ok {
+					break
+				}
+
+				user.Send(v)
+			}
+
+			forwarded.Done()
+		}(ch, user)
+	}
+
+	return forwarded
+}
+
+// watchIn is an inport channel of the running graph. Once the relay of the port
+// has started, only the relay sends to the channel and closes it: when the user
+// closes their channel or when stop is closed on restart.
+type watchIn struct {
+	ch   reflect.Value
+	stop chan struct{}
+}
+
+// relayIn passes packets from a user's inport channel to the running graph.
+// The watcher is not locked while sending, so a graph which waits for packets
+// of another inport does not hold up a restart. A packet which the old graph
+// has not taken is sent to the next one.
+func (w *GraphWatcher) relayIn(name string, user reflect.Value) {
+	var (
+		packet  reflect.Value // Received from the user and not sent yet
+		pending bool
+		closed  bool // The user has closed the channel
+	)
+
+	for {
+		w.lock.RLock()
+		in, resume := w.ins[name], w.resume
+		w.lock.RUnlock()
+
+		switch {
+		case in == nil && resume != nil:
+			// The graph is restarting, the packets go to the next one
+			<-resume
+			continue
+		case in == nil && pending:
+			// The running graph does not have the port
+			pending = false
+		case closed && !pending:
+			w.closeIn(name, in)
+			return
+		}
+
+		cases := make([]reflect.SelectCase, 0, 2)
+		recv, send, stop := -1, -1, -1
+
+		if !pending {
+			recv = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: user})
+		}
+
+		if in != nil {
+			if pending {
+				send = len(cases)
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: in.ch, Send: packet})
+			}
+
+			stop = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(in.stop)})
+		}
+
+		chosen, v, ok := reflect.Select(cases)
+
+		switch chosen {
+		case recv:
+			packet, pending, closed = v, ok, !ok
+		case send:
+			pending = false
+		case stop:
+			in.ch.Close()
+		}
+	}
+}
+
+// closeIn closes the inports of the running graph after the user has closed
+// their channel. in is the one the relay has been sending to, nil if none.
+func (w *GraphWatcher) closeIn(name string, in *watchIn) {
+	w.lock.Lock()
+	current := w.ins[name]
+	w.closed[name] = true
+	w.ins[name] = nil
+	w.lock.Unlock()
+
+	if in != nil {
+		in.ch.Close()
+	}
+
+	// The graph may have been restarted since the relay has looked up its inport
+	if current != nil && current != in {
+		current.ch.Close()
+	}
+}
+
+// poll checks the definition file for changes until stopped.
+func (w *GraphWatcher) poll(stop <-chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if event, changed := w.reload(); changed {
+				select {
+				case w.events <- event:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+// reload loads the definition file if it has changed and updates the running graph.
+func (w *GraphWatcher) reload() (GraphEvent, bool) {
+	event := GraphEvent{File: w.filename}
+
+	data, err := ioutil.ReadFile(w.filename)
+	if err != nil || bytes.Equal(data, w.data) {
+		// The file may be in the middle of being replaced, so reading errors are ignored
+		return event, false
+	}
+
+	w.data = data
+
+	next, err := buildGraph(w.filename, data, w.factory)
+	if err != nil {
+		event.Err = fmt.Errorf("reload: %w", err)
+		return event, true
+	}
+
+	w.lock.RLock()
+	current, restarting, setup := w.graph, w.next != nil, w.setup
+	w.lock.RUnlock()
+
+	if setup != nil {
+		if err := setup(next); err != nil {
+			event.Err = fmt.Errorf("reload: %w", err)
+			return event, true
+		}
+	}
+
+	if restarting {
+		// The running graph is draining already, so the pending one is replaced
+		w.restart(next)
+		event.Restarted = true
+
+		return event, true
+	}
+
+	event.Patch = Diff(current, next)
+
+	if len(event.Patch) == 0 {
+		return event, false
+	}
+
+	if isAdditive(current, event.Patch) {
+		if err := current.Apply(event.Patch); err == nil {
+			// Processes having unconnected ports start like on the network start
+			for _, msg := range event.Patch {
+				if m, ok := msg.Payload.(addNode); ok {
+					current.startPending(m.ID)
+				}
+			}
+
+			return event, true
+		}
+	}
+
+	w.restart(next)
+	event.Restarted = true
+
+	return event, true
+}
+
+// restart replaces the running graph with the next one. It closes the inports of
+// the running graph and lets Process start the next one once the old one finishes.
+func (w *GraphWatcher) restart(next *Graph) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.next = next
+	if w.resume == nil {
+		w.resume = make(chan struct{})
+	}
+
+	// The relays close the inports, so packets being sent are not lost
+	for name, in := range w.ins {
+		if in != nil {
+			close(in.stop)
+		}
+
+		w.ins[name] = nil
+	}
+}
+
+// isAdditive tells if a patch can be applied to a running graph. Such a patch adds
+// processes, connections and IIPs of processes which have not been started yet.
+// A started process has received its IIPs already, so changing them needs a restart.
+func isAdditive(n *Graph, p Patch) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for _, msg := range p {
+		switch m := msg.Payload.(type) {
+		case addNode, addEdge:
+		case addInitial:
+			if n.isStarted(m.Tgt.Node) {
+				return false
+			}
+		case removeInitial:
+			if n.isStarted(m.Tgt.Node) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
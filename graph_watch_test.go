@@ -0,0 +1,308 @@
+package goflow
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeGraphFile(t *testing.T, filename, src string) {
+	if err := ioutil.WriteFile(filename, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectEvent(t *testing.T, w *GraphWatcher) GraphEvent {
+	select {
+	case e := <-w.Events():
+		if e.Err != nil {
+			t.Fatal(e.Err)
+		}
+
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for a graph event")
+	}
+
+	return GraphEvent{}
+}
+
+func TestGraphWatcher(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "graph.fbp")
+	writeGraphFile(t, filename, "INPORT=e.IN:IN\nOUTPORT=p.OUT:OUT\ne(echo) OUT -> IN p(echo)\n")
+
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewGraphWatcher(filename, f, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := w.SetInPort("In", in); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetOutPort("Out", out); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := Run(w)
+
+	in <- 1
+	if i := <-out; i != 1 {
+		t.Errorf("%d != 1", i)
+	}
+
+	// Additions are applied to the running graph
+	writeGraphFile(t, filename, "INPORT=e.IN:IN\nOUTPORT=p.OUT:OUT\ne(echo) OUT -> IN p(echo)\n'21' -> IN d(doubler) OUT -> IN p\n")
+
+	if i := <-out; i != 42 {
+		t.Errorf("%d != 42", i)
+	}
+
+	e := expectEvent(t, w)
+	if e.Restarted {
+		t.Error("Expected the change to be applied live")
+	}
+
+	expectCommands(t, e.Patch, "addnode", "addedge", "addinitial")
+
+	// Replacing a component requires a restart
+	writeGraphFile(t, filename, "INPORT=e.IN:IN\nOUTPORT=p.OUT:OUT\ne(doubler) OUT -> IN p(echo)\n")
+
+	e = expectEvent(t, w)
+	if !e.Restarted {
+		t.Error("Expected the graph to restart")
+	}
+
+	expectCommands(t, e.Patch, "removeinitial", "removeedge", "removeedge", "removeinport",
+		"removenode", "removenode", "addnode", "addedge", "addinport")
+
+	in <- 5
+	if i := <-out; i != 10 {
+		t.Errorf("%d != 10", i)
+	}
+
+	// Broken definitions are reported and the graph keeps running
+	writeGraphFile(t, filename, "e(doubler) OUT ->")
+
+	e = <-w.Events()
+	if e.Err == nil {
+		t.Error("Expected an error")
+	}
+
+	in <- 6
+	if i := <-out; i != 12 {
+		t.Errorf("%d != 12", i)
+	}
+
+	close(in)
+
+	if _, ok := <-out; ok {
+		t.Error("Expected the outport to be closed")
+	}
+
+	<-wait
+}
+
+func TestGraphWatcherIIPChange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "graph.fbp")
+	src := "INPORT=e.IN:IN\nOUTPORT=p.OUT:OUT\ne(echo) OUT -> IN p(echo)\n'21' -> IN d(doubler) OUT -> IN p\n"
+	writeGraphFile(t, filename, src)
+
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewGraphWatcher(filename, f, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan int)
+	out := make(chan int)
+
+	if err := w.SetInPort("In", in); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetOutPort("Out", out); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := Run(w)
+
+	if i := <-out; i != 42 {
+		t.Errorf("%d != 42", i)
+	}
+
+	// The IIP of the running d has been sent already, so the new value needs a restart
+	writeGraphFile(t, filename, strings.Replace(src, "'21'", "'5'", 1))
+
+	e := expectEvent(t, w)
+	if !e.Restarted {
+		t.Error("Expected the graph to restart")
+	}
+
+	expectCommands(t, e.Patch, "removeinitial", "addinitial")
+
+	if i := <-out; i != 10 {
+		t.Errorf("%d != 10", i)
+	}
+
+	in <- 3
+	if i := <-out; i != 3 {
+		t.Errorf("%d != 3", i)
+	}
+
+	close(in)
+
+	for i := range out {
+		t.Errorf("Unexpected %d", i)
+	}
+
+	<-wait
+}
+
+func TestGraphWatcherRestartWhileSending(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "graph.fbp")
+	src := "INPORT=p.FIRST:A\nINPORT=p.SECOND:B\nOUTPORT=p.OUT:OUT\np(pair)\n"
+	writeGraphFile(t, filename, src)
+
+	f := NewFactory()
+	if err := f.Register("pair", func() (interface{}, error) { return new(pair), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewGraphWatcher(filename, f, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := make(chan int)
+	b := make(chan int)
+	out := make(chan int)
+
+	for name, ch := range map[string]chan int{"A": a, "B": b} {
+		if err := w.SetInPort(name, ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.SetOutPort("Out", out); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := Run(w)
+
+	// The graph does not read B until it gets A, so the packet is being sent
+	b <- 2
+
+	writeGraphFile(t, filename, "INPORT=q.FIRST:A\nINPORT=q.SECOND:B\nOUTPORT=q.OUT:OUT\nq(pair)\n")
+
+	if e := expectEvent(t, w); !e.Restarted {
+		t.Error("Expected a restart")
+	}
+
+	// The packet is sent to the restarted graph
+	a <- 1
+
+	if i := <-out; i != 3 {
+		t.Errorf("%d != 3", i)
+	}
+
+	close(a)
+	close(b)
+
+	for i := range out {
+		t.Errorf("Unexpected %d", i)
+	}
+
+	<-wait
+}
+
+func TestGraphWatcherParams(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "graph.json")
+	src := `{
+		"params": {"times": {"type": "int", "default": 1}},
+		"processes": {"r": {"component": "repeater"}},
+		"connections": [{"data": "${times}", "tgt": {"process": "r", "port": "Times"}}],
+		"inports": {"In": {"process": "r", "port": "Word"}},
+		"outports": {"Words": {"process": "r", "port": "Words"}}
+	}`
+	writeGraphFile(t, filename, src)
+
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewGraphWatcher(filename, f, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetBuildFunc(func(n *Graph) error { return n.SetParam("nope", 3) }); err == nil {
+		t.Error("Expected an error for an undeclared parameter")
+	}
+
+	if err := w.SetBuildFunc(func(n *Graph) error { return n.SetParam("times", 3) }); err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan string)
+	out := make(chan string)
+
+	if err := w.SetInPort("In", in); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetOutPort("Words", out); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := Run(w)
+
+	expectWords := func(word string) {
+		for i := 0; i < 3; i++ {
+			select {
+			case s := <-out:
+				if s != word {
+					t.Errorf("%s != %s", s, word)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Got %d of 3 words '%s'", i, word)
+			}
+		}
+	}
+
+	in <- "a"
+	expectWords("a")
+
+	// The restarted graph keeps the parameters
+	writeGraphFile(t, filename, strings.Replace(src, `"r"`, `"q"`, -1))
+
+	if e := expectEvent(t, w); !e.Restarted {
+		t.Error("Expected a restart")
+	}
+
+	in <- "b"
+	expectWords("b")
+
+	close(in)
+
+	for s := range out {
+		t.Errorf("Unexpected %s", s)
+	}
+
+	<-wait
+}
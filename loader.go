@@ -1,140 +1,308 @@
-// +build ignore
-
 package goflow
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
 // Internal representation of NoFlo JSON format
 type graphDescription struct {
 	Properties struct {
-		Name string
-	}
-	Processes map[string]struct {
-		Component string
-		Metadata  struct {
-			Sync     bool  `json:",omitempty"`
-			PoolSize int64 `json:",omitempty"`
-		} `json:",omitempty"`
-	}
-	Connections []struct {
-		Data interface{} `json:",omitempty"`
-		Src  struct {
-			Process string
-			Port    string
-		} `json:",omitempty"`
-		Tgt struct {
-			Process string
-			Port    string
-		}
-		Metadata struct {
-			Buffer int `json:",omitempty"`
-		} `json:",omitempty"`
+		Name        string
+		Description string `json:",omitempty"`
+		Icon        string `json:",omitempty"`
 	}
-	Exports []struct {
+	Params      map[string]Param `json:",omitempty"` // Graph parameters by name
+	Processes   map[string]processDescription
+	Connections []connectionDescription
+	Exports     []struct {
 		Private string
 		Public  string
 	}
+	Inports  map[string]exportDescription
+	Outports map[string]exportDescription
+}
+
+// processDescription is a process in the NoFlo JSON format.
+type processDescription struct {
+	Component string
+	Metadata  struct {
+		Sync     bool                       `json:",omitempty"` // ignored
+		PoolSize int64                      `json:",omitempty"`
+		Params   map[string]json.RawMessage `json:",omitempty"` // Parameters of a subgraph, may refer to parameters of this graph
+	} `json:",omitempty"`
+}
+
+// connectionDescription is a connection or an IIP in the NoFlo JSON format.
+type connectionDescription struct {
+	Data     json.RawMessage `json:",omitempty"`
+	Src      endpointDescription
+	Tgt      endpointDescription
+	Metadata struct {
+		Buffer  int               `json:",omitempty"`
+		Headers map[string]string `json:",omitempty"` // Headers of an IIP sent to an IP port
+		Durable *QueueConfig      `json:",omitempty"` // Makes the connection durable
+	} `json:",omitempty"`
+}
+
+// endpointDescription is a port of a process in the NoFlo JSON format.
+type endpointDescription struct {
+	Process string
+	Port    string
+	Index   *int `json:",omitempty"`
+}
+
+// portName returns the port name including the array index if any.
+func (e endpointDescription) portName() string {
+	if e.Index == nil {
+		return e.Port
+	}
+
+	return e.Port + "[" + strconv.Itoa(*e.Index) + "]"
+}
+
+// exportDescription is a graph port export in the NoFlo JSON format.
+type exportDescription struct {
+	Process string
+	Port    string
 }
 
 // ParseJSON converts a JSON network definition string into
-// a flow.Graph object that can be run or used in other networks
-func ParseJSON(js []byte) *Graph {
-	// Parse JSON into Go struct
-	var descr graphDescription
-	err := json.Unmarshal(js, &descr)
+// a flow.Graph object that can be run or used in other networks.
+// Components are created using a given factory. Component names may contain version
+// constraints like "Split@^1.2" and the graph records the versions actually used.
+func ParseJSON(js []byte, factory *Factory) (*Graph, error) {
+	descr, err := parseJSONDescription(js)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	// fmt.Printf("%+v\n", descr)
-
-	constructor := func() interface{} {
-		// Create a new Graph
-		net := new(Graph)
-		net.InitGraphState()
-
-		// Add processes to the network
-		for procName, procValue := range descr.Processes {
-			net.AddNew(procValue.Component, procName)
-			// Process mode detection
-			if procValue.Metadata.PoolSize > 0 {
-				proc := net.Get(procName).(*Component)
-				proc.Mode = ComponentModePool
-				proc.PoolSize = uint8(procValue.Metadata.PoolSize)
-			} else if procValue.Metadata.Sync {
-				proc := net.Get(procName).(*Component)
-				proc.Mode = ComponentModeSync
-			}
+
+	return descr.build(factory)
+}
+
+// LoadJSON loads a JSON graph definition file into
+// a flow.Graph object that can be run or used in other networks.
+func LoadJSON(filename string, factory *Factory) (*Graph, error) {
+	js, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJSON(js, factory)
+}
+
+// LoadGraph loads a graph definition file in either JSON or FBP format,
+// which is detected by the file extension.
+func LoadGraph(filename string, factory *Factory) (*Graph, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	descr, err := parseGraphDescription(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return descr.build(factory)
+}
+
+// parseGraphDescription parses a graph definition in a format defined by the file extension.
+func parseGraphDescription(filename string, data []byte) (*graphDescription, error) {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		return parseJSONDescription(data)
+	case ".fbp":
+		return parseFBPDescription(data)
+	default:
+		return nil, fmt.Errorf("unsupported graph file format '%s'", ext)
+	}
+}
+
+func parseJSONDescription(js []byte) (*graphDescription, error) {
+	// Parse JSON into Go struct
+	descr := new(graphDescription)
+	if err := json.Unmarshal(js, descr); err != nil {
+		return nil, fmt.Errorf("ParseJSON: %w", err)
+	}
+
+	return descr, nil
+}
+
+// build creates a new graph from a description.
+func (descr *graphDescription) build(factory *Factory) (*Graph, error) {
+	// Create a new Graph
+	net := NewGraph()
+
+	for name, p := range descr.Params {
+		p.Name = name
+		if err := net.DeclareParam(p); err != nil {
+			return nil, fmt.Errorf("ParseJSON: %w", err)
+		}
+	}
+
+	// Add processes to the network
+	for procName, procValue := range descr.Processes {
+		var err error
+		if procValue.Metadata.PoolSize > 1 {
+			err = net.AddNewPool(procName, procValue.Component, int(procValue.Metadata.PoolSize), factory)
+		} else {
+			err = net.AddNew(procName, procValue.Component, factory)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("ParseJSON: %w", err)
 		}
 
-		// Add connections
-		for _, conn := range descr.Connections {
-			// Check if it is an IIP or actual connection
-			if conn.Data == nil {
-				// Add a connection
-				net.ConnectBuf(conn.Src.Process, conn.Src.Port, conn.Tgt.Process, conn.Tgt.Port, conn.Metadata.Buffer)
-			} else {
-				// Add an IIP
-				net.AddIIP(conn.Data, conn.Tgt.Process, conn.Tgt.Port)
+		if len(procValue.Metadata.Params) > 0 {
+			if err := net.overrideParams(procName, procValue.Metadata.Params); err != nil {
+				return nil, fmt.Errorf("ParseJSON: %w", err)
 			}
 		}
+	}
 
-		// Add port exports
-		for _, export := range descr.Exports {
-			// Split private into proc.port
-			procName := export.Private[:strings.Index(export.Private, ".")]
-			procPort := export.Private[strings.Index(export.Private, ".")+1:]
-			// Try to detect port direction using reflection
-			procType := reflect.TypeOf(net.Get(procName)).Elem()
-			field, fieldFound := procType.FieldByName(procPort)
-			if !fieldFound {
-				panic("Private port '" + export.Private + "' not found")
+	// Add connections
+	for _, conn := range descr.Connections {
+		// Check if it is an IIP or actual connection
+		if conn.Data == nil {
+			// Add a connection
+			var opts []ConnectOption
+			if conn.Metadata.Durable != nil {
+				opts = append(opts, Durable(*conn.Metadata.Durable))
 			}
-			if field.Type.Kind() == reflect.Chan && (field.Type.ChanDir()&reflect.RecvDir) != 0 {
-				// It's an inport
-				net.MapInPort(export.Public, procName, procPort)
-			} else if field.Type.Kind() == reflect.Chan && (field.Type.ChanDir()&reflect.SendDir) != 0 {
-				// It's an outport
-				net.MapOutPort(export.Public, procName, procPort)
-			} else {
-				// It's not a proper port
-				panic("Private port '" + export.Private + "' is not a valid channel")
+
+			if err := net.ConnectBuf(conn.Src.Process, conn.Src.portName(), conn.Tgt.Process, conn.Tgt.portName(), conn.Metadata.Buffer, opts...); err != nil {
+				return nil, fmt.Errorf("ParseJSON: %w", err)
 			}
-			// TODO add support for subgraphs
+
+			continue
+		}
+
+		// Add an IIP
+		data, err := net.iipData(conn.Tgt.Process, conn.Tgt.portName(), conn.Data, conn.Metadata.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("ParseJSON: %w", err)
+		}
+
+		if err := net.AddIIP(conn.Tgt.Process, conn.Tgt.portName(), data); err != nil {
+			return nil, fmt.Errorf("ParseJSON: %w", err)
+		}
+	}
+
+	// Add port exports
+	for _, export := range descr.Exports {
+		// Split private into proc.port
+		dot := strings.Index(export.Private, ".")
+		if dot < 0 {
+			return nil, fmt.Errorf("ParseJSON: invalid private port '%s'", export.Private)
+		}
+
+		if err := net.mapPort(export.Public, export.Private[:dot], export.Private[dot+1:]); err != nil {
+			return nil, fmt.Errorf("ParseJSON: %w", err)
 		}
+	}
 
-		return net
+	for name, export := range descr.Inports {
+		net.MapInPort(name, export.Process, export.Port)
 	}
 
-	// Register a component to be reused
-	if descr.Properties.Name != "" {
-		Register(descr.Properties.Name, constructor)
+	for name, export := range descr.Outports {
+		net.MapOutPort(name, export.Process, export.Port)
 	}
 
-	return constructor().(*Graph)
+	return net, nil
 }
 
-// LoadJSON loads a JSON graph definition file into
-// a flow.Graph object that can be run or used in other networks
-func LoadJSON(filename string) *Graph {
-	js, err := ioutil.ReadFile(filename)
+// decodeIIP unmarshals JSON data into a value of the target port type.
+// IIPs of IP ports are decoded into *IP.
+func (n *Graph) decodeIIP(procName, portName string, data json.RawMessage) (interface{}, error) {
+	addr := parseAddress(procName, portName)
+
+	port, err := n.getProcPort(procName, addr.port, reflect.RecvDir)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	t := portChanType(port.Type())
+	if t == nil {
+		return nil, fmt.Errorf("IIP target '%s' is not a channel", addr)
+	}
+
+	if isIPType(t.Elem()) {
+		return decodeIP(data), nil
+	}
+
+	v := reflect.New(t.Elem())
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		if t.Elem().Kind() != reflect.String {
+			return nil, fmt.Errorf("invalid IIP for '%s': %w", addr, err)
+		}
+
+		// Raw data for string ports, e.g. '5' in FBP
+		v.Elem().SetString(string(data))
+	}
+
+	return v.Elem().Interface(), nil
+}
+
+// decodeIP decodes an envelope if data is an object with a "value" field,
+// otherwise data is the value of a new envelope. Data which is not valid JSON
+// is taken as a string, e.g. '5' in FBP.
+func decodeIP(data json.RawMessage) *IP {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) == nil {
+		if _, ok := fields["value"]; ok {
+			ip := new(IP)
+			if json.Unmarshal(data, ip) == nil {
+				return ip
+			}
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		v = string(data)
+	}
+
+	return &IP{Value: v}
+}
+
+// mapPort exports a process port detecting its direction using reflection.
+func (n *Graph) mapPort(name, procName, procPort string) error {
+	addr := parseAddress(procName, procPort)
+
+	// Subgraphs look their ports up by direction, so both have to be tried
+	if port, err := n.getProcPort(procName, addr.port, reflect.RecvDir); err == nil {
+		if t := portChanType(port.Type()); t != nil && t.ChanDir()&reflect.RecvDir != 0 {
+			n.MapInPort(name, procName, procPort)
+			return nil
+		}
+	}
+
+	if port, err := n.getProcPort(procName, addr.port, reflect.SendDir); err == nil {
+		if t := portChanType(port.Type()); t != nil && t.ChanDir()&reflect.SendDir != 0 {
+			n.MapOutPort(name, procName, procPort)
+			return nil
+		}
 	}
-	return ParseJSON(js)
+
+	return fmt.Errorf("private port '%s' is not a valid port", addr)
 }
 
-// RegisterJSON registers an external JSON graph definition as a component
-// that can be instantiated at run-time using component Factory.
-// It returns true on success or false if component name is already taken.
-func RegisterJSON(componentName, filePath string) bool {
-	var constructor ComponentConstructor
-	constructor = func() interface{} {
-		return LoadJSON(filePath)
+// portChanType returns the channel type of a plain, array or map port or nil if it is not a port.
+func portChanType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Map || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Chan {
+		return nil
 	}
-	return Register(componentName, constructor)
+
+	return t
 }
@@ -0,0 +1,309 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ParseFBP converts a network definition in the FBP DSL into a flow.Graph object.
+// The supported syntax covers connection chains like
+//
+//	'5' -> TIMES Rep(repeater) WORDS -> IN Out(echo)
+//
+// array port indexes like OUT[1], process metadata like d(doubler:poolSize=3),
+// and exported ports like INPORT=Rep.WORD:WORD and OUTPORT=Out.OUT:OUT.
+// Statements are separated with new lines or commas, comments start with '#'.
+func ParseFBP(src []byte, factory *Factory) (*Graph, error) {
+	descr, err := parseFBPDescription(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return descr.build(factory)
+}
+
+// LoadFBP loads a graph definition file in the FBP DSL.
+func LoadFBP(filename string, factory *Factory) (*Graph, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFBP(src, factory)
+}
+
+// parseFBPDescription converts FBP DSL into the same description as the JSON format.
+func parseFBPDescription(src []byte) (*graphDescription, error) {
+	descr := &graphDescription{
+		Processes: make(map[string]processDescription),
+		Inports:   make(map[string]exportDescription),
+		Outports:  make(map[string]exportDescription),
+	}
+	used := make(map[string]int) // line where a process is first mentioned
+
+	for i, line := range strings.Split(string(src), "\n") {
+		for _, stmt := range splitFBP(stripFBPComment(line), ",") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" {
+				continue
+			}
+
+			if err := parseFBPStatement(descr, stmt, used, i+1); err != nil {
+				return nil, fmt.Errorf("ParseFBP: line %d: %w", i+1, err)
+			}
+		}
+	}
+
+	for name, line := range used {
+		if _, declared := descr.Processes[name]; !declared {
+			return nil, fmt.Errorf("ParseFBP: line %d: process '%s' has no component", line, name)
+		}
+	}
+
+	return descr, nil
+}
+
+// parseFBPStatement parses a single export or connection chain.
+func parseFBPStatement(descr *graphDescription, stmt string, used map[string]int, line int) error {
+	upper := strings.ToUpper(stmt)
+
+	for _, kw := range []string{"INPORT=", "OUTPORT="} {
+		if !strings.HasPrefix(upper, kw) {
+			continue
+		}
+
+		exp, public, err := parseFBPExport(stmt[len(kw):])
+		if err != nil {
+			return err
+		}
+
+		used[exp.Process] = line
+
+		if kw == "INPORT=" {
+			descr.Inports[public] = exp
+		} else {
+			descr.Outports[public] = exp
+		}
+
+		return nil
+	}
+
+	segs := splitFBP(stmt, "->")
+
+	var (
+		prevProc string
+		prevPort string
+		data     json.RawMessage
+	)
+
+	for i, seg := range segs {
+		seg = strings.TrimSpace(seg)
+
+		if i == 0 && strings.HasPrefix(seg, "'") {
+			raw, err := parseFBPData(seg)
+			if err != nil {
+				return err
+			}
+
+			data = raw
+
+			continue
+		}
+
+		fields := splitFBP(strings.ReplaceAll(seg, "\t", " "), " ")
+		tokens := fields[:0]
+
+		for _, f := range fields {
+			if f = strings.TrimSpace(f); f != "" {
+				tokens = append(tokens, f)
+			}
+		}
+
+		// The first segment has no inport and the last one has no outport
+		inPort, outPort := "", ""
+
+		switch {
+		case len(segs) == 1 && len(tokens) == 1:
+		case i == 0 && len(tokens) == 2:
+			outPort = tokens[1]
+			tokens = tokens[:1]
+		case i > 0 && i == len(segs)-1 && len(tokens) == 2:
+			inPort = tokens[0]
+			tokens = tokens[1:]
+		case i > 0 && i < len(segs)-1 && len(tokens) == 3:
+			inPort, outPort = tokens[0], tokens[2]
+			tokens = tokens[1:2]
+		default:
+			return fmt.Errorf("unexpected '%s'", seg)
+		}
+
+		proc, err := parseFBPNode(descr, tokens[0])
+		if err != nil {
+			return err
+		}
+
+		if _, seen := used[proc]; !seen {
+			used[proc] = line
+		}
+
+		if i > 0 {
+			conn := connectionDescription{Data: data}
+			conn.Src.Process = prevProc
+			conn.Src.Port = prevPort
+			conn.Tgt.Process = proc
+			conn.Tgt.Port = inPort
+			descr.Connections = append(descr.Connections, conn)
+			data = nil
+		}
+
+		prevProc, prevPort = proc, outPort
+	}
+
+	return nil
+}
+
+// parseFBPNode parses a process reference like "name" or "name(Component:key=value)"
+// and registers the process component if it is given.
+func parseFBPNode(descr *graphDescription, token string) (string, error) {
+	open := strings.Index(token, "(")
+	if open < 0 {
+		return token, nil
+	}
+
+	if !strings.HasSuffix(token, ")") || open == 0 {
+		return "", fmt.Errorf("invalid process '%s'", token)
+	}
+
+	name := token[:open]
+	spec := token[open+1 : len(token)-1]
+
+	var proc processDescription
+
+	meta := ""
+	if colon := strings.Index(spec, ":"); colon >= 0 {
+		spec, meta = spec[:colon], spec[colon+1:]
+	}
+
+	proc.Component = spec
+
+	for _, kv := range strings.Split(meta, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+
+		eq := strings.Index(kv, "=")
+		if eq < 0 {
+			return "", fmt.Errorf("invalid metadata '%s' of process '%s'", kv, name)
+		}
+
+		key, value := strings.ToLower(kv[:eq]), kv[eq+1:]
+
+		switch key {
+		case "poolsize":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid pool size of process '%s': %w", name, err)
+			}
+
+			proc.Metadata.PoolSize = size
+		case "sync":
+			proc.Metadata.Sync = value == "true"
+		}
+	}
+
+	if existing, declared := descr.Processes[name]; declared && existing.Component != proc.Component {
+		return "", fmt.Errorf("process '%s' is already declared as '%s'", name, existing.Component)
+	}
+
+	if proc.Component != "" {
+		descr.Processes[name] = proc
+	}
+
+	return name, nil
+}
+
+// parseFBPExport parses "proc.PORT:NAME".
+func parseFBPExport(s string) (exportDescription, string, error) {
+	var exp exportDescription
+
+	colon := strings.LastIndex(s, ":")
+	dot := strings.Index(s, ".")
+
+	if colon < 0 || dot < 0 || dot > colon {
+		return exp, "", fmt.Errorf("invalid export '%s'", s)
+	}
+
+	exp.Process = strings.TrimSpace(s[:dot])
+	exp.Port = strings.TrimSpace(s[dot+1 : colon])
+
+	return exp, strings.TrimSpace(s[colon+1:]), nil
+}
+
+// parseFBPData converts a quoted IIP into JSON. Data which is not valid JSON is
+// treated as a string.
+func parseFBPData(s string) (json.RawMessage, error) {
+	if len(s) < 2 || !strings.HasSuffix(s, "'") {
+		return nil, fmt.Errorf("unterminated IIP %s", s)
+	}
+
+	s = strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`)
+
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s), nil
+	}
+
+	return json.Marshal(s)
+}
+
+// splitFBP splits a string by a separator outside of quotes and parentheses.
+func splitFBP(s, sep string) []string {
+	var (
+		res     []string
+		start   int
+		depth   int
+		inQuote bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuote:
+			i++
+		case s[i] == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			res = append(res, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+
+	return append(res, s[start:])
+}
+
+// stripFBPComment removes a comment which is not inside quotes.
+func stripFBPComment(line string) string {
+	inQuote := false
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '\'':
+			inQuote = !inQuote
+		case '#':
+			if !inQuote {
+				return line[:i]
+			}
+		}
+	}
+
+	return line
+}
@@ -0,0 +1,103 @@
+package goflow
+
+import (
+	"sort"
+	"testing"
+)
+
+var doublerFBP = `# Doubles incoming numbers
+INPORT=e.IN:IN
+OUTPORT=d.OUT:OUT
+
+e(echo) OUT -> IN d(doubler:poolSize=2) # pooled doubler
+`
+
+func TestParseFBP(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := ParseFBP([]byte(doublerFBP), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if size := n.PoolSize("d"); size != 2 {
+		t.Errorf("%d != 2", size)
+	}
+
+	res := runIntGraph(n, []int{1, 2, 3})
+
+	sort.Ints(res)
+	expectInts(t, res, []int{2, 4, 6})
+}
+
+func TestParseFBPWithIIPs(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	n, err := ParseFBP([]byte(`'it\'s' -> WORD r(repeater), '3' -> TIMES r
+OUTPORT=r.WORDS:OUT`), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := make(chan string)
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
+
+	i := 0
+	for s := range out {
+		if s != "it's" {
+			t.Errorf("'%s' != 'it's'", s)
+		}
+		i++
+	}
+
+	<-wait
+
+	if i != 3 {
+		t.Errorf("%d != 3", i)
+	}
+}
+
+func TestParseInvalidFBP(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cases := []struct {
+		scenario string
+		src      string
+	}{
+		{"Undeclared process", "a(echo) OUT -> IN b"},
+		{"Missing port", "a(echo) -> IN b(echo)"},
+		{"Unterminated IIP", "'5 -> IN a(echo)"},
+		{"Conflicting components", "a(echo) OUT -> IN a(doubler)"},
+		{"Invalid export", "INPORT=a:IN\na(echo)"},
+		{"Invalid pool size", "a(echo:poolSize=many)"},
+		{"Unknown component", "a(notfound)"},
+	}
+
+	for _, item := range cases {
+		c := item
+		t.Run(c.scenario, func(t *testing.T) {
+			if _, err := ParseFBP([]byte(c.src), f); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
@@ -1,179 +1,212 @@
-// +build ignore
-
 package goflow
 
 import (
-	"sync"
+	"sort"
 	"testing"
 )
 
-// Starter component fires the network
-// by sending a number given in its constructor
-// to its output port.
-type starter struct {
-	Component
-	Start <-chan float64
-	Out   chan<- int
-}
-
-func (s *starter) OnStart(num float64) {
-	s.Out <- int(num)
-}
+var pooledNetworkJSON = `{
+	"properties": {
+		"name": "pooledNetwork"
+	},
+	"processes": {
+		"e1": {
+			"component": "echo"
+		},
+		"d": {
+			"component": "doubler",
+			"metadata": {
+				"poolSize": 3
+			}
+		},
+		"e2": {
+			"component": "echo"
+		}
+	},
+	"connections": [
+		{
+			"src": {
+				"process": "e1",
+				"port": "Out"
+			},
+			"tgt": {
+				"process": "d",
+				"port": "In"
+			}
+		},
+		{
+			"src": {
+				"process": "d",
+				"port": "Out"
+			},
+			"tgt": {
+				"process": "e2",
+				"port": "In"
+			},
+			"metadata": {
+				"buffer": 2
+			}
+		}
+	],
+	"inports": {
+		"In": {
+			"process": "e1",
+			"port": "In"
+		}
+	},
+	"outports": {
+		"Out": {
+			"process": "e2",
+			"port": "Out"
+		}
+	}
+}`
 
-func newStarter() interface{} {
-	s := new(starter)
-	return s
-}
+func TestPooledNetwork(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
 
-func init() {
-	Register("starter", newStarter)
-}
+	n, err := ParseJSON([]byte(pooledNetworkJSON), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
 
-// SequenceGenerator generates a sequence of integers
-// from 0 to a number passed to its input.
-type sequenceGenerator struct {
-	Component
-	Num      <-chan int
-	Sequence chan<- int
-}
+	if size := n.PoolSize("d"); size != 3 {
+		t.Errorf("%d != 3", size)
+	}
 
-func (s *sequenceGenerator) OnNum(n int) {
-	for i := 1; i <= n; i++ {
-		s.Sequence <- i
+	if ref := n.ComponentOf("d"); ref != "doubler" {
+		t.Errorf("'%s' != 'doubler'", ref)
 	}
-}
 
-func newSequenceGenerator() interface{} {
-	return new(sequenceGenerator)
-}
+	in := make(chan int)
+	out := make(chan int)
 
-func init() {
-	Register("sequenceGenerator", newSequenceGenerator)
-}
+	if err := n.SetInPort("In", in); err != nil {
+		t.Error(err)
+		return
+	}
 
-// Summarizer component sums all its input packets and
-// produces a sum output just before shutdown
-type summarizer struct {
-	Component
-	In <-chan int
-	// Flush <-chan bool
-	Sum       chan<- int
-	StateLock *sync.Mutex
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
 
-	current int
-}
+	wait := Run(n)
 
-func newSummarizer() interface{} {
-	s := new(summarizer)
-	s.Component.Mode = ComponentModeSync
-	return s
-}
+	go func() {
+		for i := 1; i <= 10; i++ {
+			in <- i
+		}
 
-func init() {
-	Register("summarizer", newSummarizer)
-}
+		close(in)
+	}()
 
-func (s *summarizer) OnIn(i int) {
-	s.current += i
-}
+	res := []int{}
+	for i := range out {
+		res = append(res, i)
+	}
+
+	<-wait
 
-func (s *summarizer) Finish() {
-	s.Sum <- s.current
+	sort.Ints(res)
+	expectInts(t, res, []int{2, 4, 6, 8, 10, 12, 14, 16, 18, 20})
 }
 
-var runtimeNetworkJSON = `{
-	"properties": {
-		"name": "runtimeNetwork"
-	},
+var iipNetworkJSON = `{
 	"processes": {
-		"starter": {
-			"component": "starter"
-		},
-		"generator": {
-			"component": "sequenceGenerator"
-		},
-		"doubler": {
-			"component": "doubler"
-		},
-		"sum": {
-			"component": "summarizer"
+		"r": {
+			"component": "repeater"
 		}
 	},
 	"connections": [
 		{
-			"data": 10,
-			"tgt": {
-				"process": "starter",
-				"port": "Start"
-			}
-		},
-		{
-			"src": {
-				"process": "starter",
-				"port": "Out"
-			},
-			"tgt": {
-				"process": "generator",
-				"port": "Num"
-			}
-		},
-		{
-			"src": {
-				"process": "generator",
-				"port": "Sequence"
-			},
+			"data": "hello",
 			"tgt": {
-				"process": "doubler",
-				"port": "In"
+				"process": "r",
+				"port": "Word"
 			}
 		},
 		{
-			"src": {
-				"process": "doubler",
-				"port": "Out"
-			},
+			"data": 2,
 			"tgt": {
-				"process": "sum",
-				"port": "In"
+				"process": "r",
+				"port": "Times"
 			}
 		}
 	],
 	"exports": [
 		{
-			"private": "starter.Start",
-			"public": "Start"
-		},
-		{
-			"private": "sum.Sum",
+			"private": "r.Words",
 			"public": "Out"
 		}
 	]
 }`
 
-func TestRuntimeNetwork(t *testing.T) {
-	net := ParseJSON([]byte(runtimeNetworkJSON))
-	if net == nil {
-		t.Error("Could not load JSON")
+func TestIIPNetwork(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
 	}
 
-	start := make(chan float64)
-	out := make(chan int)
+	n, err := ParseJSON([]byte(iipNetworkJSON), f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
 
-	net.SetInPort("Start", start)
-	net.SetOutPort("Out", out)
+	out := make(chan string)
 
-	RunNet(net)
+	if err := n.SetOutPort("Out", out); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wait := Run(n)
 
-	// Wait for the network setup
-	<-net.Ready()
+	i := 0
+	for s := range out {
+		if s != "hello" {
+			t.Errorf("'%s' != 'hello'", s)
+		}
+		i++
+	}
 
-	// Close start to halt it normally
-	close(start)
+	<-wait
 
-	i := <-out
-	if i != 110 {
-		t.Errorf("Wrong result: %d != 110", i)
+	if i != 2 {
+		t.Errorf("%d != 2", i)
 	}
+}
 
-	<-net.Wait()
+func TestParseInvalidJSON(t *testing.T) {
+	f := NewFactory()
+	if err := RegisterTestComponents(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cases := []struct {
+		scenario string
+		json     string
+	}{
+		{"Malformed JSON", `{"processes":`},
+		{"Unknown component", `{"processes": {"x": {"component": "notfound"}}}`},
+		{"Invalid IIP type", `{"processes": {"r": {"component": "repeater"}}, "connections": [{"data": "two", "tgt": {"process": "r", "port": "Times"}}]}`},
+		{"Invalid export", `{"processes": {"r": {"component": "repeater"}}, "exports": [{"private": "r.Nope", "public": "Out"}]}`},
+	}
+
+	for _, item := range cases {
+		c := item
+		t.Run(c.scenario, func(t *testing.T) {
+			if _, err := ParseJSON([]byte(c.json), f); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
 }
@@ -14,6 +14,7 @@ type PortInfo struct {
 // ComponentInfo represents a component to a protocol client.
 type ComponentInfo struct {
 	Name        string     `json:"name"`
+	Version     string     `json:"version,omitempty"`
 	Description string     `json:"description"`
 	Icon        string     `json:"icon"`
 	Subgraph    bool       `json:"subgraph"`
@@ -57,17 +58,18 @@ type clearGraph struct {
 }
 
 // addNode message is sent by client to add a node to a graph.
+// Metadata may contain "poolSize" of the process.
 type addNode struct {
-	ID        string
-	Component string
-	Graph     string
-	Metadata  map[string]interface{} `json:",omitempty"` // ignored
+	ID        string                 `json:"id"`
+	Component string                 `json:"component"`
+	Graph     string                 `json:"graph"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // removeNode is a client message to remove a node from a graph.
 type removeNode struct {
-	ID    string
-	Graph string
+	ID    string `json:"id"`
+	Graph string `json:"graph"`
 }
 
 // renameNode is a client message to rename a node in a graph.
@@ -84,47 +86,34 @@ type changeNode struct { // ignored
 	Metadata map[string]interface{}
 }
 
+// edgeEnd is a port of a node in edge and IIP messages.
+// Keys of map ports are passed within the port name, e.g. "Out[key]".
+type edgeEnd struct {
+	Node  string `json:"node"`
+	Port  string `json:"port"`
+	Index *int   `json:"index,omitempty"`
+}
+
 // addEdge is a client message to create a connection in a graph.
+// Metadata may contain "buffer" size of the connection.
 type addEdge struct {
-	Src struct {
-		Node  string
-		Port  string
-		Index int `json:",omitempty"` // ignored
-	}
-	Tgt struct {
-		Node  string
-		Port  string
-		Index int `json:",omitempty"` // ignored
-	}
-	Graph    string
-	Metadata map[string]interface{} `json:",omitempty"` // ignored
+	Src      edgeEnd                `json:"src"`
+	Tgt      edgeEnd                `json:"tgt"`
+	Graph    string                 `json:"graph"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // removeEdge is a client message to delete a connection from a graph.
 type removeEdge struct {
-	Src struct {
-		Node string
-		Port string
-	}
-	Tgt struct {
-		Node string
-		Port string
-	}
-	Graph string
+	Src   edgeEnd `json:"src"`
+	Tgt   edgeEnd `json:"tgt"`
+	Graph string  `json:"graph"`
 }
 
 // changeEdge is a client message to change connection metadata.
 type changeEdge struct { // ignored
-	Src struct {
-		Node  string
-		Port  string
-		Index int `json:",omitempty"`
-	}
-	Tgt struct {
-		Node  string
-		Port  string
-		Index int `json:",omitempty"`
-	}
+	Src      edgeEnd
+	Tgt      edgeEnd
 	Graph    string
 	Metadata map[string]interface{}
 }
@@ -132,40 +121,36 @@ type changeEdge struct { // ignored
 // addInitial is a client message to add an IIP to a graph.
 type addInitial struct {
 	Src struct {
-		Data interface{}
-	}
-	Tgt struct {
-		Node  string
-		Port  string
-		Index int `json:",omitempty"` // ignored
-	}
-	Graph    string
-	Metadata map[string]interface{} `json:",omitempty"` // ignored
+		Data interface{} `json:"data"`
+	} `json:"src"`
+	Tgt      edgeEnd                `json:"tgt"`
+	Graph    string                 `json:"graph"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"` // ignored
 }
 
 // removeInitial is a client message to remove an IIP from a graph.
+// If data is set, only the IIP carrying that data is removed.
 type removeInitial struct {
-	Tgt struct {
-		Node  string
-		Port  string
-		Index int `json:",omitempty"` // ignored
-	}
-	Graph string
+	Src struct {
+		Data interface{} `json:"data"`
+	} `json:"src"`
+	Tgt   edgeEnd `json:"tgt"`
+	Graph string  `json:"graph"`
 }
 
 // addPort is a client message to add an exported inport/outport to the graph.
 type addPort struct {
-	Public   string
-	Node     string
-	Port     string
-	Graph    string
-	Metadata map[string]interface{} `json:",omitempty"` // ignored
+	Public   string                 `json:"public"`
+	Node     string                 `json:"node"`
+	Port     string                 `json:"port"`
+	Graph    string                 `json:"graph"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"` // ignored
 }
 
 // removePort is a client message to remove an exported inport/outport from the graph.
 type removePort struct {
-	Public string
-	Graph  string
+	Public string `json:"public"`
+	Graph  string `json:"graph"`
 }
 
 // renamePort is a client message to rename a port of a graph.
@@ -0,0 +1,249 @@
+package goflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionSeparator separates a component name from its version or version constraint,
+// e.g. "core/Split@1.2.0" or "core/Split@^1.2".
+const VersionSeparator = "@"
+
+// semVersion is a semantic version of a component.
+type semVersion struct {
+	major, minor, patch int
+	pre                 string // Pre-release suffix
+}
+
+func (v semVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+
+	return s
+}
+
+// compare returns -1, 0 or 1 if v is less, equal or greater than o.
+func (v semVersion) compare(o semVersion) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		// A release is greater than its pre-releases
+		return 1
+	case o.pre == "":
+		return -1
+	default:
+		return comparePre(v.pre, o.pre)
+	}
+}
+
+// comparePre compares dot-separated pre-release identifiers one by one. Numeric
+// identifiers are compared numerically and are lower than alphanumeric ones.
+// A larger set of identifiers is greater if the common ones are equal.
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// sameRelease tells if two versions have the same major, minor and patch numbers.
+func (v semVersion) sameRelease(o semVersion) bool {
+	return v.major == o.major && v.minor == o.minor && v.patch == o.patch
+}
+
+// parseVersion parses a full or partial version like "1.2.3", "v1.2" or "1.0.0-beta".
+// It returns the number of numeric parts which have been specified.
+func parseVersion(s string) (semVersion, int, error) {
+	var v semVersion
+
+	s = strings.TrimPrefix(s, "v")
+	if pos := strings.Index(s, "-"); pos >= 0 {
+		v.pre = s[pos+1:]
+		s = s[:pos]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || s == "" {
+		return v, 0, fmt.Errorf("invalid version '%s'", s)
+	}
+
+	nums := []*int{&v.major, &v.minor, &v.patch}
+
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("invalid version '%s'", s)
+		}
+
+		*nums[i] = n
+	}
+
+	return v, len(parts), nil
+}
+
+// versionComparator is a single condition of a version constraint.
+type versionComparator struct {
+	op string
+	v  semVersion
+}
+
+func (c versionComparator) match(v semVersion) bool {
+	cmp := v.compare(c.v)
+
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// versionConstraint is a list of conditions which must all be met.
+// A pre-release only matches if one of the conditions names a pre-release
+// of the same version, e.g. ">=1.2.0-beta" matches "1.2.0-rc.1" but not "1.3.0-alpha".
+type versionConstraint []versionComparator
+
+func (c versionConstraint) match(v semVersion) bool {
+	allowPre := v.pre == ""
+
+	for _, cmp := range c {
+		if !cmp.match(v) {
+			return false
+		}
+
+		if cmp.v.pre != "" && cmp.v.sameRelease(v) {
+			allowPre = true
+		}
+	}
+
+	return allowPre
+}
+
+// parseConstraint parses a space-separated list of conditions. Supported forms are
+// exact versions ("1.2.3"), partial versions ("1.2" meaning 1.2.x), caret ("^1.2"),
+// tilde ("~1.2"), comparisons (">=1.0.0", "<2") and wildcards ("*", "latest").
+func parseConstraint(s string) (versionConstraint, error) {
+	c := versionConstraint{}
+
+	for _, term := range strings.Fields(s) {
+		if term == "*" || term == "latest" {
+			continue
+		}
+
+		op := ""
+
+		for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(term, prefix) {
+				op = prefix
+				term = term[len(prefix):]
+
+				break
+			}
+		}
+
+		v, parts, err := parseVersion(term)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "^":
+			c = append(c, versionComparator{">=", v}, versionComparator{"<", caretLimit(v, parts)})
+		case "~", "":
+			if op == "" && parts == 3 {
+				c = append(c, versionComparator{"=", v})
+				break
+			}
+
+			c = append(c, versionComparator{">=", v}, versionComparator{"<", tildeLimit(v, parts)})
+		case "=":
+			c = append(c, versionComparator{"=", v})
+		default:
+			c = append(c, versionComparator{op, v})
+		}
+	}
+
+	return c, nil
+}
+
+// caretLimit returns the first version which is not compatible with v.
+func caretLimit(v semVersion, parts int) semVersion {
+	switch {
+	case v.major > 0 || parts == 1:
+		return semVersion{major: v.major + 1}
+	case v.minor > 0 || parts == 2:
+		return semVersion{minor: v.minor + 1}
+	default:
+		return semVersion{patch: v.patch + 1}
+	}
+}
+
+// tildeLimit returns the first version which has a different minor (or major if
+// minor is not specified) version than v.
+func tildeLimit(v semVersion, parts int) semVersion {
+	if parts == 1 {
+		return semVersion{major: v.major + 1}
+	}
+
+	return semVersion{major: v.major, minor: v.minor + 1}
+}
+
+// splitComponentRef splits a component reference like "Split@^1.2" into a name and a version constraint.
+func splitComponentRef(ref string) (name, constraint string) {
+	pos := strings.LastIndex(ref, VersionSeparator)
+	if pos < 0 {
+		return ref, ""
+	}
+
+	return ref[:pos], ref[pos+1:]
+}
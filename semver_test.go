@@ -0,0 +1,120 @@
+package goflow
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"v1.2", "1.2.0", true},
+		{"2", "2.0.0", true},
+		{"1.0.0-beta", "1.0.0-beta", true},
+		{"", "", false},
+		{"1.x", "", false},
+		{"1.2.3.4", "", false},
+	}
+
+	for _, c := range cases {
+		v, _, err := parseVersion(c.input)
+		if c.valid != (err == nil) {
+			t.Errorf("'%s': unexpected error %v", c.input, err)
+			continue
+		}
+
+		if c.valid && v.String() != c.expected {
+			t.Errorf("'%s' != '%s'", v, c.expected)
+		}
+	}
+}
+
+func TestVersionConstraints(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		match      bool
+	}{
+		{"", "1.2.3", true},
+		{"*", "0.0.1", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.2", "1.2.9", true},
+		{"1.2", "1.3.0", false},
+		{"^1.2", "1.9.0", true},
+		{"^1.2", "1.1.0", false},
+		{"^1.2", "2.0.0", false},
+		{"^0.2.1", "0.2.5", true},
+		{"^0.2.1", "0.3.0", false},
+		{"~1.2.1", "1.2.5", true},
+		{"~1.2.1", "1.3.0", false},
+		{">=1.0.0 <2", "1.5.0", true},
+		{">=1.0.0 <2", "2.0.0", false},
+		{">1", "1.0.0", false},
+		{"<=1.0.0", "1.0.0-rc1", false},
+		{"", "1.2.3-beta", false},
+		{"^1.2", "1.3.0-beta", false},
+		{"1.3.0-beta", "1.3.0-beta", true},
+		{">=1.3.0-alpha", "1.3.0-beta", true},
+		{">=1.3.0-alpha <2", "1.4.0-beta", false},
+		{"^1.3.0-beta.2", "1.3.0-beta.11", true},
+		{"^1.3.0-beta.11", "1.3.0-beta.2", false},
+	}
+
+	for _, c := range cases {
+		constraint, err := parseConstraint(c.constraint)
+		if err != nil {
+			t.Errorf("'%s': %v", c.constraint, err)
+			continue
+		}
+
+		v, _, err := parseVersion(c.version)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if constraint.match(v) != c.match {
+			t.Errorf("'%s' matching '%s' != %v", c.constraint, c.version, c.match)
+		}
+	}
+
+	if _, err := parseConstraint("^abc"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestComparePreRelease(t *testing.T) {
+	// Ordered as in the example of SemVer 2.0.0
+	versions := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+	}
+
+	for i := 1; i < len(versions); i++ {
+		a, _, err := parseVersion(versions[i-1])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		b, _, err := parseVersion(versions[i])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if a.compare(b) != -1 || b.compare(a) != 1 || a.compare(a) != 0 {
+			t.Errorf("'%s' is not less than '%s'", a, b)
+		}
+	}
+}